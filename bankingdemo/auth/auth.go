@@ -0,0 +1,123 @@
+// Package auth authenticates the users of the demo's web UIs and APIs and checks their roles. Credentials come
+// from a static users file over HTTP basic authentication or from JWTs signed by a key in a local JWKS; several
+// authenticators can be chained.
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Roles a user can hold
+const (
+	RoleCustomer       = "customer"
+	RoleReviewer       = "reviewer"
+	RoleSeniorReviewer = "senior-reviewer"
+	RoleOps            = "ops"
+)
+
+var allRoles = []string{RoleCustomer, RoleReviewer, RoleSeniorReviewer, RoleOps}
+
+// ErrNoCredentials is returned by an Authenticator when the request carries none of its credentials
+var ErrNoCredentials = errors.New("no credentials")
+
+// Identity is an authenticated user. Subject is empty when authentication is disabled. It is the stable key
+// transfers are owned by; Name is only for display and may be chosen by the user.
+type Identity struct {
+	Subject string
+	Name    string
+	Roles   []string
+}
+
+// DisplayName is the user's name, or Subject when there is none
+func (i Identity) DisplayName() string {
+	if i.Name != "" {
+		return i.Name
+	}
+	return i.Subject
+}
+
+// HasRole reports whether the identity holds any of roles
+func (i Identity) HasRole(roles ...string) bool {
+	for _, held := range i.Roles {
+		for _, r := range roles {
+			if held == r {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Authenticator establishes who made a request.
+type Authenticator interface {
+	// Authenticate returns ErrNoCredentials when the request has none of the authenticator's credentials
+	Authenticate(r *http.Request) (Identity, error)
+	// Challenge is the WWW-Authenticate value asking for the authenticator's credentials
+	Challenge() string
+}
+
+// None lets everybody in with every role. It keeps the demo usable without any setup.
+type None struct{}
+
+func (None) Authenticate(*http.Request) (Identity, error) {
+	return Identity{Roles: allRoles}, nil
+}
+
+func (None) Challenge() string { return "" }
+
+// Chain tries each authenticator in turn; the first one finding its credentials decides.
+type Chain []Authenticator
+
+func (c Chain) Authenticate(r *http.Request) (Identity, error) {
+	for _, a := range c {
+		identity, err := a.Authenticate(r)
+		if errors.Is(err, ErrNoCredentials) {
+			continue
+		}
+		return identity, err
+	}
+	return Identity{}, ErrNoCredentials
+}
+
+func (c Chain) Challenge() string {
+	challenges := []string{}
+	for _, a := range c {
+		if v := a.Challenge(); v != "" {
+			challenges = append(challenges, v)
+		}
+	}
+	return strings.Join(challenges, ", ")
+}
+
+type identityKey struct{}
+
+// FromContext returns the identity Require stored in the request context
+func FromContext(ctx context.Context) Identity {
+	identity, _ := ctx.Value(identityKey{}).(Identity)
+	return identity
+}
+
+// Require only lets requests authenticated by a and holding one of roles through to h
+func Require(a Authenticator, h http.HandlerFunc, roles ...string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		identity, err := a.Authenticate(r)
+		if err != nil {
+			if challenge := a.Challenge(); challenge != "" {
+				w.Header().Set("WWW-Authenticate", challenge)
+			}
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprint(w, "ERROR: UNAUTHENTICATED")
+			return
+		}
+		if len(roles) > 0 && !identity.HasRole(roles...) {
+			w.WriteHeader(http.StatusForbidden)
+			fmt.Fprintf(w, "ERROR: FORBIDDEN, needs one of the roles %s", strings.Join(roles, ", "))
+			return
+		}
+		h(w, r.WithContext(context.WithValue(r.Context(), identityKey{}, identity)))
+	}
+}
@@ -0,0 +1,60 @@
+package auth
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strings"
+)
+
+const defaultRolesClaim = "roles"
+
+/*
+FromEnv builds the authenticator configured by:
+  - AUTH_MODE: comma separated list of none, basic and jwt (default none: everyone is let in with every role)
+  - AUTH_USERS_FILE: JSON list of User, for basic
+  - AUTH_JWKS_FILE: JWKS holding the keys tokens are signed with, for jwt
+  - AUTH_JWT_ISSUER, AUTH_JWT_AUDIENCE: the iss and aud tokens must have (default: not checked)
+  - AUTH_JWT_ROLES_CLAIM: the claim holding the roles (default roles)
+
+realm names the application in basic authentication prompts.
+*/
+func FromEnv(realm string) (Authenticator, error) {
+	mode := os.Getenv("AUTH_MODE")
+	if mode == "" || mode == "none" {
+		log.Printf("AUTH_MODE is none, %s is open to everyone", realm)
+		return None{}, nil
+	}
+	result := Chain{}
+	for _, m := range strings.Split(mode, ",") {
+		switch strings.TrimSpace(m) {
+		case "basic":
+			path := os.Getenv("AUTH_USERS_FILE")
+			if path == "" {
+				return nil, fmt.Errorf("AUTH_USERS_FILE is needed for basic authentication")
+			}
+			b, err := NewBasic(path, realm)
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, b)
+		case "jwt":
+			path := os.Getenv("AUTH_JWKS_FILE")
+			if path == "" {
+				return nil, fmt.Errorf("AUTH_JWKS_FILE is needed for jwt authentication")
+			}
+			rolesClaim := os.Getenv("AUTH_JWT_ROLES_CLAIM")
+			if rolesClaim == "" {
+				rolesClaim = defaultRolesClaim
+			}
+			j, err := NewJWT(path, os.Getenv("AUTH_JWT_ISSUER"), os.Getenv("AUTH_JWT_AUDIENCE"), rolesClaim)
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, j)
+		default:
+			return nil, fmt.Errorf("bad AUTH_MODE: %q", mode)
+		}
+	}
+	return result, nil
+}
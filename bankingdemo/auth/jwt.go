@@ -0,0 +1,190 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// clockSkew is tolerated on the exp and nbf claims
+const clockSkew = time.Minute
+
+// JWT validates RS256 bearer tokens, such as the ID or access tokens of an OIDC provider, against the keys of a
+// local JWKS file. Users are identified by the sub claim, unique per issuer, so Issuer should be set when the keys
+// are shared with other issuers. preferred_username, which users can often change, is only their display name.
+type JWT struct {
+	Issuer     string // required iss when set
+	Audience   string // required among aud when set
+	RolesClaim string // claim holding the roles, an array or a space separated string
+	keys       map[string]*rsa.PublicKey
+	now        func() time.Time
+}
+
+type jwks struct {
+	Keys []struct {
+		Kty string `json:"kty"`
+		Kid string `json:"kid"`
+		Use string `json:"use"`
+		N   string `json:"n"`
+		E   string `json:"e"`
+	} `json:"keys"`
+}
+
+// NewJWT reads the RSA keys of the JWKS file at path
+func NewJWT(path, issuer, audience, rolesClaim string) (*JWT, error) {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	set := jwks{}
+	if err := json.Unmarshal(contents, &set); err != nil {
+		return nil, fmt.Errorf("bad JWKS %s: %w", path, err)
+	}
+	result := &JWT{Issuer: issuer, Audience: audience, RolesClaim: rolesClaim, keys: make(map[string]*rsa.PublicKey), now: time.Now}
+	for _, k := range set.Keys {
+		if k.Kty != "RSA" || (k.Use != "" && k.Use != "sig") {
+			continue
+		}
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, fmt.Errorf("bad modulus of key %q in %s: %w", k.Kid, path, err)
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil || len(e) == 0 || len(e) > 4 {
+			return nil, fmt.Errorf("bad exponent of key %q in %s", k.Kid, path)
+		}
+		result.keys[k.Kid] = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+	}
+	if len(result.keys) == 0 {
+		return nil, fmt.Errorf("no RSA signing keys in JWKS %s", path)
+	}
+	return result, nil
+}
+
+func (j *JWT) Authenticate(r *http.Request) (Identity, error) {
+	header := r.Header.Get("Authorization")
+	if !strings.HasPrefix(header, "Bearer ") {
+		return Identity{}, ErrNoCredentials
+	}
+	claims, err := j.verify(strings.TrimSpace(strings.TrimPrefix(header, "Bearer ")))
+	if err != nil {
+		return Identity{}, err
+	}
+	return j.identity(claims)
+}
+
+func (j *JWT) Challenge() string {
+	return "Bearer"
+}
+
+// verify checks the signature and the registered claims of token and returns its claims
+func (j *JWT) verify(token string) (map[string]interface{}, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("malformed token")
+	}
+	header := struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}{}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("bad token header: %w", err)
+	}
+	if header.Alg != "RS256" {
+		return nil, fmt.Errorf("unsupported algorithm %q", header.Alg)
+	}
+	key, ok := j.keys[header.Kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown key %q", header.Kid)
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("bad signature encoding: %w", err)
+	}
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature); err != nil {
+		return nil, fmt.Errorf("bad signature")
+	}
+	claims := map[string]interface{}{}
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, fmt.Errorf("bad token claims: %w", err)
+	}
+
+	now := j.now()
+	exp, ok := claims["exp"].(float64)
+	if !ok {
+		return nil, fmt.Errorf("token has no expiry")
+	}
+	if now.After(time.Unix(int64(exp), 0).Add(clockSkew)) {
+		return nil, fmt.Errorf("token expired")
+	}
+	if nbf, ok := claims["nbf"].(float64); ok && now.Add(clockSkew).Before(time.Unix(int64(nbf), 0)) {
+		return nil, fmt.Errorf("token not yet valid")
+	}
+	if j.Issuer != "" && claims["iss"] != j.Issuer {
+		return nil, fmt.Errorf("unexpected issuer %v", claims["iss"])
+	}
+	if j.Audience != "" && !containsString(stringList(claims["aud"]), j.Audience) {
+		return nil, fmt.Errorf("token is not meant for %s", j.Audience)
+	}
+	return claims, nil
+}
+
+func (j *JWT) identity(claims map[string]interface{}) (Identity, error) {
+	subject, _ := claims["sub"].(string)
+	if subject == "" {
+		return Identity{}, fmt.Errorf("token has no subject")
+	}
+	name, _ := claims["preferred_username"].(string)
+	result := Identity{Subject: subject, Name: name}
+	for _, r := range stringList(claims[j.RolesClaim]) {
+		// roles of other applications may share the claim
+		if containsString(allRoles, r) {
+			result.Roles = append(result.Roles, r)
+		}
+	}
+	return result, nil
+}
+
+func decodeSegment(segment string, v interface{}) error {
+	contents, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(contents, v)
+}
+
+// stringList reads a claim that is either a string, split on spaces, or an array of strings
+func stringList(claim interface{}) []string {
+	switch v := claim.(type) {
+	case string:
+		return strings.Fields(v)
+	case []interface{}:
+		result := []string{}
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				result = append(result, s)
+			}
+		}
+		return result
+	default:
+		return nil
+	}
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,51 @@
+package auth
+
+import "testing"
+
+func TestJWTIdentity(t *testing.T) {
+	j := &JWT{RolesClaim: "roles"}
+	tests := []struct {
+		name    string
+		claims  map[string]interface{}
+		want    Identity
+		wantErr bool
+	}{
+		{
+			name:   "subject is sub, not the username",
+			claims: map[string]interface{}{"sub": "1f3a", "preferred_username": "alice", "roles": []interface{}{RoleCustomer, "other-app"}},
+			want:   Identity{Subject: "1f3a", Name: "alice", Roles: []string{RoleCustomer}},
+		},
+		{
+			name:   "no username",
+			claims: map[string]interface{}{"sub": "1f3a", "roles": RoleOps + " " + RoleCustomer},
+			want:   Identity{Subject: "1f3a", Roles: []string{RoleOps, RoleCustomer}},
+		},
+		{
+			name:    "a username is not enough",
+			claims:  map[string]interface{}{"preferred_username": "alice"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := j.identity(tt.claims)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("got %+v, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Subject != tt.want.Subject || got.Name != tt.want.Name || len(got.Roles) != len(tt.want.Roles) {
+				t.Fatalf("got %+v, want %+v", got, tt.want)
+			}
+			for i := range got.Roles {
+				if got.Roles[i] != tt.want.Roles[i] {
+					t.Fatalf("got roles %v, want %v", got.Roles, tt.want.Roles)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,88 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// User is an entry of the static users file. Password is "sha256:<salt>:<hex digest>" where the digest is the
+// SHA-256 of the salt followed by the password, e.g. made with: printf '%s' "$SALT$PASSWORD" | sha256sum
+type User struct {
+	Username string   `json:"username"`
+	Password string   `json:"password"`
+	Roles    []string `json:"roles"`
+}
+
+// Basic authenticates HTTP basic credentials against a static users file.
+type Basic struct {
+	Realm string
+	users map[string]User
+}
+
+// NewBasic reads the JSON users file at path
+func NewBasic(path, realm string) (*Basic, error) {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	users := []User{}
+	if err := json.Unmarshal(contents, &users); err != nil {
+		return nil, fmt.Errorf("bad users file %s: %w", path, err)
+	}
+	result := &Basic{Realm: realm, users: make(map[string]User)}
+	for _, u := range users {
+		if _, _, err := splitPassword(u.Password); err != nil {
+			return nil, fmt.Errorf("bad password of %s in %s: %w", u.Username, path, err)
+		}
+		if err := validateRoles(u.Roles); err != nil {
+			return nil, fmt.Errorf("bad roles of %s in %s: %w", u.Username, path, err)
+		}
+		result.users[u.Username] = u
+	}
+	return result, nil
+}
+
+func (b *Basic) Authenticate(r *http.Request) (Identity, error) {
+	username, password, ok := r.BasicAuth()
+	if !ok {
+		return Identity{}, ErrNoCredentials
+	}
+	u, known := b.users[username]
+	salt, digest, _ := splitPassword(u.Password)
+	given := sha256.Sum256([]byte(salt + password))
+	if subtle.ConstantTimeCompare(given[:], digest) != 1 || !known {
+		return Identity{}, fmt.Errorf("bad username or password")
+	}
+	return Identity{Subject: u.Username, Roles: u.Roles}, nil
+}
+
+func (b *Basic) Challenge() string {
+	return fmt.Sprintf("Basic realm=%q, charset=\"UTF-8\"", b.Realm)
+}
+
+func splitPassword(password string) (string, []byte, error) {
+	parts := strings.Split(password, ":")
+	if len(parts) != 3 || parts[0] != "sha256" {
+		return "", nil, fmt.Errorf("want sha256:<salt>:<hex digest>")
+	}
+	digest, err := hex.DecodeString(parts[2])
+	if err != nil || len(digest) != sha256.Size {
+		return "", nil, fmt.Errorf("bad digest")
+	}
+	return parts[1], digest, nil
+}
+
+func validateRoles(roles []string) error {
+	for _, r := range roles {
+		if !containsString(allRoles, r) {
+			return fmt.Errorf("unknown role %q", r)
+		}
+	}
+	return nil
+}
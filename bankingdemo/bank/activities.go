@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+
+	"github.com/arunsworld/temporal-demo/bankingdemo/banksim"
+	"github.com/arunsworld/temporal-demo/bankingdemo/contracts"
+	"github.com/arunsworld/temporal-demo/bankingdemo/faults"
+	"go.temporal.io/sdk/activity"
+)
+
+// activities are registered once per hosted bank, each instance on its own task queue
+type activities struct {
+	bank   string
+	ledger *banksim.Ledger
+	faults *faults.Injector
+}
+
+func (a *activities) Withdraw(ctx context.Context, txn contracts.BankTransaction) (contracts.TxnResponse, error) {
+	if resp, injected, err := a.injectFaults(ctx, contracts.WithdrawActivity, txn); injected {
+		return resp, err
+	}
+	entry, err := a.ledger.Debit(idempotencyKey(ctx, txn), txn.AccountID, txn.Amount, txn.Reference)
+	if err != nil {
+		log.Printf("[%s] withdraw of %s from %s failed: %v. Ref: [%s]", a.bank, txn.Amount, txn.AccountID, err, txn.Reference)
+		return failedTxn(err), nil
+	}
+	log.Printf("[%s] withdrawn %s from %s, balance %s. Ref: [%s]", a.bank, txn.Amount, txn.AccountID, entry.Balance, txn.Reference)
+	return contracts.TxnResponse{Status: contracts.TxnSuccess}, nil
+}
+
+func (a *activities) Deposit(ctx context.Context, txn contracts.BankTransaction) (contracts.TxnResponse, error) {
+	if resp, injected, err := a.injectFaults(ctx, contracts.DepositActivity, txn); injected {
+		return resp, err
+	}
+	entry, err := a.ledger.Credit(idempotencyKey(ctx, txn), txn.AccountID, txn.Amount, txn.Reference, txn.IsRefund)
+	if err != nil {
+		log.Printf("[%s] deposit of %s to %s failed: %v. Ref: [%s]", a.bank, txn.Amount, txn.AccountID, err, txn.Reference)
+		return failedTxn(err), nil
+	}
+	log.Printf("[%s] deposited %s to %s, balance %s. Ref: [%s]", a.bank, txn.Amount, txn.AccountID, entry.Balance, txn.Reference)
+	return contracts.TxnResponse{Status: contracts.TxnSuccess}, nil
+}
+
+func (a *activities) injectFaults(ctx context.Context, activity string, txn contracts.BankTransaction) (contracts.TxnResponse, bool, error) {
+	decision := a.faults.Decide(activity, txn.AccountID, txn.Reference)
+	if err := decision.Wait(ctx); err != nil {
+		log.Printf("[%s] injected %s error for %s: %v", a.bank, activity, txn.Reference, err)
+		return contracts.TxnResponse{}, true, err
+	}
+	if decision.FailureReason != "" {
+		log.Printf("[%s] injected %s failure for %s: %s", a.bank, activity, txn.Reference, decision.FailureReason)
+		return contracts.TxnResponse{
+			Status:        contracts.TxnFailure,
+			FailureReason: decision.FailureReason,
+		}, true, nil
+	}
+	return contracts.TxnResponse{}, false, nil
+}
+
+// callers that predate idempotency keys get one derived from the activity info, which is stable across retries
+func idempotencyKey(ctx context.Context, txn contracts.BankTransaction) string {
+	if txn.IdempotencyKey != "" {
+		return txn.IdempotencyKey
+	}
+	info := activity.GetInfo(ctx)
+	return contracts.IdempotencyKey(info.WorkflowExecution.ID, info.ActivityType.Name, txn.Reference)
+}
+
+func failedTxn(err error) contracts.TxnResponse {
+	reason := err.Error()
+	switch {
+	case errors.Is(err, banksim.ErrAccountNotFound):
+		reason = "Account ID Not Found"
+	case errors.Is(err, banksim.ErrInsufficientFunds):
+		reason = "Not Enough Funds"
+	case errors.Is(err, banksim.ErrInvalidAmount):
+		reason = "Invalid Amount"
+	case errors.Is(err, banksim.ErrCurrencyMismatch):
+		reason = "Currency Not Supported By Account"
+	case errors.Is(err, banksim.ErrIdempotencyKeyReused):
+		reason = "Duplicate Transaction"
+	}
+	return contracts.TxnResponse{
+		Status:        contracts.TxnFailure,
+		FailureReason: reason,
+	}
+}
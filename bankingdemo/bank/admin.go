@@ -0,0 +1,60 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/arunsworld/temporal-demo/bankingdemo/auth"
+	"github.com/arunsworld/temporal-demo/bankingdemo/banksim"
+	"github.com/arunsworld/temporal-demo/bankingdemo/contracts"
+)
+
+// newAdminHandler serves, for every hosted bank:
+//   - /admin/<bank>/faults: the fault profile, see faults.Injector.Handler
+//   - /admin/<bank>/accounts: balances and the journal, optionally for a single ?account=
+//
+// Only ops may use it.
+func newAdminHandler(banks []*bank, authn auth.Authenticator) http.Handler {
+	mux := http.NewServeMux()
+	for _, b := range banks {
+		b := b
+		mux.Handle("/admin/"+b.id+"/faults", b.faults.Handler())
+		mux.HandleFunc("/admin/"+b.id+"/accounts", b.accountsHandler)
+	}
+	mux.HandleFunc("/admin/", func(w http.ResponseWriter, r *http.Request) {
+		if strings.TrimSuffix(r.URL.Path, "/") != "/admin" {
+			http.NotFound(w, r)
+			return
+		}
+		ids := []string{}
+		for _, b := range banks {
+			ids = append(ids, b.id)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(struct {
+			Banks []string
+		}{Banks: ids})
+	})
+	return auth.Require(authn, mux.ServeHTTP, auth.RoleOps)
+}
+
+func (b *bank) accountsHandler(w http.ResponseWriter, r *http.Request) {
+	account := r.URL.Query().Get("account")
+	balances := make(map[string]contracts.Money)
+	for _, id := range b.ledger.Accounts() {
+		if account != "" && id != account {
+			continue
+		}
+		balance, _ := b.ledger.Balance(id)
+		balances[id] = balance
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(struct {
+		Balances map[string]contracts.Money
+		Journal  []banksim.Entry
+	}{
+		Balances: balances,
+		Journal:  b.ledger.Journal(account),
+	})
+}
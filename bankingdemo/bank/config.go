@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/arunsworld/temporal-demo/bankingdemo/banksim"
+	"github.com/arunsworld/temporal-demo/bankingdemo/contracts"
+	"github.com/arunsworld/temporal-demo/bankingdemo/faults"
+)
+
+/*
+Relies on:
+  - BANKS: comma separated bank IDs to host, each one is also its task queue (default abbank,bcbank)
+  - <BANK>_ACCOUNTS: accounts to open as ID:BALANCE[:CURRENCY],ID:BALANCE[:CURRENCY]
+  - <BANK>_CURRENCY: currency of the accounts that do not name one (default USD)
+  - <BANK>_FAULTS_FILE, <BANK>_FAULT_*: fault profile, see faults.LoadProfile
+  - BANK_ADMIN_ADDR: address of the admin endpoint (default :9499)
+  - AUTH_*: who may use the admin endpoint, only ops, see auth.FromEnv
+
+<BANK> is the bank ID upper cased with dashes replaced by underscores, e.g. ABBANK_ACCOUNTS.
+*/
+
+const defaultBanks = contracts.ABBankTaskQueue + "," + contracts.BCBankTaskQueue
+const defaultAdminAddr = ":9499"
+const defaultCurrency = "USD"
+
+// used for the demo banks when <BANK>_ACCOUNTS is not set
+var defaultAccounts = map[string]string{
+	contracts.ABBankTaskQueue: "12345:100000",
+	contracts.BCBankTaskQueue: "99999:0",
+}
+
+type bank struct {
+	id     string
+	ledger *banksim.Ledger
+	faults *faults.Injector
+}
+
+func loadBanks() ([]*bank, error) {
+	ids := os.Getenv("BANKS")
+	if ids == "" {
+		ids = defaultBanks
+	}
+	result := []*bank{}
+	seen := make(map[string]bool)
+	for _, id := range strings.Split(ids, ",") {
+		id = strings.TrimSpace(id)
+		if id == "" {
+			continue
+		}
+		if seen[id] {
+			return nil, fmt.Errorf("bank %s configured twice", id)
+		}
+		seen[id] = true
+		b, err := loadBank(id)
+		if err != nil {
+			return nil, fmt.Errorf("bad configuration for bank %s: %w", id, err)
+		}
+		result = append(result, b)
+	}
+	if len(result) == 0 {
+		return nil, fmt.Errorf("no banks configured")
+	}
+	return result, nil
+}
+
+func loadBank(id string) (*bank, error) {
+	prefix := envPrefix(id)
+
+	ledger := banksim.NewLedger()
+	accounts := os.Getenv(prefix + "ACCOUNTS")
+	if accounts == "" {
+		accounts = defaultAccounts[id]
+	}
+	currency := strings.ToUpper(os.Getenv(prefix + "CURRENCY"))
+	if currency == "" {
+		currency = defaultCurrency
+	}
+	if err := ledger.OpenAccounts(accounts, currency); err != nil {
+		return nil, err
+	}
+
+	profile, err := faults.LoadProfile(prefix)
+	if err != nil {
+		return nil, err
+	}
+	injector, err := faults.NewInjector(profile)
+	if err != nil {
+		return nil, err
+	}
+	return &bank{id: id, ledger: ledger, faults: injector}, nil
+}
+
+func envPrefix(id string) string {
+	return strings.ToUpper(strings.ReplaceAll(id, "-", "_")) + "_"
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/arunsworld/nursery"
+	"github.com/arunsworld/temporal-demo/bankingdemo/auth"
+	"github.com/arunsworld/temporal-demo/bankingdemo/contracts"
+	temporalgolibs "github.com/arunsworld/temporal-demo/temporal-golibs"
+	"go.temporal.io/sdk/activity"
+	"go.temporal.io/sdk/worker"
+)
+
+func main() {
+	if err := run(); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func run() error {
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	banks, err := loadBanks()
+	if err != nil {
+		return err
+	}
+
+	c, err := temporalgolibs.NewClient(ctx, "default")
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	workers := []worker.Worker{}
+	for _, b := range banks {
+		acts := &activities{bank: b.id, ledger: b.ledger, faults: b.faults}
+		w := worker.New(c, b.id, worker.Options{})
+		w.RegisterActivityWithOptions(acts.Withdraw, activity.RegisterOptions{
+			Name: contracts.WithdrawActivity,
+		})
+		w.RegisterActivityWithOptions(acts.Deposit, activity.RegisterOptions{
+			Name: contracts.DepositActivity,
+		})
+		workers = append(workers, w)
+		log.Printf("hosting bank %s with accounts %v", b.id, b.ledger.Accounts())
+	}
+
+	adminAddr := os.Getenv("BANK_ADMIN_ADDR")
+	if adminAddr == "" {
+		adminAddr = defaultAdminAddr
+	}
+	authn, err := auth.FromEnv("Bank Admin")
+	if err != nil {
+		return err
+	}
+	server := &http.Server{Addr: adminAddr, Handler: newAdminHandler(banks, authn)}
+
+	return nursery.RunConcurrentlyWithContext(ctx,
+		func(ctx context.Context, errCh chan error) {
+			for _, w := range workers {
+				if err := w.Start(); err != nil {
+					errCh <- err
+					return
+				}
+				defer w.Stop()
+			}
+			<-ctx.Done()
+		},
+		func(context.Context, chan error) {
+			log.Printf("admin endpoint on http://localhost%s/admin/", adminAddr)
+			if err := server.ListenAndServe(); err != nil {
+				log.Printf("unable to serve on %s", adminAddr)
+			}
+		},
+		func(ctx context.Context, errCh chan error) {
+			<-ctx.Done()
+			server.Close()
+		},
+	)
+}
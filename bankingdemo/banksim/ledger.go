@@ -0,0 +1,221 @@
+// Package banksim simulates the core banking system the bank teams integrate with: accounts, balances and a
+// journal of every posting.
+package banksim
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/arunsworld/temporal-demo/bankingdemo/contracts"
+)
+
+var ErrAccountNotFound = errors.New("account not found")
+var ErrAccountExists = errors.New("account already exists")
+var ErrInsufficientFunds = errors.New("insufficient funds")
+var ErrInvalidAmount = errors.New("invalid amount")
+var ErrCurrencyMismatch = errors.New("amount is not in the account's currency")
+var ErrIdempotencyKeyReused = errors.New("idempotency key reused for a different posting")
+
+// Journal entry kinds
+const (
+	Debit  = "debit"
+	Credit = "credit"
+	Refund = "refund"
+)
+
+type Entry struct {
+	Time      time.Time
+	AccountID string
+	Kind      string
+	Amount    contracts.Money
+	Balance   contracts.Money // balance after the posting
+	Reference string
+	// IdempotencyKey is empty for postings made without one
+	IdempotencyKey string
+}
+
+// Ledger keeps balances, each in the currency of its account, and a journal of postings. Postings made with an idempotency key are applied at most
+// once: repeating a successful posting returns the original entry. Failed postings move no money and are not
+// remembered, so they can be retried once the account is in a better state.
+type Ledger struct {
+	mu       sync.Mutex
+	balances map[string]contracts.Money
+	journal  []Entry
+	postings map[string]Entry
+}
+
+func NewLedger() *Ledger {
+	return &Ledger{
+		balances: make(map[string]contracts.Money),
+		postings: make(map[string]Entry),
+	}
+}
+
+// Open opens an account in the currency of balance
+func (l *Ledger) Open(accountID string, balance contracts.Money) error {
+	if balance.Minor < 0 || balance.Currency == "" {
+		return ErrInvalidAmount
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if _, ok := l.balances[accountID]; ok {
+		return ErrAccountExists
+	}
+	l.balances[accountID] = balance
+	return nil
+}
+
+func (l *Ledger) Balance(accountID string) (contracts.Money, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	balance, ok := l.balances[accountID]
+	if !ok {
+		return contracts.Money{}, ErrAccountNotFound
+	}
+	return balance, nil
+}
+
+// Accounts returns the IDs of all open accounts, sorted.
+func (l *Ledger) Accounts() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	result := make([]string, 0, len(l.balances))
+	for id := range l.balances {
+		result = append(result, id)
+	}
+	sort.Strings(result)
+	return result
+}
+
+func (l *Ledger) Debit(idempotencyKey, accountID string, amount contracts.Money, ref string) (Entry, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if amount.Minor <= 0 {
+		return Entry{}, ErrInvalidAmount
+	}
+	if entry, ok, err := l.previousPosting(idempotencyKey, accountID, Debit, amount); ok {
+		return entry, err
+	}
+	balance, ok := l.balances[accountID]
+	if !ok {
+		return Entry{}, ErrAccountNotFound
+	}
+	if balance.Currency != amount.Currency {
+		return Entry{}, ErrCurrencyMismatch
+	}
+	if balance.Minor < amount.Minor {
+		return Entry{}, ErrInsufficientFunds
+	}
+	balance.Minor -= amount.Minor
+	return l.post(idempotencyKey, accountID, Debit, amount, balance, ref), nil
+}
+
+// Credit posts money into an account. Refunds are journaled separately so they can be told apart from
+// incoming transfers.
+func (l *Ledger) Credit(idempotencyKey, accountID string, amount contracts.Money, ref string, isRefund bool) (Entry, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if amount.Minor <= 0 {
+		return Entry{}, ErrInvalidAmount
+	}
+	kind := Credit
+	if isRefund {
+		kind = Refund
+	}
+	if entry, ok, err := l.previousPosting(idempotencyKey, accountID, kind, amount); ok {
+		return entry, err
+	}
+	balance, ok := l.balances[accountID]
+	if !ok {
+		return Entry{}, ErrAccountNotFound
+	}
+	if balance.Currency != amount.Currency {
+		return Entry{}, ErrCurrencyMismatch
+	}
+	balance.Minor += amount.Minor
+	return l.post(idempotencyKey, accountID, kind, amount, balance, ref), nil
+}
+
+// Journal returns the postings against accountID in order, or every posting if accountID is empty.
+func (l *Ledger) Journal(accountID string) []Entry {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	result := []Entry{}
+	for _, e := range l.journal {
+		if accountID == "" || e.AccountID == accountID {
+			result = append(result, e)
+		}
+	}
+	return result
+}
+
+// must be called with the lock held
+func (l *Ledger) previousPosting(idempotencyKey, accountID, kind string, amount contracts.Money) (Entry, bool, error) {
+	if idempotencyKey == "" {
+		return Entry{}, false, nil
+	}
+	entry, ok := l.postings[idempotencyKey]
+	if !ok {
+		return Entry{}, false, nil
+	}
+	if entry.AccountID != accountID || entry.Kind != kind || entry.Amount != amount {
+		return Entry{}, true, ErrIdempotencyKeyReused
+	}
+	return entry, true, nil
+}
+
+// must be called with the lock held
+func (l *Ledger) post(idempotencyKey, accountID, kind string, amount, newBalance contracts.Money, ref string) Entry {
+	l.balances[accountID] = newBalance
+	entry := Entry{
+		Time:           time.Now(),
+		AccountID:      accountID,
+		Kind:           kind,
+		Amount:         amount,
+		Balance:        newBalance,
+		Reference:      ref,
+		IdempotencyKey: idempotencyKey,
+	}
+	l.journal = append(l.journal, entry)
+	if idempotencyKey != "" {
+		l.postings[idempotencyKey] = entry
+	}
+	return entry
+}
+
+// OpenAccounts opens accounts from a spec of the form "12345:1000,67890:250.50:EUR". Accounts without a currency
+// are opened in currency.
+func (l *Ledger) OpenAccounts(spec, currency string) error {
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		parts := strings.Split(item, ":")
+		if len(parts) < 2 || len(parts) > 3 {
+			return fmt.Errorf("bad account spec %q: expected ID:BALANCE[:CURRENCY]", item)
+		}
+		id, accountCurrency := parts[0], currency
+		if len(parts) == 3 {
+			accountCurrency = strings.ToUpper(strings.TrimSpace(parts[2]))
+		}
+		balance, err := contracts.ParseMoney(strings.TrimSpace(parts[1]), accountCurrency)
+		if err != nil {
+			return fmt.Errorf("bad balance for account %s: %w", id, err)
+		}
+		if err := l.Open(strings.TrimSpace(id), balance); err != nil {
+			return fmt.Errorf("unable to open account %s: %w", id, err)
+		}
+	}
+	return nil
+}
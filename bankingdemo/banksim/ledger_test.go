@@ -0,0 +1,156 @@
+package banksim
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/arunsworld/temporal-demo/bankingdemo/contracts"
+)
+
+func usd(minor int64) contracts.Money {
+	return contracts.Money{Minor: minor, Currency: "USD"}
+}
+
+func newTestLedger(t *testing.T) *Ledger {
+	t.Helper()
+	l := NewLedger()
+	if err := l.OpenAccounts("12345:100,67890:0", "USD"); err != nil {
+		t.Fatal(err)
+	}
+	return l
+}
+
+func TestPostingsAreIdempotent(t *testing.T) {
+	tests := []struct {
+		name        string
+		post        func(l *Ledger) (Entry, error)
+		account     string
+		wantBalance contracts.Money
+	}{
+		{
+			name: "debit",
+			post: func(l *Ledger) (Entry, error) {
+				return l.Debit("key-1", "12345", usd(2500), "ref-1")
+			},
+			account:     "12345",
+			wantBalance: usd(7500),
+		},
+		{
+			name: "credit",
+			post: func(l *Ledger) (Entry, error) {
+				return l.Credit("key-1", "67890", usd(2500), "ref-1", false)
+			},
+			account:     "67890",
+			wantBalance: usd(2500),
+		},
+		{
+			name: "refund",
+			post: func(l *Ledger) (Entry, error) {
+				return l.Credit("key-1", "12345", usd(2500), "ref-1", true)
+			},
+			account:     "12345",
+			wantBalance: usd(12500),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := newTestLedger(t)
+			first, err := tt.post(l)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i := 0; i < 100; i++ {
+				entry, err := tt.post(l)
+				if err != nil {
+					t.Fatalf("replay %d: %v", i, err)
+				}
+				if entry != first {
+					t.Fatalf("replay %d returned %+v, want the original %+v", i, entry, first)
+				}
+			}
+			if journal := l.Journal(""); len(journal) != 1 {
+				t.Fatalf("got %d journal entries, want 1", len(journal))
+			}
+			balance, err := l.Balance(tt.account)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if balance != tt.wantBalance {
+				t.Fatalf("balance is %s, want %s", balance, tt.wantBalance)
+			}
+		})
+	}
+}
+
+func TestIdempotencyKeyReused(t *testing.T) {
+	tests := []struct {
+		name string
+		post func(l *Ledger) (Entry, error)
+	}{
+		{
+			name: "different amount",
+			post: func(l *Ledger) (Entry, error) {
+				return l.Debit("key-1", "12345", usd(2600), "ref-1")
+			},
+		},
+		{
+			name: "different currency",
+			post: func(l *Ledger) (Entry, error) {
+				return l.Debit("key-1", "12345", contracts.Money{Minor: 2500, Currency: "EUR"}, "ref-1")
+			},
+		},
+		{
+			name: "different account",
+			post: func(l *Ledger) (Entry, error) {
+				return l.Debit("key-1", "67890", usd(2500), "ref-1")
+			},
+		},
+		{
+			name: "different kind",
+			post: func(l *Ledger) (Entry, error) {
+				return l.Credit("key-1", "12345", usd(2500), "ref-1", false)
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := newTestLedger(t)
+			if _, err := l.Debit("key-1", "12345", usd(2500), "ref-1"); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := tt.post(l); !errors.Is(err, ErrIdempotencyKeyReused) {
+				t.Fatalf("got %v, want ErrIdempotencyKeyReused", err)
+			}
+			if journal := l.Journal(""); len(journal) != 1 {
+				t.Fatalf("got %d journal entries, want 1", len(journal))
+			}
+			if balance, _ := l.Balance("12345"); balance != usd(7500) {
+				t.Fatalf("balance is %s, want %s", balance, usd(7500))
+			}
+		})
+	}
+}
+
+func TestFailedPostingCanBeRetried(t *testing.T) {
+	l := newTestLedger(t)
+	if _, err := l.Debit("key-1", "67890", usd(2500), "ref-1"); !errors.Is(err, ErrInsufficientFunds) {
+		t.Fatalf("got %v, want ErrInsufficientFunds", err)
+	}
+	if journal := l.Journal(""); len(journal) != 0 {
+		t.Fatalf("got %d journal entries after a failed posting, want 0", len(journal))
+	}
+	if _, err := l.Credit("", "67890", usd(5000), "top-up", false); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		if _, err := l.Debit("key-1", "67890", usd(2500), "ref-1"); err != nil {
+			t.Fatalf("attempt %d: %v", i, err)
+		}
+	}
+	if journal := l.Journal("67890"); len(journal) != 2 {
+		t.Fatalf("got %d journal entries, want the top-up and one debit", len(journal))
+	}
+	if balance, _ := l.Balance("67890"); balance != usd(2500) {
+		t.Fatalf("balance is %s, want %s", balance, usd(2500))
+	}
+}
@@ -0,0 +1,124 @@
+// Package calendar knows which days are business days. Future-dated transfers fall due on one; the clearing
+// house waits for it and the customer service shows it.
+package calendar
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	// the calendar's time zone must load wherever the workflow replays
+	_ "time/tzdata"
+)
+
+const dateLayout = "2006-01-02"
+
+var defaultWeekend = []string{"Saturday", "Sunday"}
+
+// searchDays is how far ahead NextBusinessTime looks: a year of holidays
+const searchDays = 366
+
+var ErrNoBusinessDay = errors.New("no business day within a year")
+
+// Calendar is a business-day calendar. The zero value has no weekend or holidays and is in UTC.
+type Calendar struct {
+	Timezone string   `json:"timezone,omitempty"` // IANA name such as Europe/London
+	Weekend  []string `json:"weekend,omitempty"`  // day names
+	Holidays []string `json:"holidays,omitempty"` // YYYY-MM-DD in Timezone
+}
+
+// Load reads a JSON encoded Calendar. No path, or a file without a weekend, means Saturday and Sunday are not
+// business days; "weekend": [] turns that off.
+func Load(path string) (Calendar, error) {
+	result := Calendar{}
+	if path != "" {
+		contents, err := os.ReadFile(path)
+		if err != nil {
+			return Calendar{}, err
+		}
+		if err := json.Unmarshal(contents, &result); err != nil {
+			return Calendar{}, fmt.Errorf("bad calendar %s: %w", path, err)
+		}
+	}
+	if result.Weekend == nil {
+		result.Weekend = defaultWeekend
+	}
+	if err := result.Validate(); err != nil {
+		return Calendar{}, fmt.Errorf("bad calendar %s: %w", path, err)
+	}
+	return result, nil
+}
+
+func (c Calendar) Validate() error {
+	if _, err := time.LoadLocation(c.Timezone); err != nil {
+		return fmt.Errorf("timezone: %w", err)
+	}
+	weekend := map[time.Weekday]bool{}
+	for _, name := range c.Weekend {
+		day, ok := weekday(name)
+		if !ok {
+			return fmt.Errorf("weekend: %q is not a day", name)
+		}
+		weekend[day] = true
+	}
+	if len(weekend) == 7 {
+		return fmt.Errorf("weekend: every day is a weekend day")
+	}
+	for _, holiday := range c.Holidays {
+		if _, err := time.Parse(dateLayout, holiday); err != nil {
+			return fmt.Errorf("holiday %q is not YYYY-MM-DD", holiday)
+		}
+	}
+	return nil
+}
+
+// Location is the calendar's time zone, UTC when it cannot be loaded
+func (c Calendar) Location() *time.Location {
+	loc, err := time.LoadLocation(c.Timezone)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+// IsBusinessDay reports whether the day t falls on, in the calendar's time zone, is a business day
+func (c Calendar) IsBusinessDay(t time.Time) bool {
+	local := t.In(c.Location())
+	for _, name := range c.Weekend {
+		if day, ok := weekday(name); ok && day == local.Weekday() {
+			return false
+		}
+	}
+	date := local.Format(dateLayout)
+	for _, holiday := range c.Holidays {
+		if holiday == date {
+			return false
+		}
+	}
+	return true
+}
+
+// NextBusinessTime is t if it falls on a business day, otherwise the same time of day on the next business day.
+// It is ErrNoBusinessDay when there is none in the year after t.
+func (c Calendar) NextBusinessTime(t time.Time) (time.Time, error) {
+	result := t.In(c.Location())
+	for i := 0; i <= searchDays; i++ {
+		if c.IsBusinessDay(result) {
+			return result, nil
+		}
+		result = result.AddDate(0, 0, 1)
+	}
+	return time.Time{}, fmt.Errorf("%w of %s", ErrNoBusinessDay, t.In(c.Location()).Format(dateLayout))
+}
+
+func weekday(name string) (time.Weekday, bool) {
+	for day := time.Sunday; day <= time.Saturday; day++ {
+		if strings.EqualFold(day.String(), name) {
+			return day, true
+		}
+	}
+	return 0, false
+}
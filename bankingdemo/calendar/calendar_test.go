@@ -0,0 +1,157 @@
+package calendar
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// holidays lists n consecutive dates from the one from falls on
+func holidays(from time.Time, n int) []string {
+	var result []string
+	for i := 0; i < n; i++ {
+		result = append(result, from.AddDate(0, 0, i).Format(dateLayout))
+	}
+	return result
+}
+
+func TestNextBusinessTime(t *testing.T) {
+	london, err := time.LoadLocation("Europe/London")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tokyo, err := time.LoadLocation("Asia/Tokyo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	thursday := time.Date(2026, 12, 24, 9, 0, 0, 0, time.UTC)
+	saturday := time.Date(2026, 12, 26, 9, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name     string
+		calendar Calendar
+		t        time.Time
+		want     time.Time
+		wantErr  bool
+	}{
+		{
+			name: "zero calendar",
+			t:    saturday,
+			want: saturday,
+		},
+		{
+			name:     "business day",
+			calendar: Calendar{Weekend: defaultWeekend},
+			t:        thursday,
+			want:     thursday,
+		},
+		{
+			name:     "weekend moves to Monday",
+			calendar: Calendar{Weekend: defaultWeekend},
+			t:        saturday,
+			want:     time.Date(2026, 12, 28, 9, 0, 0, 0, time.UTC),
+		},
+		{
+			name:     "custom weekend",
+			calendar: Calendar{Weekend: []string{"friday", "saturday"}},
+			t:        time.Date(2026, 12, 25, 9, 0, 0, 0, time.UTC),
+			want:     time.Date(2026, 12, 27, 9, 0, 0, 0, time.UTC),
+		},
+		{
+			name:     "holiday before a weekend",
+			calendar: Calendar{Weekend: defaultWeekend, Holidays: []string{"2026-12-25"}},
+			t:        time.Date(2026, 12, 25, 9, 0, 0, 0, time.UTC),
+			want:     time.Date(2026, 12, 28, 9, 0, 0, 0, time.UTC),
+		},
+		{
+			name:     "holidays are dates in the calendar's time zone",
+			calendar: Calendar{Timezone: "Asia/Tokyo", Weekend: defaultWeekend, Holidays: []string{"2026-12-25"}},
+			// still the 24th in UTC, already Christmas in Tokyo
+			t:    time.Date(2026, 12, 24, 23, 30, 0, 0, time.UTC),
+			want: time.Date(2026, 12, 28, 8, 30, 0, 0, tokyo),
+		},
+		{
+			name:     "weekend in the calendar's time zone",
+			calendar: Calendar{Timezone: "Asia/Tokyo", Weekend: defaultWeekend},
+			// Friday in UTC, Saturday in Tokyo
+			t:    time.Date(2026, 12, 25, 20, 0, 0, 0, time.UTC),
+			want: time.Date(2026, 12, 28, 5, 0, 0, 0, tokyo),
+		},
+		{
+			name:     "same time of day across the start of summer time",
+			calendar: Calendar{Timezone: "Europe/London", Weekend: defaultWeekend},
+			// clocks go forward on Sunday 29 March 2026
+			t:    time.Date(2026, 3, 28, 9, 0, 0, 0, london),
+			want: time.Date(2026, 3, 30, 9, 0, 0, 0, london),
+		},
+		{
+			name:     "same time of day across the end of summer time",
+			calendar: Calendar{Timezone: "Europe/London", Weekend: defaultWeekend},
+			// clocks go back on Sunday 25 October 2026
+			t:    time.Date(2026, 10, 24, 9, 0, 0, 0, london),
+			want: time.Date(2026, 10, 26, 9, 0, 0, 0, london),
+		},
+		{
+			name:     "business day at the end of the search",
+			calendar: Calendar{Holidays: holidays(thursday, searchDays)},
+			t:        thursday,
+			want:     thursday.AddDate(0, 0, searchDays),
+		},
+		{
+			name:     "no business day within the search",
+			calendar: Calendar{Holidays: holidays(thursday, searchDays+1)},
+			t:        thursday,
+			wantErr:  true,
+		},
+		{
+			name:     "every day a holiday",
+			calendar: Calendar{Timezone: "Europe/London", Weekend: defaultWeekend, Holidays: holidays(thursday, 3*365)},
+			t:        thursday,
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.calendar.Validate(); err != nil {
+				t.Fatal(err)
+			}
+			got, err := tt.calendar.NextBusinessTime(tt.t)
+			if tt.wantErr {
+				if !errors.Is(err, ErrNoBusinessDay) {
+					t.Fatalf("got %v, %v; want ErrNoBusinessDay", got, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !got.Equal(tt.want) {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+			if got.Location().String() != tt.calendar.Location().String() {
+				t.Fatalf("got %v in %v, want it in %v", got, got.Location(), tt.calendar.Location())
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name     string
+		calendar Calendar
+		wantErr  bool
+	}{
+		{name: "embedded time zone", calendar: Calendar{Timezone: "America/New_York"}},
+		{name: "unknown time zone", calendar: Calendar{Timezone: "Mars/Olympus_Mons"}, wantErr: true},
+		{name: "unknown weekend day", calendar: Calendar{Weekend: []string{"Caturday"}}, wantErr: true},
+		{name: "every day a weekend", calendar: Calendar{Weekend: []string{"monday", "tuesday", "wednesday", "thursday",
+			"friday", "saturday", "sunday"}}, wantErr: true},
+		{name: "bad holiday", calendar: Calendar{Holidays: []string{"25/12/2026"}}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.calendar.Validate(); tt.wantErr != (err != nil) {
+				t.Fatalf("got %v, want an error: %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/arunsworld/temporal-demo/bankingdemo/calendar"
+	"github.com/arunsworld/temporal-demo/bankingdemo/contracts"
+	"github.com/arunsworld/temporal-demo/bankingdemo/fees"
+	"go.temporal.io/sdk/workflow"
+)
+
+/*
+Relies on:
+  - REFUND_RETRY_PERIOD: how long a failing refund is retried before an operator is needed (default 30m)
+  - REFUND_RETRY_INITIAL_INTERVAL: first backoff between refund attempts (default 10s)
+  - REFUND_RETRY_MAX_INTERVAL: cap on the backoff between refund attempts (default 5m)
+  - AML_REVIEW_MODE: activity (the money laundering service holds reviews) or signal (transfers hold them and
+    reviewers send signals), see the AMLReviewMode constants (default activity)
+  - AML_REVIEW_REMINDER_INTERVAL: how often reviewers are reminded of a waiting AML review (default 4h, 0 disables)
+  - AML_REVIEW_ESCALATE_AFTER: when a waiting AML review moves to the senior reviewers (default 24h, 0 disables)
+  - AML_REVIEW_EXPIRE_AFTER: when a waiting AML review is given up on (default 120h)
+  - AML_REVIEW_HEARTBEAT_TIMEOUT: how long a waiting AML review may go without a heartbeat from the money laundering
+    service before it is retried (default 2m)
+  - AML_REVIEW_EXPIRY_OUTCOME: decision applied on expiry, reject or approve (default reject)
+  - FEE_SCHEDULE_FILE: JSON encoded fees.Schedule transfers are charged by (default none: transfers are free)
+  - BUSINESS_CALENDAR_FILE: JSON encoded calendar.Calendar future-dated transfers fall due on business days of
+    (default UTC, Monday to Friday)
+  - STANDING_ORDER_MAX_FAILURES: transfers in a row that may fail before a standing order pauses itself (default 3)
+*/
+
+type workflowConfig struct {
+	RefundRetryPeriod          time.Duration
+	RefundRetryInitialInterval time.Duration
+	RefundRetryMaxInterval     time.Duration
+	AMLReviewMode              string
+	AMLReviewReminderInterval  time.Duration
+	AMLReviewEscalateAfter     time.Duration
+	AMLReviewExpireAfter       time.Duration
+	AMLReviewHeartbeatTimeout  time.Duration
+	AMLReviewExpiryOutcome     string
+	Fees                       fees.Schedule
+	Calendar                   calendar.Calendar
+	StandingOrderMaxFailures   int
+}
+
+var config = workflowConfig{
+	RefundRetryPeriod:          time.Minute * 30,
+	RefundRetryInitialInterval: time.Second * 10,
+	RefundRetryMaxInterval:     time.Minute * 5,
+	AMLReviewMode:              contracts.AMLReviewModeActivity,
+	AMLReviewReminderInterval:  time.Hour * 4,
+	AMLReviewEscalateAfter:     time.Hour * 24,
+	AMLReviewExpireAfter:       time.Hour * 24 * 5,
+	AMLReviewHeartbeatTimeout:  time.Minute * 2,
+	AMLReviewExpiryOutcome:     contracts.MoneyLaunderingReject,
+	StandingOrderMaxFailures:   3,
+}
+
+func loadConfig() error {
+	durations := map[string]*time.Duration{
+		"REFUND_RETRY_PERIOD":           &config.RefundRetryPeriod,
+		"REFUND_RETRY_INITIAL_INTERVAL": &config.RefundRetryInitialInterval,
+		"REFUND_RETRY_MAX_INTERVAL":     &config.RefundRetryMaxInterval,
+		"AML_REVIEW_REMINDER_INTERVAL":  &config.AMLReviewReminderInterval,
+		"AML_REVIEW_ESCALATE_AFTER":     &config.AMLReviewEscalateAfter,
+		"AML_REVIEW_EXPIRE_AFTER":       &config.AMLReviewExpireAfter,
+		"AML_REVIEW_HEARTBEAT_TIMEOUT":  &config.AMLReviewHeartbeatTimeout,
+	}
+	for name, target := range durations {
+		v := os.Getenv(name)
+		if v == "" {
+			continue
+		}
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("bad %s: %w", name, err)
+		}
+		*target = d
+	}
+	if config.AMLReviewExpireAfter <= 0 {
+		return fmt.Errorf("bad AML_REVIEW_EXPIRE_AFTER: must be positive")
+	}
+	if v := os.Getenv("AML_REVIEW_MODE"); v != "" {
+		if v != contracts.AMLReviewModeActivity && v != contracts.AMLReviewModeSignal {
+			return fmt.Errorf("bad AML_REVIEW_MODE: %q", v)
+		}
+		config.AMLReviewMode = v
+	}
+	if v := os.Getenv("AML_REVIEW_EXPIRY_OUTCOME"); v != "" {
+		if v != contracts.MoneyLaunderingApprove && v != contracts.MoneyLaunderingReject {
+			return fmt.Errorf("bad AML_REVIEW_EXPIRY_OUTCOME: %q", v)
+		}
+		config.AMLReviewExpiryOutcome = v
+	}
+	schedule, err := fees.Load(os.Getenv("FEE_SCHEDULE_FILE"))
+	if err != nil {
+		return err
+	}
+	config.Fees = schedule
+	cal, err := calendar.Load(os.Getenv("BUSINESS_CALENDAR_FILE"))
+	if err != nil {
+		return err
+	}
+	config.Calendar = cal
+	if v := os.Getenv("STANDING_ORDER_MAX_FAILURES"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return fmt.Errorf("bad STANDING_ORDER_MAX_FAILURES: %q", v)
+		}
+		config.StandingOrderMaxFailures = n
+	}
+	return nil
+}
+
+// The settings a workflow uses are recorded in its history once per run, so that a replay on a worker with
+// different settings still takes the same decisions. Only what the workflow needs is recorded; the fee schedule
+// and calendar are consulted through recordedFee and recordedDueTime instead.
+
+// transferConfig is the part of the configuration a MoneyTransfer uses
+type transferConfig struct {
+	RefundRetryPeriod          time.Duration
+	RefundRetryInitialInterval time.Duration
+	RefundRetryMaxInterval     time.Duration
+	AMLReviewMode              string
+	AMLReviewReminderInterval  time.Duration
+	AMLReviewEscalateAfter     time.Duration
+	AMLReviewExpireAfter       time.Duration
+	AMLReviewHeartbeatTimeout  time.Duration
+	AMLReviewExpiryOutcome     string
+}
+
+func recordTransferConfig(ctx workflow.Context) (transferConfig, error) {
+	var result transferConfig
+	err := workflow.SideEffect(ctx, func(workflow.Context) interface{} {
+		return transferConfig{
+			RefundRetryPeriod:          config.RefundRetryPeriod,
+			RefundRetryInitialInterval: config.RefundRetryInitialInterval,
+			RefundRetryMaxInterval:     config.RefundRetryMaxInterval,
+			AMLReviewMode:              config.AMLReviewMode,
+			AMLReviewReminderInterval:  config.AMLReviewReminderInterval,
+			AMLReviewEscalateAfter:     config.AMLReviewEscalateAfter,
+			AMLReviewExpireAfter:       config.AMLReviewExpireAfter,
+			AMLReviewHeartbeatTimeout:  config.AMLReviewHeartbeatTimeout,
+			AMLReviewExpiryOutcome:     config.AMLReviewExpiryOutcome,
+		}
+	}).Get(&result)
+	return result, err
+}
+
+// standingOrderConfig is the part of the configuration a StandingOrder uses
+type standingOrderConfig struct {
+	Timezone    string // of the business calendar, which occurrences are due in
+	MaxFailures int
+}
+
+func recordStandingOrderConfig(ctx workflow.Context) (standingOrderConfig, error) {
+	var result standingOrderConfig
+	err := workflow.SideEffect(ctx, func(workflow.Context) interface{} {
+		return standingOrderConfig{Timezone: config.Calendar.Timezone, MaxFailures: config.StandingOrderMaxFailures}
+	}).Get(&result)
+	return result, err
+}
+
+// Location is the time zone occurrences are due in
+func (c standingOrderConfig) Location() *time.Location {
+	return calendar.Calendar{Timezone: c.Timezone}.Location()
+}
+
+type feeResult struct {
+	Quote contracts.FeeQuote
+	Error string
+}
+
+// recordedFee prices req by the fee schedule, recording only the quote
+func recordedFee(ctx workflow.Context, req contracts.Request) (contracts.FeeQuote, error) {
+	var result feeResult
+	if err := workflow.SideEffect(ctx, func(workflow.Context) interface{} {
+		quote, err := config.Fees.Quote(req)
+		if err != nil {
+			return feeResult{Error: err.Error()}
+		}
+		return feeResult{Quote: quote}
+	}).Get(&result); err != nil {
+		return contracts.FeeQuote{}, err
+	}
+	if result.Error != "" {
+		return contracts.FeeQuote{}, errors.New(result.Error)
+	}
+	return result.Quote, nil
+}
+
+type dueTimeResult struct {
+	Due           time.Time
+	NoBusinessDay bool
+}
+
+// recordedDueTime is when a transfer executing at t falls due by the business calendar, recording only the
+// answer. It is calendar.ErrNoBusinessDay when the calendar has none.
+func recordedDueTime(ctx workflow.Context, t time.Time) (time.Time, error) {
+	var result dueTimeResult
+	if err := workflow.SideEffect(ctx, func(workflow.Context) interface{} {
+		due, err := config.Calendar.NextBusinessTime(t)
+		return dueTimeResult{Due: due, NoBusinessDay: err != nil}
+	}).Get(&result); err != nil {
+		return time.Time{}, err
+	}
+	if result.NoBusinessDay {
+		return time.Time{}, calendar.ErrNoBusinessDay
+	}
+	return result.Due, nil
+}
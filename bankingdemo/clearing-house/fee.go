@@ -0,0 +1,52 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/arunsworld/temporal-demo/bankingdemo/contracts"
+	"go.temporal.io/sdk/workflow"
+)
+
+// priceTransfer charges req by the fee schedule, recording a fee in progress. It answers the failure reason of a
+// transfer that cannot be priced or would cost more than the customer accepted.
+func priceTransfer(ctx workflow.Context, req contracts.Request, progress *contracts.Progress) string {
+	fee, err := recordedFee(ctx, req)
+	if err != nil {
+		workflow.GetLogger(ctx).Error("unable to price transfer", "Ref", req.Ref, "Error", err)
+		return "Unable to price the transfer"
+	}
+	if accepted := req.AcceptedFee; accepted != nil && (accepted.Currency != fee.Total.Currency || accepted.Minor < fee.Total.Minor) {
+		return fmt.Sprintf("Fee of %s is more than the %s accepted", fee.Total, accepted)
+	}
+	if !fee.Total.IsZero() {
+		progress.Fee = &fee
+	}
+	return ""
+}
+
+// withdrawalAmount is what is taken from the source account: the amount plus any fee
+func withdrawalAmount(req contracts.Request, progress *contracts.Progress) contracts.Money {
+	if progress.Fee == nil {
+		return req.Amount
+	}
+	return contracts.Money{Minor: req.Amount.Minor + progress.Fee.Total.Minor, Currency: req.Amount.Currency}
+}
+
+// collectFee credits the fee to the clearing house's revenue account. The transfer has completed by then, so a
+// rejected credit is only reported, for the fee to be reconciled by hand.
+func collectFee(ctx workflow.Context, req contracts.Request, fee contracts.FeeQuote) (string, error) {
+	txn := contracts.BankTransaction{
+		AccountID: fee.RevenueAccount,
+		Amount:    fee.Total,
+		Reference: fmt.Sprintf("FEE: %s", req.Ref),
+	}
+	resp, err := contracts.Deposit(ctx, fee.RevenueBank, txn)
+	if err != nil {
+		return "", err
+	}
+	if resp.Status != contracts.TxnSuccess {
+		workflow.GetLogger(ctx).Error("fee not credited to the revenue account", "Ref", req.Ref, "Reason", resp.FailureReason)
+		return resp.FailureReason, nil
+	}
+	return "", nil
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"errors"
+	"time"
+
+	"github.com/arunsworld/temporal-demo/bankingdemo/contracts"
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/workflow"
+)
+
+// maxFXLockAttempts bounds how often an expired quote is replaced before the withdrawal
+const maxFXLockAttempts = 3
+
+var errFXQuoteExpired = errors.New("FX quote expired before the withdrawal")
+
+// withReferenceAmount sets the amount of req in the FX service's reference currency, which AML thresholds are
+// evaluated in. Transfers without currencies are left alone.
+func withReferenceAmount(ctx workflow.Context, req contracts.Request) (contracts.Request, error) {
+	if req.Amount.Currency == "" {
+		return req, nil
+	}
+	fxctx := workflow.WithActivityOptions(ctx, workflow.ActivityOptions{
+		StartToCloseTimeout: time.Minute,
+	})
+	quote, err := contracts.IndicativeFXRate(fxctx, contracts.FXQuoteRequest{
+		Amount: req.Amount,
+		Ref:    req.Ref,
+	})
+	if err != nil {
+		return req, err
+	}
+	req.ReferenceAmount = quote.ConvertedAmount
+	return req, nil
+}
+
+// lockFXRate locks the rate the destination amount is converted at. A quote that has expired by the time the
+// workflow gets to use it is replaced.
+func lockFXRate(ctx workflow.Context, req contracts.Request) (contracts.FXQuote, error) {
+	fxctx := workflow.WithActivityOptions(ctx, workflow.ActivityOptions{
+		StartToCloseTimeout: time.Minute,
+	})
+	for attempt := 1; attempt <= maxFXLockAttempts; attempt++ {
+		quote, err := contracts.LockFXRate(fxctx, contracts.FXQuoteRequest{
+			Amount: req.Amount,
+			To:     req.DestinationCurrency,
+			Ref:    req.Ref,
+		})
+		if err != nil {
+			return contracts.FXQuote{}, err
+		}
+		if workflow.Now(ctx).Before(quote.ExpiresAt) {
+			return quote, nil
+		}
+		workflow.GetLogger(ctx).Warn("FX quote expired, locking a new one", "Ref", req.Ref, "Quote", quote.ID)
+	}
+	return contracts.FXQuote{}, errFXQuoteExpired
+}
+
+func isUnknownCurrency(err error) bool {
+	var appErr *temporal.ApplicationError
+	return errors.As(err, &appErr) && appErr.Type() == contracts.FXUnknownCurrencyError
+}
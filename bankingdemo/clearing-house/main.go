@@ -7,8 +7,10 @@ import (
 	"os/signal"
 	"syscall"
 
+	"github.com/arunsworld/temporal-demo/bankingdemo/contracts"
 	temporalgolibs "github.com/arunsworld/temporal-demo/temporal-golibs"
 	"go.temporal.io/sdk/worker"
+	"go.temporal.io/sdk/workflow"
 )
 
 func main() {
@@ -18,6 +20,10 @@ func main() {
 }
 
 func run() error {
+	if err := loadConfig(); err != nil {
+		return err
+	}
+
 	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer cancel()
 
@@ -27,9 +33,14 @@ func run() error {
 	}
 	defer c.Close()
 
-	w := worker.New(c, "clearing-house", worker.Options{})
+	w := worker.New(c, contracts.ClearingHouseTaskQueue, worker.Options{})
 
-	w.RegisterWorkflow(MoneyTransfer)
+	w.RegisterWorkflowWithOptions(MoneyTransfer, workflow.RegisterOptions{
+		Name: contracts.MoneyTransferWorkflow,
+	})
+	w.RegisterWorkflowWithOptions(StandingOrder, workflow.RegisterOptions{
+		Name: contracts.StandingOrderWorkflow,
+	})
 
 	return w.Run(worker.InterruptCh())
 }
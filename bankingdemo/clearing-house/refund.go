@@ -0,0 +1,102 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/arunsworld/temporal-demo/bankingdemo/contracts"
+	"go.temporal.io/sdk/workflow"
+)
+
+type refundOutcome struct {
+	status        string
+	failureReason string
+	attempts      int
+	resolution    *contracts.RefundResolution
+}
+
+// refund returns the withdrawn money, fee included, to the source account after a failed deposit. A refund the bank rejects
+// is retried with backoff for the configured period, after which the transfer is parked until an operator
+// resolves it with a RefundResolutionSignal.
+func refund(ctx workflow.Context, cfg transferConfig, req contracts.Request, progress *contracts.Progress, depositFailure string) (refundOutcome, error) {
+	actx := workflow.WithActivityOptions(ctx, workflow.ActivityOptions{
+		StartToCloseTimeout: time.Minute,
+	})
+	txn := contracts.BankTransaction{
+		AccountID: req.SourceAcc,
+		Amount:    withdrawalAmount(req, progress),
+		Reference: fmt.Sprintf("REFUND: %s", req.Ref),
+		IsRefund:  true,
+	}
+
+	setStage(ctx, progress, contracts.StageRefunding)
+	result := refundOutcome{}
+	deadline := workflow.Now(ctx).Add(cfg.RefundRetryPeriod)
+	backoff := cfg.RefundRetryInitialInterval
+	var refundFailure string
+	for {
+		result.attempts++
+		progress.Attempts[contracts.StageRefunding] = result.attempts
+		resp, err := contracts.Deposit(actx, req.SourceBank, txn)
+		if err != nil {
+			return refundOutcome{}, err
+		}
+		if resp.Status == contracts.TxnSuccess {
+			return refunded(ctx, progress, result, depositFailure), nil
+		}
+		refundFailure = resp.FailureReason
+		progress.LastFailure = refundFailure
+		if workflow.Now(ctx).Add(backoff).After(deadline) {
+			break
+		}
+		workflow.GetLogger(ctx).Warn("refund failed, retrying", "Ref", req.Ref, "Reason", refundFailure, "Backoff", backoff)
+		if err := workflow.Sleep(ctx, backoff); err != nil {
+			return refundOutcome{}, err
+		}
+		backoff *= 2
+		if backoff > cfg.RefundRetryMaxInterval {
+			backoff = cfg.RefundRetryMaxInterval
+		}
+	}
+
+	workflow.GetLogger(ctx).Error("refund failed, awaiting manual intervention", "Ref", req.Ref, "Reason", refundFailure)
+	setStage(ctx, progress, contracts.StageAwaitingRefundResolution)
+	resolutions := workflow.GetSignalChannel(ctx, contracts.RefundResolutionSignal)
+	for {
+		var resolution contracts.RefundResolution
+		resolutions.Receive(ctx, &resolution)
+		result.resolution = &resolution
+		switch resolution.Action {
+		case contracts.RefundRetry:
+			result.attempts++
+			progress.Attempts[contracts.StageRefunding] = result.attempts
+			resp, err := contracts.Deposit(actx, req.SourceBank, txn)
+			if err != nil {
+				return refundOutcome{}, err
+			}
+			if resp.Status == contracts.TxnSuccess {
+				return refunded(ctx, progress, result, depositFailure), nil
+			}
+			refundFailure = resp.FailureReason
+			progress.LastFailure = refundFailure
+		case contracts.RefundForce:
+			result = refunded(ctx, progress, result, depositFailure)
+			result.failureReason = fmt.Sprintf("%s. Refund made manually by %s", depositFailure, resolution.Operator)
+			return result, nil
+		case contracts.RefundWriteOff:
+			result.status = contracts.StatusRefundFailed
+			result.failureReason = fmt.Sprintf("Deposit failed due to %s. Refund also failed: %s. Written off by %s",
+				depositFailure, refundFailure, resolution.Operator)
+			return result, nil
+		default:
+			workflow.GetLogger(ctx).Warn("ignoring unknown refund resolution", "Ref", req.Ref, "Action", resolution.Action)
+		}
+	}
+}
+
+func refunded(ctx workflow.Context, progress *contracts.Progress, result refundOutcome, depositFailure string) refundOutcome {
+	_ = workflow.SideEffect(ctx, currentTime).Get(&progress.RefundDoneTime)
+	result.status = contracts.StatusRefunded
+	result.failureReason = depositFailure
+	return result
+}
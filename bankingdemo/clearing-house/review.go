@@ -0,0 +1,264 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/arunsworld/temporal-demo/bankingdemo/contracts"
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/workflow"
+)
+
+const (
+	reviewSLAReviewer   = "review-sla"
+	reviewExpiredReason = "review-expired"
+	// ignored decisions kept in a review case for reviewers to see what became of theirs
+	ignoredDecisionsKept = 20
+)
+
+// moneyLaunderingReview waits for the money laundering decision while running the review SLA alongside it once a
+// reviewer is needed: reviewers are reminded every AMLReviewReminderInterval, the review moves to the senior
+// reviewers after AMLReviewEscalateAfter and, once AMLReviewExpireAfter has passed, the configured default outcome
+// is applied and the customer is told. Every SLA event is recorded in the progress.
+//
+// The decision comes from the MoneyLaunderingCheck activity or, in signal mode, from AMLDecisionSignals sent to
+// the transfer after the money laundering service's screening asked for a reviewer. The SLA starts with that
+// screening or, for the activity, with the money laundering service's AMLReviewOpenedSignal; transfers the rules
+// engine decides straight away never start it.
+func moneyLaunderingReview(ctx workflow.Context, cfg transferConfig, req contracts.Request, hits []contracts.SanctionsHit, progress *contracts.Progress) (contracts.AMLDecision, error) {
+	nctx := workflow.WithActivityOptions(ctx, workflow.ActivityOptions{
+		StartToCloseTimeout:    time.Minute,
+		ScheduleToCloseTimeout: time.Minute * 10,
+	})
+
+	checkCtx, cancelCheck := workflow.WithCancel(ctx)
+	defer cancelCheck()
+	var check workflow.Future
+	var reviewCase *contracts.AMLReviewCase
+	if cfg.AMLReviewMode == contracts.AMLReviewModeSignal {
+		sctx := workflow.WithActivityOptions(ctx, workflow.ActivityOptions{
+			StartToCloseTimeout: time.Minute,
+		})
+		screening, err := contracts.ScreenTransfer(sctx, req, hits)
+		if err != nil {
+			return contracts.AMLDecision{}, err
+		}
+		if screening.Decision != nil {
+			return *screening.Decision, nil
+		}
+		if check, reviewCase, err = awaitReviewSignals(checkCtx, req, hits, screening); err != nil {
+			return contracts.AMLDecision{}, err
+		}
+	} else {
+		checkCtx = workflow.WithActivityOptions(checkCtx, workflow.ActivityOptions{
+			// the expiry timer ends the review before the activity would time out
+			StartToCloseTimeout: cfg.AMLReviewExpireAfter + time.Hour,
+			// the money laundering service heartbeats pending reviews; a missed heartbeat retries the activity,
+			// which reattaches to the review
+			HeartbeatTimeout: cfg.AMLReviewHeartbeatTimeout,
+		})
+		var settle workflow.Settable
+		check, settle = workflow.NewFuture(ctx)
+		workflow.Go(checkCtx, func(gctx workflow.Context) {
+			settle.Set(contracts.MoneyLaunderingCheck(gctx, req, hits))
+		})
+	}
+
+	timerCtx, cancelTimers := workflow.WithCancel(ctx)
+	defer cancelTimers()
+
+	notify := func(activity, event string, reminder int, outcome string) {
+		e := contracts.AMLEscalation{Event: event, At: workflow.Now(ctx), Reminder: reminder}
+		progress.AMLEscalations = append(progress.AMLEscalations, e)
+		if reviewCase != nil {
+			switch event {
+			case contracts.AMLReviewReminded:
+				reviewCase.Reminders = reminder
+			case contracts.AMLReviewEscalated:
+				reviewCase.Escalated = true
+				upsertReviewStatus(ctx, contracts.AMLReviewStatusEscalated)
+			}
+		}
+		notice := contracts.AMLReviewNotice{Ref: req.Ref, Event: e, Outcome: outcome}
+		if err := contracts.NotifyAMLReview(nctx, activity, notice); err != nil {
+			workflow.GetLogger(ctx).Warn("unable to notify the money laundering service", "Ref", req.Ref, "Event", event, "Error", err)
+		}
+	}
+
+	var decision contracts.AMLDecision
+	var checkErr error
+	decided, expired := false, false
+	selector := workflow.NewSelector(ctx)
+	selector.AddFuture(check, func(f workflow.Future) {
+		decided = true
+		checkErr = f.Get(ctx, &decision)
+	})
+	startSLA := func() {
+		if cfg.AMLReviewReminderInterval > 0 {
+			reminders := 0
+			var remind func(workflow.Future)
+			remind = func(f workflow.Future) {
+				if f.Get(ctx, nil) != nil {
+					return
+				}
+				reminders++
+				notify(contracts.AMLReviewReminderActivity, contracts.AMLReviewReminded, reminders, "")
+				selector.AddFuture(workflow.NewTimer(timerCtx, cfg.AMLReviewReminderInterval), remind)
+			}
+			selector.AddFuture(workflow.NewTimer(timerCtx, cfg.AMLReviewReminderInterval), remind)
+		}
+		if cfg.AMLReviewEscalateAfter > 0 && cfg.AMLReviewEscalateAfter < cfg.AMLReviewExpireAfter {
+			selector.AddFuture(workflow.NewTimer(timerCtx, cfg.AMLReviewEscalateAfter), func(f workflow.Future) {
+				if f.Get(ctx, nil) == nil {
+					notify(contracts.EscalateAMLReviewActivity, contracts.AMLReviewEscalated, 0, "")
+				}
+			})
+		}
+		selector.AddFuture(workflow.NewTimer(timerCtx, cfg.AMLReviewExpireAfter), func(f workflow.Future) {
+			expired = f.Get(ctx, nil) == nil
+		})
+	}
+	if reviewCase != nil {
+		startSLA()
+	} else {
+		opened := false
+		selector.AddReceive(workflow.GetSignalChannel(ctx, contracts.AMLReviewOpenedSignal), func(c workflow.ReceiveChannel, _ bool) {
+			c.Receive(ctx, nil)
+			// a retried activity tells again
+			if !opened {
+				opened = true
+				startSLA()
+			}
+		})
+	}
+	for !decided && !expired {
+		selector.Select(ctx)
+	}
+	if decided {
+		if reviewCase != nil && checkErr == nil {
+			upsertReviewStatus(ctx, contracts.AMLReviewStatusDecided)
+		}
+		return decision, checkErr
+	}
+
+	cancelCheck()
+	if reviewCase != nil {
+		reviewCase.Open = false
+		upsertReviewStatus(ctx, contracts.AMLReviewStatusExpired)
+	}
+	notify(contracts.ExpireAMLReviewActivity, contracts.AMLReviewExpired, 0, cfg.AMLReviewExpiryOutcome)
+	decision = contracts.AMLDecision{
+		Decision:   cfg.AMLReviewExpiryOutcome,
+		Reviewer:   reviewSLAReviewer,
+		ReasonCode: reviewExpiredReason,
+		Comment:    fmt.Sprintf("No decision within %v", cfg.AMLReviewExpireAfter),
+		DecidedAt:  workflow.Now(ctx),
+	}
+	message := fmt.Sprintf("We could not complete the checks on your transfer %s in time, so it has been cancelled. "+
+		"No money has left your account.", req.Ref)
+	if decision.Decision == contracts.MoneyLaunderingApprove {
+		message = fmt.Sprintf("The checks on your transfer %s took longer than expected; it is now going ahead.", req.Ref)
+	}
+	if err := contracts.NotifyCustomer(nctx, contracts.CustomerNotification{Ref: req.Ref, Message: message}); err != nil {
+		workflow.GetLogger(ctx).Warn("unable to notify the customer", "Ref", req.Ref, "Error", err)
+	}
+	return decision, nil
+}
+
+// awaitReviewSignals opens a review held by the transfer itself: the case is published through the AMLReviewQuery
+// and the AMLReviewStatus search attribute, and the returned future is settled with the decision once reviewers
+// have sent it as AMLDecisionSignals. Under dual control the first approval is collected and the second, from a
+// different reviewer, decides. Invalid decisions are ignored and listed in the case, so that whoever sent one can
+// be told.
+func awaitReviewSignals(ctx workflow.Context, req contracts.Request, hits []contracts.SanctionsHit, screening contracts.AMLScreening) (workflow.Future, *contracts.AMLReviewCase, error) {
+	reviewCase := &contracts.AMLReviewCase{
+		Request:       req,
+		SanctionsHits: hits,
+		Screening:     screening,
+		Since:         workflow.Now(ctx),
+		Open:          true,
+	}
+	if err := workflow.SetQueryHandler(ctx, contracts.AMLReviewQuery, func() (contracts.AMLReviewCase, error) {
+		return *reviewCase, nil
+	}); err != nil {
+		return nil, nil, err
+	}
+	upsertReviewStatus(ctx, contracts.AMLReviewStatusPending)
+
+	result, settle := workflow.NewFuture(ctx)
+	decisions := workflow.GetSignalChannel(ctx, contracts.AMLDecisionSignal)
+	workflow.Go(ctx, func(gctx workflow.Context) {
+		for {
+			var d contracts.AMLDecision
+			cancelled := false
+			selector := workflow.NewSelector(gctx)
+			selector.AddReceive(decisions, func(c workflow.ReceiveChannel, _ bool) {
+				c.Receive(gctx, &d)
+			})
+			selector.AddReceive(gctx.Done(), func(workflow.ReceiveChannel, bool) {
+				cancelled = true
+			})
+			selector.Select(gctx)
+			if cancelled {
+				settle.Set(nil, temporal.NewCanceledError())
+				return
+			}
+			if problem := invalidReviewDecision(reviewCase, d); problem != "" {
+				workflow.GetLogger(gctx).Warn("ignoring AML decision", "Ref", req.Ref, "Reviewer", d.Reviewer, "Problem", problem)
+				ignored := append(reviewCase.Ignored, contracts.IgnoredAMLDecision{Decision: d, Problem: problem})
+				if len(ignored) > ignoredDecisionsKept {
+					ignored = ignored[len(ignored)-ignoredDecisionsKept:]
+				}
+				reviewCase.Ignored = ignored
+				continue
+			}
+			d.RiskScore = screening.RiskScore
+			d.TriggeredRules = nil
+			for _, rule := range screening.TriggeredRules {
+				d.TriggeredRules = append(d.TriggeredRules, rule.Name)
+			}
+			if d.Decision == contracts.MoneyLaunderingApprove && screening.ApprovalsRequired > 1 {
+				reviewCase.Approvals = append(reviewCase.Approvals, d)
+				if len(reviewCase.Approvals) < screening.ApprovalsRequired {
+					continue
+				}
+				for _, a := range reviewCase.Approvals {
+					d.Approvers = append(d.Approvers, a.Reviewer)
+				}
+			}
+			reviewCase.Open = false
+			reviewCase.Decision = &d
+			settle.Set(d, nil)
+			return
+		}
+	})
+	return result, reviewCase, nil
+}
+
+func invalidReviewDecision(reviewCase *contracts.AMLReviewCase, d contracts.AMLDecision) string {
+	if !reviewCase.Open {
+		return "review is closed"
+	}
+	if d.Decision != contracts.MoneyLaunderingApprove && d.Decision != contracts.MoneyLaunderingReject {
+		return "unknown decision"
+	}
+	if d.Reviewer == "" {
+		return "missing reviewer"
+	}
+	if d.Decision == contracts.MoneyLaunderingApprove {
+		for _, a := range reviewCase.Approvals {
+			if a.Reviewer == d.Reviewer {
+				return "same reviewer cannot approve twice"
+			}
+		}
+	}
+	return ""
+}
+
+func upsertReviewStatus(ctx workflow.Context, status string) {
+	if err := workflow.UpsertSearchAttributes(ctx, map[string]interface{}{
+		contracts.AMLReviewStatusSearchAttribute: status,
+	}); err != nil {
+		workflow.GetLogger(ctx).Warn("unable to update the AML review status", "Status", status, "Error", err)
+	}
+}
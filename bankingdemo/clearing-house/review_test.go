@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/arunsworld/temporal-demo/bankingdemo/contracts"
+	"github.com/stretchr/testify/mock"
+	"go.temporal.io/sdk/activity"
+	"go.temporal.io/sdk/testsuite"
+	"go.temporal.io/sdk/workflow"
+)
+
+var testReviewConfig = transferConfig{
+	AMLReviewMode:             contracts.AMLReviewModeActivity,
+	AMLReviewReminderInterval: time.Hour * 4,
+	AMLReviewEscalateAfter:    time.Hour * 24,
+	AMLReviewExpireAfter:      time.Hour * 120,
+	AMLReviewHeartbeatTimeout: time.Minute * 2,
+	AMLReviewExpiryOutcome:    contracts.MoneyLaunderingReject,
+}
+
+func reviewWorkflow(ctx workflow.Context, req contracts.Request) ([]contracts.AMLEscalation, error) {
+	progress := &contracts.Progress{}
+	_, err := moneyLaunderingReview(ctx, testReviewConfig, req, nil, progress)
+	return progress.AMLEscalations, err
+}
+
+// newReviewEnv answers the MoneyLaunderingCheck after checkTakes
+func newReviewEnv(checkTakes time.Duration) *testsuite.TestWorkflowEnvironment {
+	env := (&testsuite.WorkflowTestSuite{}).NewTestWorkflowEnvironment()
+	env.RegisterWorkflow(reviewWorkflow)
+	env.RegisterActivityWithOptions(func(context.Context, contracts.Request, []contracts.SanctionsHit) (contracts.AMLDecision, error) {
+		return contracts.AMLDecision{}, nil
+	}, activity.RegisterOptions{Name: contracts.MoneyLaunderingCheckActivity})
+	for _, name := range []string{contracts.AMLReviewReminderActivity, contracts.EscalateAMLReviewActivity, contracts.ExpireAMLReviewActivity} {
+		env.RegisterActivityWithOptions(func(context.Context, contracts.AMLReviewNotice) error {
+			return nil
+		}, activity.RegisterOptions{Name: name})
+	}
+	env.OnActivity(contracts.MoneyLaunderingCheckActivity, mock.Anything, mock.Anything, mock.Anything).
+		After(checkTakes).Return(contracts.AMLDecision{Decision: contracts.MoneyLaunderingApprove}, nil)
+	return env
+}
+
+func TestReviewSLAStartsWithTheReview(t *testing.T) {
+	tests := []struct {
+		name        string
+		checkTakes  time.Duration
+		openedAfter time.Duration // zero when the rules engine decides
+		wantEvents  []string
+	}{
+		{
+			name:       "decided by the rules engine",
+			checkTakes: time.Second,
+		},
+		{
+			name:       "slow answer without a review",
+			checkTakes: time.Hour * 30,
+		},
+		{
+			name:        "manual review",
+			checkTakes:  time.Hour * 30,
+			openedAfter: time.Second,
+			wantEvents: []string{
+				contracts.AMLReviewReminded, contracts.AMLReviewReminded, contracts.AMLReviewReminded,
+				contracts.AMLReviewReminded, contracts.AMLReviewReminded, contracts.AMLReviewEscalated,
+				contracts.AMLReviewReminded, contracts.AMLReviewReminded,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newReviewEnv(tt.checkTakes)
+			if tt.openedAfter > 0 {
+				env.RegisterDelayedCallback(func() {
+					env.SignalWorkflow(contracts.AMLReviewOpenedSignal, nil)
+				}, tt.openedAfter)
+			}
+			env.ExecuteWorkflow(reviewWorkflow, contracts.Request{Ref: "ref-1"})
+			if err := env.GetWorkflowError(); err != nil {
+				t.Fatal(err)
+			}
+			var got []contracts.AMLEscalation
+			if err := env.GetWorkflowResult(&got); err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != len(tt.wantEvents) {
+				t.Fatalf("got %d SLA events %+v, want %v", len(got), got, tt.wantEvents)
+			}
+			for i, e := range got {
+				if e.Event != tt.wantEvents[i] {
+					t.Fatalf("event %d is %s, want %s", i, e.Event, tt.wantEvents[i])
+				}
+			}
+		})
+	}
+}
+
+func TestIgnoredReviewDecisions(t *testing.T) {
+	env := (&testsuite.WorkflowTestSuite{}).NewTestWorkflowEnvironment()
+	cfg := testReviewConfig
+	cfg.AMLReviewMode = contracts.AMLReviewModeSignal
+	env.RegisterWorkflowWithOptions(func(ctx workflow.Context, req contracts.Request) (contracts.AMLDecision, error) {
+		return moneyLaunderingReview(ctx, cfg, req, nil, &contracts.Progress{})
+	}, workflow.RegisterOptions{Name: "review"})
+	env.RegisterActivityWithOptions(func(context.Context, contracts.Request, []contracts.SanctionsHit) (contracts.AMLScreening, error) {
+		return contracts.AMLScreening{ApprovalsRequired: 2}, nil
+	}, activity.RegisterOptions{Name: contracts.AMLScreenActivity})
+
+	start := time.Date(2026, 11, 2, 9, 0, 0, 0, time.UTC)
+	env.SetStartTime(start)
+	decision := func(reviewer string, after time.Duration) contracts.AMLDecision {
+		return contracts.AMLDecision{Decision: contracts.MoneyLaunderingApprove, Reviewer: reviewer, DecidedAt: start.Add(after)}
+	}
+	first, again, second := decision("alice", time.Minute), decision("alice", time.Minute*2), decision("bob", time.Minute*3)
+	for i, d := range []contracts.AMLDecision{first, again, second} {
+		d := d
+		env.RegisterDelayedCallback(func() {
+			env.SignalWorkflow(contracts.AMLDecisionSignal, d)
+		}, time.Minute*time.Duration(i+1))
+	}
+	env.ExecuteWorkflow("review", contracts.Request{Ref: "ref-1"})
+	if err := env.GetWorkflowError(); err != nil {
+		t.Fatal(err)
+	}
+	value, err := env.QueryWorkflow(contracts.AMLReviewQuery)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var reviewCase contracts.AMLReviewCase
+	if err := value.Get(&reviewCase); err != nil {
+		t.Fatal(err)
+	}
+
+	late := decision("carol", time.Minute*4)
+	tests := []struct {
+		name     string
+		decision contracts.AMLDecision
+		counted  bool
+		problem  string
+	}{
+		{name: "first approval", decision: first, counted: true},
+		{name: "second approval by the same reviewer", decision: again, problem: "same reviewer cannot approve twice"},
+		{name: "deciding approval", decision: second, counted: true},
+		{name: "after the review closed", decision: late, problem: "review is closed"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handled, counted, problem := reviewCase.DecisionOutcome(tt.decision)
+			if !handled || counted != tt.counted || problem != tt.problem {
+				t.Fatalf("got handled %v, counted %v, problem %q; want counted %v, problem %q", handled, counted, problem,
+					tt.counted, tt.problem)
+			}
+		})
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"github.com/arunsworld/temporal-demo/bankingdemo/contracts"
+	"go.temporal.io/sdk/workflow"
+)
+
+// awaitSchedule holds a future-dated transfer until it is due: its ExecuteAt, moved to the next business day of
+// the configured calendar. The customer's edits are applied meanwhile and the request is returned as edited. A
+// cancellation ends the wait with a canceled error.
+func awaitSchedule(ctx workflow.Context, req contracts.Request, progress *contracts.Progress) (contracts.Request, error) {
+	edits := workflow.GetSignalChannel(ctx, contracts.EditScheduleSignal)
+	setStage(ctx, progress, contracts.StageScheduled)
+	for {
+		scheduledFor, err := recordedDueTime(ctx, req.ExecuteAt)
+		if err != nil {
+			return req, err
+		}
+		progress.ScheduledFor = scheduledFor
+		wait := progress.ScheduledFor.Sub(workflow.Now(ctx))
+		if wait <= 0 {
+			return req, nil
+		}
+		timerCtx, cancelTimer := workflow.WithCancel(ctx)
+		var timerErr error
+		due := false
+		selector := workflow.NewSelector(ctx)
+		selector.AddFuture(workflow.NewTimer(timerCtx, wait), func(f workflow.Future) {
+			due = true
+			timerErr = f.Get(ctx, nil)
+		})
+		selector.AddReceive(edits, func(c workflow.ReceiveChannel, _ bool) {
+			edit := contracts.ScheduleEdit{}
+			c.Receive(ctx, &edit)
+			req = applyScheduleEdit(ctx, req, edit, progress)
+		})
+		selector.Select(ctx)
+		cancelTimer()
+		if due {
+			return req, timerErr
+		}
+	}
+}
+
+func applyScheduleEdit(ctx workflow.Context, req contracts.Request, edit contracts.ScheduleEdit, progress *contracts.Progress) contracts.Request {
+	if edit.Amount.Currency != "" {
+		if edit.Amount.Currency != req.Amount.Currency || edit.Amount.Minor <= 0 {
+			workflow.GetLogger(ctx).Warn("ignoring schedule edit with a bad amount", "Ref", req.Ref, "Amount", edit.Amount.String())
+			return req
+		}
+		req.Amount = edit.Amount
+		// an edit without a fee keeps the ceiling the customer agreed to
+		if edit.AcceptedFee != nil {
+			req.AcceptedFee = edit.AcceptedFee
+		}
+	}
+	if !edit.ExecuteAt.IsZero() {
+		req.ExecuteAt = edit.ExecuteAt
+	}
+	workflow.GetLogger(ctx).Info("schedule edited", "Ref", req.Ref, "Editor", edit.Editor,
+		"ExecuteAt", req.ExecuteAt, "Amount", req.Amount.String())
+	progress.Request = req
+	progress.ScheduleEdits++
+	return req
+}
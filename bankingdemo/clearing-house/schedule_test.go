@@ -0,0 +1,67 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/arunsworld/temporal-demo/bankingdemo/contracts"
+	"go.temporal.io/sdk/testsuite"
+	"go.temporal.io/sdk/workflow"
+)
+
+func applyScheduleEditWorkflow(ctx workflow.Context, req contracts.Request, edit contracts.ScheduleEdit) (contracts.Request, error) {
+	return applyScheduleEdit(ctx, req, edit, &contracts.Progress{}), nil
+}
+
+func TestApplyScheduleEdit(t *testing.T) {
+	usd := func(minor int64) *contracts.Money {
+		return &contracts.Money{Minor: minor, Currency: "USD"}
+	}
+	due := time.Date(2026, 11, 2, 9, 0, 0, 0, time.UTC)
+	req := contracts.Request{Ref: "ref-1", Amount: *usd(10000), AcceptedFee: usd(250), ExecuteAt: due}
+	tests := []struct {
+		name string
+		edit contracts.ScheduleEdit
+		want contracts.Request
+	}{
+		{
+			name: "amount and fee",
+			edit: contracts.ScheduleEdit{Amount: *usd(20000), AcceptedFee: usd(400)},
+			want: contracts.Request{Ref: "ref-1", Amount: *usd(20000), AcceptedFee: usd(400), ExecuteAt: due},
+		},
+		{
+			name: "amount without a fee keeps the fee ceiling",
+			edit: contracts.ScheduleEdit{Amount: *usd(20000)},
+			want: contracts.Request{Ref: "ref-1", Amount: *usd(20000), AcceptedFee: usd(250), ExecuteAt: due},
+		},
+		{
+			name: "due time only",
+			edit: contracts.ScheduleEdit{ExecuteAt: due.Add(time.Hour)},
+			want: contracts.Request{Ref: "ref-1", Amount: *usd(10000), AcceptedFee: usd(250), ExecuteAt: due.Add(time.Hour)},
+		},
+		{
+			name: "amount in another currency is ignored",
+			edit: contracts.ScheduleEdit{Amount: contracts.Money{Minor: 20000, Currency: "EUR"}, ExecuteAt: due.Add(time.Hour)},
+			want: req,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := (&testsuite.WorkflowTestSuite{}).NewTestWorkflowEnvironment()
+			env.RegisterWorkflow(applyScheduleEditWorkflow)
+			env.ExecuteWorkflow(applyScheduleEditWorkflow, req, tt.edit)
+			if err := env.GetWorkflowError(); err != nil {
+				t.Fatal(err)
+			}
+			var got contracts.Request
+			if err := env.GetWorkflowResult(&got); err != nil {
+				t.Fatal(err)
+			}
+			if got.Amount != tt.want.Amount || !got.ExecuteAt.Equal(tt.want.ExecuteAt) ||
+				got.AcceptedFee == nil || *got.AcceptedFee != *tt.want.AcceptedFee {
+				t.Fatalf("got amount %s, fee %v, due %v; want %s, %s, %v", got.Amount, got.AcceptedFee, got.ExecuteAt,
+					tt.want.Amount, tt.want.AcceptedFee, tt.want.ExecuteAt)
+			}
+		})
+	}
+}
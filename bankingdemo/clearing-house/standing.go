@@ -0,0 +1,302 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/arunsworld/temporal-demo/bankingdemo/contracts"
+	"go.temporal.io/api/enums/v1"
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/workflow"
+)
+
+const (
+	// occurrences kept in a standing order's history
+	standingOrderHistoryLength = 24
+	// occurrences a standing order goes through before it continues as new, keeping its event history short
+	standingOrderRunLength = 12
+)
+
+// StandingOrder makes a MoneyTransfer for every occurrence of state.Order until it is cancelled or ends. Each
+// transfer is a child workflow due at its occurrence, so one falling on a weekend or holiday waits for the next
+// business day like any future-dated transfer. Occurrences are transferred one at a time; a transfer still
+// under way delays the next.
+//
+// Pausing skips the occurrences that fall due until the order is resumed, and the order pauses itself after
+// StandingOrderMaxFailures transfers in a row fail. Amendments apply to the occurrences still to come. A
+// cancellation ends the order but leaves a transfer under way to complete.
+func StandingOrder(ctx workflow.Context, state contracts.StandingOrderState) (contracts.StandingOrderState, error) {
+	if state.Status == "" {
+		state.Status = contracts.StandingOrderActive
+	}
+	if err := workflow.SetQueryHandler(ctx, contracts.StandingOrderQuery, func() (contracts.StandingOrderState, error) {
+		return state, nil
+	}); err != nil {
+		return state, err
+	}
+	if err := state.Order.Recurrence.Validate(); err != nil {
+		return state, temporal.NewNonRetryableApplicationError(err.Error(), "BadStandingOrder", nil)
+	}
+	cfg, err := recordStandingOrderConfig(ctx)
+	if err != nil {
+		return state, err
+	}
+	so := &standingOrder{
+		state:  &state,
+		cfg:    cfg,
+		pause:  workflow.GetSignalChannel(ctx, contracts.PauseStandingOrderSignal),
+		resume: workflow.GetSignalChannel(ctx, contracts.ResumeStandingOrderSignal),
+		amend:  workflow.GetSignalChannel(ctx, contracts.AmendStandingOrderSignal),
+	}
+	// transfers outlive a cancellation of the order
+	dctx, _ := workflow.NewDisconnectedContext(ctx)
+
+	for occurrences := 0; ; {
+		due := state.Order.Recurrence.Occurrence(state.Order.StartAt, state.Next, so.cfg.Location())
+		if so.ended(due) {
+			state.Status = contracts.StandingOrderCompleted
+			state.NextDueAt = time.Time{}
+			return state, nil
+		}
+		state.NextDueAt = due
+		isDue, err := so.await(ctx, due)
+		if temporal.IsCanceledError(err) {
+			return so.cancelled(), nil
+		}
+		if err != nil {
+			return state, err
+		}
+		if !isDue {
+			// a signal came first; it may have amended when the order ends
+			continue
+		}
+
+		if state.Status == contracts.StandingOrderPaused {
+			so.record(contracts.StandingOrderOccurrence{
+				N:      state.Next,
+				DueAt:  due,
+				Amount: state.Order.Request.Amount,
+				Status: contracts.OccurrenceSkipped,
+			})
+		} else if err := so.transfer(ctx, dctx, due); err != nil {
+			if temporal.IsCanceledError(err) {
+				return so.cancelled(), nil
+			}
+			return state, err
+		}
+		state.Next++
+
+		if occurrences++; occurrences >= standingOrderRunLength {
+			so.drainSignals(ctx)
+			return state, workflow.NewContinueAsNewError(ctx, contracts.StandingOrderWorkflow, state)
+		}
+	}
+}
+
+type standingOrder struct {
+	state                *contracts.StandingOrderState
+	cfg                  standingOrderConfig
+	pause, resume, amend workflow.ReceiveChannel
+}
+
+func (so *standingOrder) ended(due time.Time) bool {
+	order := so.state.Order
+	return (order.Count > 0 && so.state.Next >= order.Count) || (!order.EndAt.IsZero() && due.After(order.EndAt))
+}
+
+// await waits until due, answering whether it is; a signal ends the wait early
+func (so *standingOrder) await(ctx workflow.Context, due time.Time) (bool, error) {
+	wait := due.Sub(workflow.Now(ctx))
+	if wait <= 0 {
+		return true, nil
+	}
+	timerCtx, cancelTimer := workflow.WithCancel(ctx)
+	defer cancelTimer()
+	var timerErr error
+	isDue := false
+	selector := workflow.NewSelector(ctx)
+	selector.AddFuture(workflow.NewTimer(timerCtx, wait), func(f workflow.Future) {
+		isDue = true
+		timerErr = f.Get(ctx, nil)
+	})
+	so.addSignals(ctx, selector)
+	selector.Select(ctx)
+	return isDue, timerErr
+}
+
+// transfer makes the transfer of the occurrence due and waits for its outcome
+func (so *standingOrder) transfer(ctx, dctx workflow.Context, due time.Time) error {
+	state := so.state
+	req := state.Order.Request
+	req.Ref = contracts.StandingOrderTransferRef(state.Order.Ref, state.Next)
+	req.ExecuteAt = due
+	options := workflow.ChildWorkflowOptions{
+		WorkflowID:            contracts.MoneyTransferID(req.Ref),
+		TaskQueue:             contracts.ClearingHouseTaskQueue,
+		WorkflowIDReusePolicy: enums.WORKFLOW_ID_REUSE_POLICY_REJECT_DUPLICATE,
+		ParentClosePolicy:     enums.PARENT_CLOSE_POLICY_ABANDON,
+	}
+	if customer := state.Order.Customer; customer != "" {
+		options.Memo = map[string]interface{}{contracts.CustomerMemo: customer}
+		options.SearchAttributes = map[string]interface{}{contracts.CustomerIDSearchAttribute: customer}
+	}
+	child := workflow.ExecuteChildWorkflow(workflow.WithChildOptions(dctx, options), contracts.MoneyTransferWorkflow, req)
+	state.Current = req.Ref
+
+	resp := contracts.Response{}
+	var transferErr error
+	done, cancelled := false, false
+	for !done && !cancelled {
+		selector := workflow.NewSelector(ctx)
+		selector.AddFuture(child, func(f workflow.Future) {
+			done = true
+			transferErr = f.Get(ctx, &resp)
+		})
+		selector.AddReceive(ctx.Done(), func(workflow.ReceiveChannel, bool) {
+			cancelled = true
+		})
+		so.addSignals(ctx, selector)
+		selector.Select(ctx)
+	}
+	if cancelled {
+		// Current is left pointing at the transfer, which carries on
+		return temporal.NewCanceledError()
+	}
+	state.Current = ""
+
+	occurrence := contracts.StandingOrderOccurrence{
+		N:             state.Next,
+		Ref:           req.Ref,
+		DueAt:         due,
+		Amount:        req.Amount,
+		Status:        resp.Status,
+		FailureReason: resp.FailureReason,
+	}
+	if temporal.IsWorkflowExecutionAlreadyStartedError(transferErr) {
+		// no transfer was made, so it says nothing about the accounts and does not count towards pausing
+		workflow.GetLogger(ctx).Warn("standing order transfer ID is taken", "Ref", req.Ref)
+		occurrence.Status = contracts.OccurrenceNotStarted
+		occurrence.FailureReason = fmt.Sprintf("a transfer with reference %s already exists", req.Ref)
+		so.record(occurrence)
+		return nil
+	}
+	if transferErr != nil {
+		occurrence.Status = contracts.StatusFailure
+		occurrence.FailureReason = transferErr.Error()
+	}
+	so.record(occurrence)
+	state.Transfers++
+	switch occurrence.Status {
+	case contracts.StatusSuccess:
+		state.ConsecutiveFailures = 0
+	case contracts.StatusCancelled:
+		// the customer called this one off; it says nothing about the next
+	default:
+		state.Failures++
+		state.ConsecutiveFailures++
+	}
+	if state.Status == contracts.StandingOrderActive && state.ConsecutiveFailures >= so.cfg.MaxFailures {
+		state.Status = contracts.StandingOrderPaused
+		state.PausedReason = fmt.Sprintf("%d transfers in a row did not go through", state.ConsecutiveFailures)
+		so.notify(ctx, fmt.Sprintf("Your standing order %s has been paused because %s. Resume it once the problem "+
+			"is fixed.", state.Order.Ref, state.PausedReason))
+	}
+	return nil
+}
+
+func (so *standingOrder) record(occurrence contracts.StandingOrderOccurrence) {
+	history := append(so.state.History, occurrence)
+	if len(history) > standingOrderHistoryLength {
+		history = history[len(history)-standingOrderHistoryLength:]
+	}
+	so.state.History = history
+}
+
+func (so *standingOrder) cancelled() contracts.StandingOrderState {
+	so.state.Status = contracts.StandingOrderCancelled
+	so.state.NextDueAt = time.Time{}
+	return *so.state
+}
+
+func (so *standingOrder) addSignals(ctx workflow.Context, selector workflow.Selector) {
+	selector.AddReceive(so.pause, func(c workflow.ReceiveChannel, _ bool) {
+		editor := ""
+		c.Receive(ctx, &editor)
+		so.onPause(ctx, editor)
+	})
+	selector.AddReceive(so.resume, func(c workflow.ReceiveChannel, _ bool) {
+		editor := ""
+		c.Receive(ctx, &editor)
+		so.onResume(ctx, editor)
+	})
+	selector.AddReceive(so.amend, func(c workflow.ReceiveChannel, _ bool) {
+		amendment := contracts.StandingOrderAmendment{}
+		c.Receive(ctx, &amendment)
+		so.onAmend(ctx, amendment)
+	})
+}
+
+// drainSignals handles the signals still pending, which would otherwise be lost when continuing as new
+func (so *standingOrder) drainSignals(ctx workflow.Context) {
+	for editor := ""; so.pause.ReceiveAsync(&editor); editor = "" {
+		so.onPause(ctx, editor)
+	}
+	for editor := ""; so.resume.ReceiveAsync(&editor); editor = "" {
+		so.onResume(ctx, editor)
+	}
+	for amendment := (contracts.StandingOrderAmendment{}); so.amend.ReceiveAsync(&amendment); amendment = (contracts.StandingOrderAmendment{}) {
+		so.onAmend(ctx, amendment)
+	}
+}
+
+func (so *standingOrder) onPause(ctx workflow.Context, editor string) {
+	if so.state.Status != contracts.StandingOrderActive {
+		return
+	}
+	workflow.GetLogger(ctx).Info("standing order paused", "Ref", so.state.Order.Ref, "Editor", editor)
+	so.state.Status = contracts.StandingOrderPaused
+	so.state.PausedReason = ""
+}
+
+func (so *standingOrder) onResume(ctx workflow.Context, editor string) {
+	if so.state.Status != contracts.StandingOrderPaused {
+		return
+	}
+	workflow.GetLogger(ctx).Info("standing order resumed", "Ref", so.state.Order.Ref, "Editor", editor)
+	so.state.Status = contracts.StandingOrderActive
+	so.state.PausedReason = ""
+	so.state.ConsecutiveFailures = 0
+}
+
+func (so *standingOrder) onAmend(ctx workflow.Context, amendment contracts.StandingOrderAmendment) {
+	order := &so.state.Order
+	if amendment.Amount.Currency != "" {
+		if amendment.Amount.Currency != order.Request.Amount.Currency || amendment.Amount.Minor <= 0 {
+			workflow.GetLogger(ctx).Warn("ignoring standing order amendment with a bad amount", "Ref", order.Ref,
+				"Amount", amendment.Amount.String())
+			return
+		}
+		order.Request.Amount = amendment.Amount
+		// an amendment without a fee keeps the ceiling the customer agreed to
+		if amendment.AcceptedFee != nil {
+			order.Request.AcceptedFee = amendment.AcceptedFee
+		}
+	}
+	if !amendment.EndAt.IsZero() {
+		order.EndAt = amendment.EndAt
+	}
+	workflow.GetLogger(ctx).Info("standing order amended", "Ref", order.Ref, "Editor", amendment.Editor,
+		"Amount", order.Request.Amount.String(), "EndAt", order.EndAt)
+	so.state.Amendments++
+}
+
+func (so *standingOrder) notify(ctx workflow.Context, message string) {
+	nctx := workflow.WithActivityOptions(ctx, workflow.ActivityOptions{
+		StartToCloseTimeout:    time.Minute,
+		ScheduleToCloseTimeout: time.Minute * 10,
+	})
+	ref := contracts.StandingOrderID(so.state.Order.Ref)
+	if err := contracts.NotifyCustomer(nctx, contracts.CustomerNotification{Ref: ref, Message: message}); err != nil {
+		workflow.GetLogger(ctx).Warn("unable to notify the customer", "Ref", so.state.Order.Ref, "Error", err)
+	}
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/arunsworld/temporal-demo/bankingdemo/contracts"
+	"go.temporal.io/sdk/client"
+	"go.temporal.io/sdk/converter"
+	"go.temporal.io/sdk/testsuite"
+	"go.temporal.io/sdk/workflow"
+)
+
+// newStandingOrderEnv runs StandingOrder with transfers that succeed at once
+func newStandingOrderEnv(start time.Time) *testsuite.TestWorkflowEnvironment {
+	env := (&testsuite.WorkflowTestSuite{}).NewTestWorkflowEnvironment()
+	env.SetStartTime(start)
+	env.RegisterWorkflowWithOptions(StandingOrder, workflow.RegisterOptions{Name: contracts.StandingOrderWorkflow})
+	env.RegisterWorkflowWithOptions(func(ctx workflow.Context, req contracts.Request) (contracts.Response, error) {
+		return contracts.Response{Status: contracts.StatusSuccess}, nil
+	}, workflow.RegisterOptions{Name: contracts.MoneyTransferWorkflow})
+	return env
+}
+
+func amendStandingOrderWorkflow(ctx workflow.Context, state contracts.StandingOrderState, amendment contracts.StandingOrderAmendment) (contracts.StandingOrderState, error) {
+	so := &standingOrder{state: &state}
+	so.onAmend(ctx, amendment)
+	return state, nil
+}
+
+func TestAmendStandingOrder(t *testing.T) {
+	usd := func(minor int64) *contracts.Money {
+		return &contracts.Money{Minor: minor, Currency: "USD"}
+	}
+	state := contracts.StandingOrderState{Order: contracts.StandingOrder{
+		Ref:     "rent",
+		Request: contracts.Request{Amount: *usd(50000), AcceptedFee: usd(250)},
+	}}
+	tests := []struct {
+		name      string
+		amendment contracts.StandingOrderAmendment
+		wantFee   contracts.Money
+	}{
+		{
+			name:      "amount and fee",
+			amendment: contracts.StandingOrderAmendment{Amount: *usd(55000), AcceptedFee: usd(300)},
+			wantFee:   *usd(300),
+		},
+		{
+			name:      "amount without a fee keeps the fee ceiling",
+			amendment: contracts.StandingOrderAmendment{Amount: *usd(55000)},
+			wantFee:   *usd(250),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := (&testsuite.WorkflowTestSuite{}).NewTestWorkflowEnvironment()
+			env.RegisterWorkflow(amendStandingOrderWorkflow)
+			env.ExecuteWorkflow(amendStandingOrderWorkflow, state, tt.amendment)
+			if err := env.GetWorkflowError(); err != nil {
+				t.Fatal(err)
+			}
+			var got contracts.StandingOrderState
+			if err := env.GetWorkflowResult(&got); err != nil {
+				t.Fatal(err)
+			}
+			req := got.Order.Request
+			if req.Amount != tt.amendment.Amount || req.AcceptedFee == nil || *req.AcceptedFee != tt.wantFee {
+				t.Fatalf("got amount %s and fee %v, want %s and %s", req.Amount, req.AcceptedFee, tt.amendment.Amount, tt.wantFee)
+			}
+		})
+	}
+}
+
+func TestStandingOrderTransferIDTaken(t *testing.T) {
+	start := time.Date(2026, 11, 2, 9, 0, 0, 0, time.UTC)
+	env := newStandingOrderEnv(start.Add(-time.Hour))
+	// the standing order itself holds the ID of its first transfer, as another workflow would
+	env.SetStartWorkflowOptions(client.StartWorkflowOptions{ID: contracts.MoneyTransferID("rent#1")})
+	env.ExecuteWorkflow(contracts.StandingOrderWorkflow, contracts.StandingOrderState{Order: contracts.StandingOrder{
+		Ref:        "rent",
+		Request:    contracts.Request{Amount: contracts.Money{Minor: 50000, Currency: "USD"}},
+		Recurrence: contracts.Recurrence{Frequency: contracts.FrequencyDaily},
+		StartAt:    start,
+		Count:      2,
+	}})
+	if err := env.GetWorkflowError(); err != nil {
+		t.Fatal(err)
+	}
+	var got contracts.StandingOrderState
+	if err := env.GetWorkflowResult(&got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got.History) != 2 || got.History[0].Status != contracts.OccurrenceNotStarted ||
+		got.History[1].Status != contracts.StatusSuccess {
+		t.Fatalf("got history %+v, want not_started then success", got.History)
+	}
+	if got.Transfers != 1 || got.Failures != 0 || got.ConsecutiveFailures != 0 || got.Status != contracts.StandingOrderCompleted {
+		t.Fatalf("got %d transfers, %d failures, %d in a row, status %s; want 1, 0, 0, completed",
+			got.Transfers, got.Failures, got.ConsecutiveFailures, got.Status)
+	}
+}
+
+func TestStandingOrderEnds(t *testing.T) {
+	start := time.Date(2026, 1, 31, 9, 0, 0, 0, time.UTC)
+	monthly := contracts.Recurrence{Frequency: contracts.FrequencyMonthly}
+	tests := []struct {
+		name    string
+		count   int
+		endAt   time.Time
+		wantDue []time.Time
+	}{
+		{
+			name:    "after Count occurrences",
+			count:   3,
+			wantDue: []time.Time{start, time.Date(2026, 2, 28, 9, 0, 0, 0, time.UTC), time.Date(2026, 3, 31, 9, 0, 0, 0, time.UTC)},
+		},
+		{
+			name:    "with an occurrence due at EndAt",
+			endAt:   time.Date(2026, 2, 28, 9, 0, 0, 0, time.UTC),
+			wantDue: []time.Time{start, time.Date(2026, 2, 28, 9, 0, 0, 0, time.UTC)},
+		},
+		{
+			name:    "before the first occurrence after EndAt",
+			endAt:   time.Date(2026, 3, 30, 0, 0, 0, 0, time.UTC),
+			wantDue: []time.Time{start, time.Date(2026, 2, 28, 9, 0, 0, 0, time.UTC)},
+		},
+		{
+			name:    "at whichever of Count and EndAt comes first",
+			count:   5,
+			endAt:   time.Date(2026, 3, 31, 9, 0, 0, 0, time.UTC),
+			wantDue: []time.Time{start, time.Date(2026, 2, 28, 9, 0, 0, 0, time.UTC), time.Date(2026, 3, 31, 9, 0, 0, 0, time.UTC)},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newStandingOrderEnv(start.Add(-time.Hour))
+			env.ExecuteWorkflow(contracts.StandingOrderWorkflow, contracts.StandingOrderState{Order: contracts.StandingOrder{
+				Ref:        "rent",
+				Request:    contracts.Request{Amount: contracts.Money{Minor: 50000, Currency: "USD"}},
+				Recurrence: monthly,
+				StartAt:    start,
+				EndAt:      tt.endAt,
+				Count:      tt.count,
+			}})
+			if err := env.GetWorkflowError(); err != nil {
+				t.Fatal(err)
+			}
+			var got contracts.StandingOrderState
+			if err := env.GetWorkflowResult(&got); err != nil {
+				t.Fatal(err)
+			}
+			if got.Status != contracts.StandingOrderCompleted || !got.NextDueAt.IsZero() || got.Transfers != len(tt.wantDue) {
+				t.Fatalf("got status %s, next due %v after %d transfers; want completed after %d", got.Status, got.NextDueAt,
+					got.Transfers, len(tt.wantDue))
+			}
+			for i, want := range tt.wantDue {
+				if !got.History[i].DueAt.Equal(want) {
+					t.Fatalf("occurrence %d was due %v, want %v", i, got.History[i].DueAt, want)
+				}
+			}
+		})
+	}
+}
+
+func TestStandingOrderContinuesAsNew(t *testing.T) {
+	start := time.Date(2026, 11, 2, 9, 0, 0, 0, time.UTC)
+	state := contracts.StandingOrderState{Order: contracts.StandingOrder{
+		Ref:        "rent",
+		Request:    contracts.Request{Amount: contracts.Money{Minor: 50000, Currency: "USD"}},
+		Recurrence: contracts.Recurrence{Frequency: contracts.FrequencyDaily},
+		StartAt:    start,
+		Count:      standingOrderRunLength + 3,
+	}}
+
+	env := newStandingOrderEnv(start.Add(-time.Hour))
+	env.ExecuteWorkflow(contracts.StandingOrderWorkflow, state)
+	var continued *workflow.ContinueAsNewError
+	if err := env.GetWorkflowError(); !errors.As(err, &continued) {
+		t.Fatalf("got %v, want it to continue as new after %d occurrences", err, standingOrderRunLength)
+	}
+	var next contracts.StandingOrderState
+	if err := converter.GetDefaultDataConverter().FromPayloads(continued.Input, &next); err != nil {
+		t.Fatal(err)
+	}
+	if next.Next != standingOrderRunLength || next.Transfers != standingOrderRunLength || next.Status != contracts.StandingOrderActive {
+		t.Fatalf("continued at occurrence %d after %d transfers, status %s; want %d, %d, active", next.Next, next.Transfers,
+			next.Status, standingOrderRunLength, standingOrderRunLength)
+	}
+
+	env = newStandingOrderEnv(start.AddDate(0, 0, standingOrderRunLength).Add(-time.Hour))
+	env.ExecuteWorkflow(contracts.StandingOrderWorkflow, next)
+	if err := env.GetWorkflowError(); err != nil {
+		t.Fatal(err)
+	}
+	var got contracts.StandingOrderState
+	if err := env.GetWorkflowResult(&got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Status != contracts.StandingOrderCompleted || got.Transfers != state.Order.Count || len(got.History) != state.Order.Count {
+		t.Fatalf("got status %s after %d transfers and %d in the history; want completed after %d", got.Status,
+			got.Transfers, len(got.History), state.Order.Count)
+	}
+	for n, occurrence := range got.History {
+		wantRef := contracts.StandingOrderTransferRef("rent", n)
+		if occurrence.N != n || occurrence.Ref != wantRef || !occurrence.DueAt.Equal(start.AddDate(0, 0, n)) {
+			t.Fatalf("occurrence %d is %+v, want %s due %v", n, occurrence, wantRef, start.AddDate(0, 0, n))
+		}
+	}
+}
@@ -1,146 +1,227 @@
 package main
 
 import (
-	"fmt"
+	"errors"
 	"time"
 
+	"github.com/arunsworld/temporal-demo/bankingdemo/calendar"
+	"github.com/arunsworld/temporal-demo/bankingdemo/contracts"
+	"go.temporal.io/sdk/temporal"
 	"go.temporal.io/sdk/workflow"
 )
 
-var moneyLaunderingThresholdAmount = 1000.0
+func MoneyTransfer(ctx workflow.Context, req contracts.Request) (contracts.Response, error) {
+	progress := &contracts.Progress{
+		Attempts: make(map[string]int),
+	}
+	if err := workflow.SetQueryHandler(ctx, contracts.ProgressQuery, func() (contracts.Progress, error) {
+		return *progress, nil
+	}); err != nil {
+		return contracts.Response{}, err
+	}
 
-type Request struct {
-	SourceBank, DestinationBank string
-	SourceAcc, DestinationAcc   string
-	Amount                      float64
-	Ref                         string
-}
+	// Customers may cancel until the deposit starts. Bank postings always run to completion on a disconnected
+	// context so that a cancellation never leaves money in an unknown state; a cancellation that arrives after
+	// the withdrawal is honoured with a refund.
+	workflow.Go(ctx, func(gctx workflow.Context) {
+		ctx.Done().Receive(gctx, nil)
+		progress.CancelRequested = true
+	})
+	dctx, _ := workflow.NewDisconnectedContext(ctx)
+	cfg, err := recordTransferConfig(ctx)
+	if err != nil {
+		return contracts.Response{}, err
+	}
 
-type TxnResponse struct {
-	Status        string // success, failure
-	FailureReason string
-}
+	progress.Request = req
+	if !req.ExecuteAt.IsZero() {
+		req, err = awaitSchedule(ctx, req, progress)
+		if temporal.IsCanceledError(err) {
+			return newResponse(dctx, progress, contracts.StatusCancelled, "Cancelled by customer before it was due"), nil
+		}
+		if errors.Is(err, calendar.ErrNoBusinessDay) {
+			return newResponse(ctx, progress, contracts.StatusFailure, "No business day within a year of the execution time"), nil
+		}
+		if err != nil {
+			return contracts.Response{}, err
+		}
+	}
 
-type BankTransaction struct {
-	AccountID string
-	Amount    float64
-	Reference string
-	IsRefund  bool
-}
+	if err := workflow.SideEffect(ctx, currentTime).Get(&progress.StartTime); err != nil {
+		return contracts.Response{}, err
+	}
+	setStage(ctx, progress, contracts.StageStarted)
 
-type Response struct {
-	Status                         string // success, failure, refunded
-	FailureReason                  string // populated for failure and refunded
-	StartTime                      time.Time
-	MoneyLaunderingCheckFinishTime time.Time
-	WithdrawDoneTime               time.Time
-	RefundDoneTime                 time.Time
-	DepositDoneTime                time.Time
-}
+	req, err = withReferenceAmount(ctx, req)
+	if temporal.IsCanceledError(err) {
+		return newResponse(dctx, progress, contracts.StatusCancelled, "Cancelled by customer"), nil
+	}
+	if isUnknownCurrency(err) {
+		return newResponse(ctx, progress, contracts.StatusFailure, "Currency not supported"), nil
+	}
+	if err != nil {
+		return contracts.Response{}, err
+	}
+
+	if failure := priceTransfer(ctx, req, progress); failure != "" {
+		return newResponse(ctx, progress, contracts.StatusFailure, failure), nil
+	}
+
+	// Every transfer is screened; the money laundering service decides which ones need a reviewer
+	setStage(ctx, progress, contracts.StageSanctionsScreening)
+	sctx := workflow.WithActivityOptions(ctx, workflow.ActivityOptions{
+		StartToCloseTimeout: time.Minute,
+	})
+	hits, err := contracts.SanctionsScreen(sctx, req)
+	if temporal.IsCanceledError(err) {
+		return newResponse(dctx, progress, contracts.StatusCancelled, "Cancelled by customer during screening"), nil
+	}
+	if err != nil {
+		return contracts.Response{}, err
+	}
+
+	setStage(ctx, progress, contracts.StageMoneyLaunderingReview)
+	decision, err := moneyLaunderingReview(ctx, cfg, req, hits, progress)
+	if temporal.IsCanceledError(err) {
+		return newResponse(dctx, progress, contracts.StatusCancelled, "Cancelled by customer during AML review"), nil
+	}
+	if err != nil {
+		return contracts.Response{}, err
+	}
+	_ = workflow.SideEffect(ctx, currentTime).Get(&progress.MoneyLaunderingCheckFinishTime)
+	progress.MoneyLaunderingDecision = &decision
+	if decision.Decision == contracts.MoneyLaunderingReject && decision.ReasonCode == reviewExpiredReason {
+		return newResponse(ctx, progress, contracts.StatusFailure, "Money Laundering check not completed in time"), nil
+	}
+	if decision.Decision == contracts.MoneyLaunderingReject {
+		return newResponse(ctx, progress, contracts.StatusFailure, "Money Laundering check failed"), nil
+	}
+
+	if ctx.Err() != nil {
+		return newResponse(dctx, progress, contracts.StatusCancelled, "Cancelled by customer"), nil
+	}
 
-func MoneyTransfer(ctx workflow.Context, req Request) (Response, error) {
-	var startTime time.Time
-	var moneyLaunderingFinishTime time.Time
-	var withdrawDoneTime time.Time
-	var refundDoneTime time.Time
-	var depositDoneTime time.Time
-
-	if err := workflow.SideEffect(ctx, currentTime).Get(&startTime); err != nil {
-		return Response{}, err
-	}
-
-	if req.Amount >= moneyLaunderingThresholdAmount {
-		actx := workflow.WithActivityOptions(ctx, workflow.ActivityOptions{
-			TaskQueue:           "money-laundering",
-			StartToCloseTimeout: time.Hour * 24 * 5,
-		})
-		var moneyLaunderingCheckResponse string
-		if err := workflow.ExecuteActivity(actx, "MoneyLaunderingCheck", req).Get(actx, &moneyLaunderingCheckResponse); err != nil {
-			return Response{}, err
+	// The rate is locked before any money moves; the destination is credited at it whatever happens after
+	depositAmount := req.Amount
+	if req.DestinationCurrency != "" && req.Amount.Currency != req.DestinationCurrency {
+		setStage(ctx, progress, contracts.StageLockingFXRate)
+		quote, err := lockFXRate(ctx, req)
+		if temporal.IsCanceledError(err) {
+			return newResponse(dctx, progress, contracts.StatusCancelled, "Cancelled by customer"), nil
 		}
-		_ = workflow.SideEffect(ctx, currentTime).Get(&moneyLaunderingFinishTime)
-		if moneyLaunderingCheckResponse == "reject" {
-			return newResponse("failure", "Money Laundering check failed", startTime, moneyLaunderingFinishTime, withdrawDoneTime, refundDoneTime, depositDoneTime), nil
+		if isUnknownCurrency(err) {
+			return newResponse(ctx, progress, contracts.StatusFailure, "Currency not supported"), nil
 		}
+		if err == errFXQuoteExpired {
+			return newResponse(ctx, progress, contracts.StatusFailure, "Unable to lock an exchange rate"), nil
+		}
+		if err != nil {
+			return contracts.Response{}, err
+		}
+		progress.FXQuote = &quote
+		depositAmount = quote.ConvertedAmount
 	}
 
 	// Withdrawal
-	actx := workflow.WithActivityOptions(ctx, workflow.ActivityOptions{
-		TaskQueue:           req.SourceBank,
+	setStage(ctx, progress, contracts.StageWithdrawing)
+	actx := workflow.WithActivityOptions(dctx, workflow.ActivityOptions{
 		StartToCloseTimeout: time.Minute,
 	})
-	txn := BankTransaction{
+	txn := contracts.BankTransaction{
 		AccountID: req.SourceAcc,
-		Amount:    req.Amount,
+		Amount:    withdrawalAmount(req, progress),
 		Reference: req.Ref,
 	}
-	txnResp := TxnResponse{}
-	if err := workflow.ExecuteActivity(actx, "Withdraw", txn).Get(actx, &txnResp); err != nil {
-		return Response{}, err
+	txnResp, err := contracts.Withdraw(actx, req.SourceBank, txn)
+	if err != nil {
+		return contracts.Response{}, err
 	}
-	if txnResp.Status != "success" {
-		return newResponse("failure", txnResp.FailureReason, startTime, moneyLaunderingFinishTime, withdrawDoneTime, refundDoneTime, depositDoneTime), nil
+	if txnResp.Status != contracts.TxnSuccess {
+		return newResponse(ctx, progress, contracts.StatusFailure, txnResp.FailureReason), nil
 	}
 
-	_ = workflow.SideEffect(ctx, currentTime).Get(&withdrawDoneTime)
+	_ = workflow.SideEffect(dctx, currentTime).Get(&progress.WithdrawDoneTime)
 
-	// Deposit
-	actx = workflow.WithActivityOptions(ctx, workflow.ActivityOptions{
-		TaskQueue:           req.DestinationBank,
-		StartToCloseTimeout: time.Minute,
-	})
-	txn = BankTransaction{
+	if ctx.Err() != nil {
+		outcome, err := refund(dctx, cfg, req, progress, "Cancelled by customer")
+		if err != nil {
+			return contracts.Response{}, err
+		}
+		if outcome.status == contracts.StatusRefunded {
+			outcome.status = contracts.StatusCancelled
+		}
+		return newRefundResponse(dctx, progress, outcome), nil
+	}
+
+	// Deposit; from here on the transfer can no longer be cancelled
+	setStage(ctx, progress, contracts.StageDepositing)
+	txn = contracts.BankTransaction{
 		AccountID: req.DestinationAcc,
-		Amount:    req.Amount,
+		Amount:    depositAmount,
 		Reference: req.Ref,
 	}
-	txnResp = TxnResponse{}
-	if err := workflow.ExecuteActivity(actx, "Deposit", txn).Get(actx, &txnResp); err != nil {
-		return Response{}, err
-	}
-	if txnResp.Status != "success" {
-		// Refund
-		actx = workflow.WithActivityOptions(ctx, workflow.ActivityOptions{
-			TaskQueue:           req.SourceBank,
-			StartToCloseTimeout: time.Minute,
-		})
-		txn = BankTransaction{
-			AccountID: req.SourceAcc,
-			Amount:    req.Amount,
-			Reference: fmt.Sprintf("REFUND: %s", req.Ref),
-			IsRefund:  true,
-		}
-		refundTxnResp := TxnResponse{}
-		if refundErr := workflow.ExecuteActivity(actx, "Deposit", txn).Get(actx, &refundTxnResp); refundErr != nil {
-			return Response{}, refundErr
-		}
-		if refundTxnResp.Status != "success" {
-			return newResponse("success", fmt.Sprintf("Deposit failed due to %s. Refund also failed: %s", txnResp.FailureReason, refundTxnResp.FailureReason),
-				startTime, moneyLaunderingFinishTime, withdrawDoneTime, refundDoneTime, depositDoneTime), nil
+	txnResp, err = contracts.Deposit(actx, req.DestinationBank, txn)
+	if err != nil {
+		return contracts.Response{}, err
+	}
+	if txnResp.Status != contracts.TxnSuccess {
+		progress.LastFailure = txnResp.FailureReason
+		outcome, err := refund(dctx, cfg, req, progress, txnResp.FailureReason)
+		if err != nil {
+			return contracts.Response{}, err
 		}
-		_ = workflow.SideEffect(ctx, currentTime).Get(&refundDoneTime)
-		return newResponse("refunded", txnResp.FailureReason, startTime, moneyLaunderingFinishTime, withdrawDoneTime, refundDoneTime, depositDoneTime), nil
+		return newRefundResponse(dctx, progress, outcome), nil
 	}
 
-	_ = workflow.SideEffect(ctx, currentTime).Get(&depositDoneTime)
+	_ = workflow.SideEffect(dctx, currentTime).Get(&progress.DepositDoneTime)
+
+	feeFailure := ""
+	if progress.Fee != nil {
+		setStage(ctx, progress, contracts.StageCollectingFee)
+		feeFailure, err = collectFee(actx, req, *progress.Fee)
+		if err != nil {
+			return contracts.Response{}, err
+		}
+	}
 
-	return newResponse("success", "", startTime, moneyLaunderingFinishTime, withdrawDoneTime, refundDoneTime, depositDoneTime), nil
+	resp := newResponse(dctx, progress, contracts.StatusSuccess, "")
+	resp.FeeCollectionFailure = feeFailure
+	return resp, nil
 }
 
 func currentTime(ctx workflow.Context) interface{} {
 	return time.Now()
 }
 
-func newResponse(status, failureReason string, startTime, moneyLaunderingFinishTime, withdrawDoneTime,
-	refundDoneTime, depositDoneTime time.Time) Response {
+func setStage(ctx workflow.Context, progress *contracts.Progress, stage string) {
+	progress.Stage = stage
+	progress.StageSince = workflow.Now(ctx)
+}
+
+// newResponse also marks the progress completed, so queries made after the result is known agree with it
+func newResponse(ctx workflow.Context, progress *contracts.Progress, status, failureReason string) contracts.Response {
+	setStage(ctx, progress, contracts.StageCompleted)
+	progress.Status = status
 
-	return Response{
+	return contracts.Response{
 		Status:                         status,
 		FailureReason:                  failureReason,
-		StartTime:                      startTime,
-		MoneyLaunderingCheckFinishTime: moneyLaunderingFinishTime,
-		WithdrawDoneTime:               withdrawDoneTime,
-		RefundDoneTime:                 refundDoneTime,
-		DepositDoneTime:                depositDoneTime,
+		ScheduledFor:                   progress.ScheduledFor,
+		StartTime:                      progress.StartTime,
+		MoneyLaunderingCheckFinishTime: progress.MoneyLaunderingCheckFinishTime,
+		WithdrawDoneTime:               progress.WithdrawDoneTime,
+		RefundDoneTime:                 progress.RefundDoneTime,
+		DepositDoneTime:                progress.DepositDoneTime,
+		MoneyLaunderingDecision:        progress.MoneyLaunderingDecision,
+		AMLEscalations:                 progress.AMLEscalations,
+		FXQuote:                        progress.FXQuote,
+		Fee:                            progress.Fee,
 	}
 }
+
+func newRefundResponse(ctx workflow.Context, progress *contracts.Progress, outcome refundOutcome) contracts.Response {
+	resp := newResponse(ctx, progress, outcome.status, outcome.failureReason)
+	resp.RefundAttempts = outcome.attempts
+	resp.RefundResolution = outcome.resolution
+	return resp
+}
@@ -0,0 +1,327 @@
+package contracts
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"go.temporal.io/api/enums/v1"
+	"go.temporal.io/api/serviceerror"
+	workflowpb "go.temporal.io/api/workflow/v1"
+	"go.temporal.io/sdk/client"
+	"go.temporal.io/sdk/converter"
+	"go.temporal.io/sdk/workflow"
+)
+
+const moneyTransferIDPrefix = "MT: "
+
+// MoneyTransferListQuery is the visibility query matching every transfer.
+var MoneyTransferListQuery = fmt.Sprintf("WorkflowType = '%s'", MoneyTransferWorkflow)
+
+var ErrDuplicateReference = errors.New("a transfer with this reference already exists")
+
+func MoneyTransferID(ref string) string {
+	return moneyTransferIDPrefix + ref
+}
+
+// ReferenceFromMoneyTransferID is the inverse of MoneyTransferID.
+func ReferenceFromMoneyTransferID(workflowID string) (string, bool) {
+	return strings.CutPrefix(workflowID, moneyTransferIDPrefix)
+}
+
+// IdempotencyKey identifies a single bank posting across activity retries.
+func IdempotencyKey(workflowID, activityType, reference string) string {
+	return fmt.Sprintf("%s|%s|%s", workflowID, activityType, reference)
+}
+
+// StartMoneyTransfer kicks off a MoneyTransfer on the clearing house, keyed by the request reference. References
+// are never reused; ErrDuplicateReference is returned for one that has been seen before. The transfer is tied to
+// customer, when known, through its memo and CustomerIDSearchAttribute.
+func StartMoneyTransfer(ctx context.Context, c client.Client, req Request, customer string) (client.WorkflowRun, error) {
+	options := client.StartWorkflowOptions{
+		TaskQueue:                                ClearingHouseTaskQueue,
+		ID:                                       MoneyTransferID(req.Ref),
+		WorkflowIDReusePolicy:                    enums.WORKFLOW_ID_REUSE_POLICY_REJECT_DUPLICATE,
+		WorkflowExecutionErrorWhenAlreadyStarted: true,
+	}
+	if customer != "" {
+		options.Memo = map[string]interface{}{CustomerMemo: customer}
+		options.SearchAttributes = map[string]interface{}{CustomerIDSearchAttribute: customer}
+	}
+	run, err := c.ExecuteWorkflow(ctx, options, MoneyTransferWorkflow, req)
+	var alreadyStarted *serviceerror.WorkflowExecutionAlreadyStarted
+	if errors.As(err, &alreadyStarted) {
+		return nil, ErrDuplicateReference
+	}
+	return run, err
+}
+
+// MoneyTransferCustomer is the customer a transfer was started for, empty when it was started anonymously
+func MoneyTransferCustomer(info *workflowpb.WorkflowExecutionInfo) string {
+	payload, ok := info.GetMemo().GetFields()[CustomerMemo]
+	if !ok {
+		return ""
+	}
+	customer := ""
+	if err := converter.GetDefaultDataConverter().FromPayload(payload, &customer); err != nil {
+		return ""
+	}
+	return customer
+}
+
+// CustomerTransfersQuery is the visibility query matching the transfers of customer.
+func CustomerTransfersQuery(customer string) (string, error) {
+	if strings.ContainsAny(customer, `'"\`) {
+		return "", fmt.Errorf("customer %q cannot be queried", customer)
+	}
+	return fmt.Sprintf("%s AND %s = '%s'", MoneyTransferListQuery, CustomerIDSearchAttribute, customer), nil
+}
+
+// GetMoneyTransferResult blocks until the transfer identified by ref (and optionally runID) completes.
+func GetMoneyTransferResult(ctx context.Context, c client.Client, ref, runID string) (Response, error) {
+	resp := Response{}
+	err := c.GetWorkflow(ctx, MoneyTransferID(ref), runID).Get(ctx, &resp)
+	return resp, err
+}
+
+func QueryMoneyTransferProgress(ctx context.Context, c client.Client, ref string) (Progress, error) {
+	progress := Progress{}
+	value, err := c.QueryWorkflow(ctx, MoneyTransferID(ref), "", ProgressQuery)
+	if err != nil {
+		return progress, err
+	}
+	err = value.Get(&progress)
+	return progress, err
+}
+
+var ErrNotCancellable = errors.New("transfer can no longer be cancelled")
+
+// CancelMoneyTransfer requests cancellation of a transfer that has not reached its deposit yet. The outcome is
+// reported in the transfer's Response: cancelled, or whatever the transfer completed with if the cancellation
+// lost the race with the deposit.
+func CancelMoneyTransfer(ctx context.Context, c client.Client, ref string) error {
+	progress, err := QueryMoneyTransferProgress(ctx, c, ref)
+	if err != nil {
+		return err
+	}
+	if !progress.Cancellable() {
+		return ErrNotCancellable
+	}
+	return c.CancelWorkflow(ctx, MoneyTransferID(ref), "")
+}
+
+var ErrNotScheduled = errors.New("transfer is no longer waiting to be due")
+
+// EditScheduledTransfer changes a transfer that is waiting for its execution time. An edit that arrives after the
+// transfer fell due is ignored.
+func EditScheduledTransfer(ctx context.Context, c client.Client, ref string, edit ScheduleEdit) error {
+	progress, err := QueryMoneyTransferProgress(ctx, c, ref)
+	if err != nil {
+		return err
+	}
+	if progress.Stage != StageScheduled {
+		return ErrNotScheduled
+	}
+	return c.SignalWorkflow(ctx, MoneyTransferID(ref), "", EditScheduleSignal, edit)
+}
+
+const standingOrderIDPrefix = "SO: "
+
+// StandingOrderListQuery is the visibility query matching every standing order.
+var StandingOrderListQuery = fmt.Sprintf("WorkflowType = '%s'", StandingOrderWorkflow)
+
+var ErrDuplicateStandingOrder = errors.New("a standing order with this reference already exists")
+
+func StandingOrderID(ref string) string {
+	return standingOrderIDPrefix + ref
+}
+
+// ReferenceFromStandingOrderID is the inverse of StandingOrderID.
+func ReferenceFromStandingOrderID(workflowID string) (string, bool) {
+	return strings.CutPrefix(workflowID, standingOrderIDPrefix)
+}
+
+// StandingOrderTransferRef is the reference of the transfer made for occurrence n of the standing order ref.
+// Customers cannot choose references containing #, so these are not taken by other transfers.
+func StandingOrderTransferRef(ref string, n int) string {
+	return fmt.Sprintf("%s#%d", ref, n+1)
+}
+
+// CustomerStandingOrdersQuery is the visibility query matching the standing orders of customer.
+func CustomerStandingOrdersQuery(customer string) (string, error) {
+	if strings.ContainsAny(customer, `'"\`) {
+		return "", fmt.Errorf("customer %q cannot be queried", customer)
+	}
+	return fmt.Sprintf("%s AND %s = '%s'", StandingOrderListQuery, CustomerIDSearchAttribute, customer), nil
+}
+
+// StartStandingOrder sets up a StandingOrder on the clearing house, keyed by its reference, which is never
+// reused. Like a transfer, it is tied to its customer through its memo and CustomerIDSearchAttribute.
+func StartStandingOrder(ctx context.Context, c client.Client, order StandingOrder) (client.WorkflowRun, error) {
+	options := client.StartWorkflowOptions{
+		TaskQueue:                                ClearingHouseTaskQueue,
+		ID:                                       StandingOrderID(order.Ref),
+		WorkflowIDReusePolicy:                    enums.WORKFLOW_ID_REUSE_POLICY_REJECT_DUPLICATE,
+		WorkflowExecutionErrorWhenAlreadyStarted: true,
+	}
+	if order.Customer != "" {
+		options.Memo = map[string]interface{}{CustomerMemo: order.Customer}
+		options.SearchAttributes = map[string]interface{}{CustomerIDSearchAttribute: order.Customer}
+	}
+	run, err := c.ExecuteWorkflow(ctx, options, StandingOrderWorkflow, StandingOrderState{Order: order})
+	var alreadyStarted *serviceerror.WorkflowExecutionAlreadyStarted
+	if errors.As(err, &alreadyStarted) {
+		return nil, ErrDuplicateStandingOrder
+	}
+	return run, err
+}
+
+func QueryStandingOrder(ctx context.Context, c client.Client, ref string) (StandingOrderState, error) {
+	result := StandingOrderState{}
+	value, err := c.QueryWorkflow(ctx, StandingOrderID(ref), "", StandingOrderQuery)
+	if err != nil {
+		return result, err
+	}
+	err = value.Get(&result)
+	return result, err
+}
+
+// GetStandingOrderResult blocks until the standing order ref has ended, and answers its final state.
+func GetStandingOrderResult(ctx context.Context, c client.Client, ref, runID string) (StandingOrderState, error) {
+	result := StandingOrderState{}
+	err := c.GetWorkflow(ctx, StandingOrderID(ref), runID).Get(ctx, &result)
+	return result, err
+}
+
+// PauseStandingOrder skips the occurrences that fall due until it is resumed. A transfer already under way is
+// not affected.
+func PauseStandingOrder(ctx context.Context, c client.Client, ref, editor string) error {
+	return c.SignalWorkflow(ctx, StandingOrderID(ref), "", PauseStandingOrderSignal, editor)
+}
+
+func ResumeStandingOrder(ctx context.Context, c client.Client, ref, editor string) error {
+	return c.SignalWorkflow(ctx, StandingOrderID(ref), "", ResumeStandingOrderSignal, editor)
+}
+
+func AmendStandingOrder(ctx context.Context, c client.Client, ref string, amendment StandingOrderAmendment) error {
+	return c.SignalWorkflow(ctx, StandingOrderID(ref), "", AmendStandingOrderSignal, amendment)
+}
+
+// CancelStandingOrder ends a standing order. A transfer already under way runs to completion; it can be
+// cancelled on its own.
+func CancelStandingOrder(ctx context.Context, c client.Client, ref string) error {
+	return c.CancelWorkflow(ctx, StandingOrderID(ref), "")
+}
+
+var ErrNotAwaitingRefundResolution = errors.New("transfer is not waiting for a refund resolution")
+
+// ResolveRefund tells a transfer awaiting manual intervention how to proceed with its refund.
+func ResolveRefund(ctx context.Context, c client.Client, ref string, resolution RefundResolution) error {
+	progress, err := QueryMoneyTransferProgress(ctx, c, ref)
+	if err != nil {
+		return err
+	}
+	if progress.Stage != StageAwaitingRefundResolution {
+		return ErrNotAwaitingRefundResolution
+	}
+	return c.SignalWorkflow(ctx, MoneyTransferID(ref), "", RefundResolutionSignal, resolution)
+}
+
+// PendingAMLReviewsQuery is the visibility query matching transfers waiting for an AMLDecisionSignal.
+var PendingAMLReviewsQuery = fmt.Sprintf("WorkflowType = '%s' AND ExecutionStatus = 'Running' AND %s IN ('%s', '%s')",
+	MoneyTransferWorkflow, AMLReviewStatusSearchAttribute, AMLReviewStatusPending, AMLReviewStatusEscalated)
+
+func QueryAMLReview(ctx context.Context, c client.Client, ref string) (AMLReviewCase, error) {
+	result := AMLReviewCase{}
+	value, err := c.QueryWorkflow(ctx, MoneyTransferID(ref), "", AMLReviewQuery)
+	if err != nil {
+		return result, err
+	}
+	err = value.Get(&result)
+	return result, err
+}
+
+// NotifyAMLReviewOpened tells a transfer that its MoneyLaunderingCheck is waiting for a reviewer.
+func NotifyAMLReviewOpened(ctx context.Context, c client.Client, ref string) error {
+	return c.SignalWorkflow(ctx, MoneyTransferID(ref), "", AMLReviewOpenedSignal, nil)
+}
+
+// SendAMLDecision hands a reviewer's decision to a transfer reviewed with signals.
+func SendAMLDecision(ctx context.Context, c client.Client, ref string, decision AMLDecision) error {
+	return c.SignalWorkflow(ctx, MoneyTransferID(ref), "", AMLDecisionSignal, decision)
+}
+
+// The workflow side stubs below route the activity to the owning team's task queue. Timeouts and retry
+// policies are left to the caller's activity options.
+
+func Withdraw(ctx workflow.Context, bank string, txn BankTransaction) (TxnResponse, error) {
+	return executeBankActivity(ctx, bank, WithdrawActivity, txn)
+}
+
+func Deposit(ctx workflow.Context, bank string, txn BankTransaction) (TxnResponse, error) {
+	return executeBankActivity(ctx, bank, DepositActivity, txn)
+}
+
+func executeBankActivity(ctx workflow.Context, bank, activity string, txn BankTransaction) (TxnResponse, error) {
+	if txn.IdempotencyKey == "" {
+		txn.IdempotencyKey = IdempotencyKey(workflow.GetInfo(ctx).WorkflowExecution.ID, activity, txn.Reference)
+	}
+	ctx = workflow.WithTaskQueue(ctx, bank)
+	resp := TxnResponse{}
+	err := workflow.ExecuteActivity(ctx, activity, txn).Get(ctx, &resp)
+	return resp, err
+}
+
+// MoneyLaunderingCheck decides on a transfer. Any sanctions hits send it to a reviewer.
+func MoneyLaunderingCheck(ctx workflow.Context, req Request, hits []SanctionsHit) (AMLDecision, error) {
+	ctx = workflow.WithTaskQueue(ctx, MoneyLaunderingTaskQueue)
+	result := AMLDecision{}
+	err := workflow.ExecuteActivity(ctx, MoneyLaunderingCheckActivity, req, hits).Get(ctx, &result)
+	return result, err
+}
+
+// ScreenTransfer asks the money laundering service for its automatic assessment of a transfer.
+func ScreenTransfer(ctx workflow.Context, req Request, hits []SanctionsHit) (AMLScreening, error) {
+	ctx = workflow.WithTaskQueue(ctx, MoneyLaunderingTaskQueue)
+	result := AMLScreening{}
+	err := workflow.ExecuteActivity(ctx, AMLScreenActivity, req, hits).Get(ctx, &result)
+	return result, err
+}
+
+// SanctionsScreen checks both parties to a transfer against the watch list.
+func SanctionsScreen(ctx workflow.Context, req Request) ([]SanctionsHit, error) {
+	ctx = workflow.WithTaskQueue(ctx, MoneyLaunderingTaskQueue)
+	result := []SanctionsHit{}
+	err := workflow.ExecuteActivity(ctx, SanctionsScreenActivity, req).Get(ctx, &result)
+	return result, err
+}
+
+// NotifyAMLReview tells the money laundering service about an SLA event on a review. activity is one of
+// AMLReviewReminderActivity, EscalateAMLReviewActivity and ExpireAMLReviewActivity.
+func NotifyAMLReview(ctx workflow.Context, activity string, notice AMLReviewNotice) error {
+	ctx = workflow.WithTaskQueue(ctx, MoneyLaunderingTaskQueue)
+	return workflow.ExecuteActivity(ctx, activity, notice).Get(ctx, nil)
+}
+
+// IndicativeFXRate converts an amount at the current rate without locking it.
+func IndicativeFXRate(ctx workflow.Context, req FXQuoteRequest) (FXQuote, error) {
+	return executeFXActivity(ctx, IndicativeFXRateActivity, req)
+}
+
+// LockFXRate fixes the rate of a conversion until the quote expires.
+func LockFXRate(ctx workflow.Context, req FXQuoteRequest) (FXQuote, error) {
+	return executeFXActivity(ctx, LockFXRateActivity, req)
+}
+
+func executeFXActivity(ctx workflow.Context, activity string, req FXQuoteRequest) (FXQuote, error) {
+	ctx = workflow.WithTaskQueue(ctx, FXTaskQueue)
+	result := FXQuote{}
+	err := workflow.ExecuteActivity(ctx, activity, req).Get(ctx, &result)
+	return result, err
+}
+
+func NotifyCustomer(ctx workflow.Context, n CustomerNotification) error {
+	ctx = workflow.WithTaskQueue(ctx, CustomerTaskQueue)
+	return workflow.ExecuteActivity(ctx, NotifyCustomerActivity, n).Get(ctx, nil)
+}
@@ -0,0 +1,149 @@
+package contracts
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+)
+
+// Money is an exact amount: a whole number of the minor units (e.g. cents) of Currency, an ISO 4217 code. It is
+// encoded, in JSON and so in Temporal payloads, as {"amount": "12.34", "currency": "EUR"}.
+type Money struct {
+	Minor    int64
+	Currency string
+}
+
+var ErrInvalidMoney = errors.New("invalid amount")
+
+// minorUnitDigits lists the currencies whose minor unit is not a hundredth
+var minorUnitDigits = map[string]int{
+	"BHD": 3, "IQD": 3, "JOD": 3, "KWD": 3, "LYD": 3, "OMR": 3, "TND": 3,
+	"CLP": 0, "ISK": 0, "JPY": 0, "KRW": 0, "PYG": 0, "UGX": 0, "VND": 0,
+}
+
+// MinorUnitDigits is the number of decimal places of currency
+func MinorUnitDigits(currency string) int {
+	if digits, ok := minorUnitDigits[currency]; ok {
+		return digits
+	}
+	return 2
+}
+
+// ParseMoney reads a plain decimal amount, such as "1234.5", in currency. Signs, exponents, separators and more
+// decimal places than the currency has are rejected.
+func ParseMoney(amount, currency string) (Money, error) {
+	return parseMoney(amount, currency, false)
+}
+
+func parseMoney(amount, currency string, allowNegative bool) (Money, error) {
+	digits := MinorUnitDigits(currency)
+	negative := false
+	if allowNegative && strings.HasPrefix(amount, "-") {
+		negative = true
+		amount = amount[1:]
+	}
+	whole, fraction, hasPoint := strings.Cut(amount, ".")
+	if whole == "" || (hasPoint && fraction == "") || !isDigits(whole) || !isDigits(fraction) {
+		return Money{}, fmt.Errorf("%w: %q is not a plain decimal number", ErrInvalidMoney, amount)
+	}
+	if len(fraction) > digits {
+		return Money{}, fmt.Errorf("%w: %s has at most %d decimal places", ErrInvalidMoney, currency, digits)
+	}
+	// the magnitude may be one more than math.MaxInt64 when negative
+	limit := uint64(math.MaxInt64)
+	if negative {
+		limit++
+	}
+	var magnitude uint64
+	for _, c := range whole + fraction + strings.Repeat("0", digits-len(fraction)) {
+		d := uint64(c - '0')
+		if magnitude > (limit-d)/10 {
+			return Money{}, fmt.Errorf("%w: %q is too large", ErrInvalidMoney, amount)
+		}
+		magnitude = magnitude*10 + d
+	}
+	minor := int64(magnitude)
+	if negative {
+		minor = -minor
+	}
+	return Money{Minor: minor, Currency: currency}, nil
+}
+
+func isDigits(s string) bool {
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// MoneyFromFloat rounds amount to the nearest minor unit of currency. Only for values that are inexact anyway,
+// such as the result of a currency conversion.
+func MoneyFromFloat(amount float64, currency string) Money {
+	return Money{Minor: int64(math.Round(amount * math.Pow10(MinorUnitDigits(currency)))), Currency: currency}
+}
+
+// Float64 approximates the amount in major units, for scoring and thresholds; never for posting money.
+func (m Money) Float64() float64 {
+	return float64(m.Minor) / math.Pow10(MinorUnitDigits(m.Currency))
+}
+
+// Decimal is the amount in major units without the currency, e.g. 1234.50
+func (m Money) Decimal() string {
+	digits := MinorUnitDigits(m.Currency)
+	// negated as unsigned, which holds the magnitude of math.MinInt64
+	sign, magnitude := "", uint64(m.Minor)
+	if m.Minor < 0 {
+		sign, magnitude = "-", -magnitude
+	}
+	s := fmt.Sprintf("%0*d", digits+1, magnitude)
+	if digits == 0 {
+		return sign + s
+	}
+	return sign + s[:len(s)-digits] + "." + s[len(s)-digits:]
+}
+
+func (m Money) String() string {
+	return strings.TrimSpace(m.Decimal() + " " + m.Currency)
+}
+
+func (m Money) IsZero() bool {
+	return m.Minor == 0
+}
+
+// Add sums amounts of the same currency
+func (m Money) Add(other Money) (Money, error) {
+	if m.Currency != other.Currency {
+		return Money{}, fmt.Errorf("cannot add %s to %s", other.Currency, m.Currency)
+	}
+	return Money{Minor: m.Minor + other.Minor, Currency: m.Currency}, nil
+}
+
+type moneyJSON struct {
+	Amount   string `json:"amount"`
+	Currency string `json:"currency"`
+}
+
+func (m Money) MarshalJSON() ([]byte, error) {
+	return json.Marshal(moneyJSON{Amount: m.Decimal(), Currency: m.Currency})
+}
+
+func (m *Money) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		*m = Money{}
+		return nil
+	}
+	v := moneyJSON{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	parsed, err := parseMoney(v.Amount, v.Currency, true)
+	if err != nil {
+		return err
+	}
+	*m = parsed
+	return nil
+}
@@ -0,0 +1,140 @@
+package contracts
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestParseMoney(t *testing.T) {
+	tests := []struct {
+		amount   string
+		currency string
+		want     int64
+		wantErr  bool
+	}{
+		{amount: "0", currency: "USD", want: 0},
+		{amount: "12", currency: "USD", want: 1200},
+		{amount: "12.3", currency: "USD", want: 1230},
+		{amount: "12.34", currency: "USD", want: 1234},
+		{amount: "007.50", currency: "EUR", want: 750},
+		{amount: "12.345", currency: "USD", wantErr: true},
+		{amount: "1234", currency: "JPY", want: 1234},
+		{amount: "1234.0", currency: "JPY", wantErr: true},
+		{amount: "1.234", currency: "KWD", want: 1234},
+		{amount: "1.2", currency: "KWD", want: 1200},
+		{amount: "1.2345", currency: "KWD", wantErr: true},
+		{amount: "1.", currency: "USD", wantErr: true},
+		{amount: ".5", currency: "USD", wantErr: true},
+		{amount: "-1", currency: "USD", wantErr: true},
+		{amount: "+1", currency: "USD", wantErr: true},
+		{amount: "", currency: "USD", wantErr: true},
+		{amount: " 1", currency: "USD", wantErr: true},
+		{amount: "1e3", currency: "USD", wantErr: true},
+		{amount: "1,000", currency: "USD", wantErr: true},
+		{amount: "1.2.3", currency: "USD", wantErr: true},
+		{amount: "9223372036854775807", currency: "JPY", want: math.MaxInt64},
+		{amount: "9223372036854775808", currency: "JPY", wantErr: true},
+		{amount: "92233720368547758.07", currency: "USD", want: math.MaxInt64},
+		{amount: "92233720368547758.08", currency: "USD", wantErr: true},
+		{amount: "99999999999999999999", currency: "USD", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.currency+" "+tt.amount, func(t *testing.T) {
+			got, err := ParseMoney(tt.amount, tt.currency)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidMoney) {
+					t.Fatalf("got %v, %v; want ErrInvalidMoney", got, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := (Money{Minor: tt.want, Currency: tt.currency}); got != want {
+				t.Fatalf("got %+v, want %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestMoneyRoundTrip(t *testing.T) {
+	tests := []struct {
+		money   Money
+		decimal string
+	}{
+		{money: Money{Minor: 0, Currency: "USD"}, decimal: "0.00"},
+		{money: Money{Minor: 5, Currency: "USD"}, decimal: "0.05"},
+		{money: Money{Minor: 123456, Currency: "EUR"}, decimal: "1234.56"},
+		{money: Money{Minor: -5, Currency: "USD"}, decimal: "-0.05"},
+		{money: Money{Minor: 1234, Currency: "JPY"}, decimal: "1234"},
+		{money: Money{Minor: -1234, Currency: "JPY"}, decimal: "-1234"},
+		{money: Money{Minor: 1, Currency: "KWD"}, decimal: "0.001"},
+		{money: Money{Minor: 12345, Currency: "KWD"}, decimal: "12.345"},
+		{money: Money{Minor: math.MaxInt64, Currency: "USD"}, decimal: "92233720368547758.07"},
+		{money: Money{Minor: math.MinInt64, Currency: "USD"}, decimal: "-92233720368547758.08"},
+		{money: Money{Minor: math.MinInt64, Currency: "JPY"}, decimal: "-9223372036854775808"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.money.Currency+" "+tt.decimal, func(t *testing.T) {
+			if got := tt.money.Decimal(); got != tt.decimal {
+				t.Fatalf("Decimal() = %s, want %s", got, tt.decimal)
+			}
+			if tt.money.Minor >= 0 {
+				parsed, err := ParseMoney(tt.decimal, tt.money.Currency)
+				if err != nil || parsed != tt.money {
+					t.Fatalf("ParseMoney(%s) = %+v, %v; want %+v", tt.decimal, parsed, err, tt.money)
+				}
+			}
+			encoded, err := json.Marshal(tt.money)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := `{"amount":"` + tt.decimal + `","currency":"` + tt.money.Currency + `"}`
+			if string(encoded) != want {
+				t.Fatalf("encoded as %s, want %s", encoded, want)
+			}
+			var decoded Money
+			if err := json.Unmarshal(encoded, &decoded); err != nil {
+				t.Fatal(err)
+			}
+			if decoded != tt.money {
+				t.Fatalf("decoded %+v, want %+v", decoded, tt.money)
+			}
+		})
+	}
+}
+
+func TestUnmarshalMoney(t *testing.T) {
+	tests := []struct {
+		json    string
+		want    Money
+		wantErr bool
+	}{
+		{json: `null`, want: Money{}},
+		{json: `{"amount": "1.50", "currency": "GBP"}`, want: Money{Minor: 150, Currency: "GBP"}},
+		{json: `{"amount": "1.505", "currency": "GBP"}`, wantErr: true},
+		{json: `{"amount": "--1", "currency": "GBP"}`, wantErr: true},
+		{json: `{"amount": 1.5, "currency": "GBP"}`, wantErr: true},
+		{json: `{"amount": "-92233720368547758.09", "currency": "USD"}`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.json, func(t *testing.T) {
+			got := Money{Minor: 1, Currency: "XXX"}
+			err := json.Unmarshal([]byte(tt.json), &got)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("got %+v, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Fatalf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
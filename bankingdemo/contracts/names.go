@@ -0,0 +1,169 @@
+// Package contracts holds the types and names shared between the banking demo teams.
+//
+// Every workflow, activity, signal and task queue that crosses a team boundary is declared here so that a
+// rename breaks the build rather than a running transfer. Changes to the payload types must stay backwards
+// compatible with in-flight workflows; bump Version when that is not possible.
+package contracts
+
+const Version = 2 // 2: amounts are Money
+
+// Task queues
+const (
+	ClearingHouseTaskQueue   = "clearing-house"
+	MoneyLaunderingTaskQueue = "money-laundering"
+	CustomerTaskQueue        = "customer"
+	FXTaskQueue              = "fx"
+	ABBankTaskQueue          = "abbank"
+	BCBankTaskQueue          = "bcbank"
+)
+
+// Workflows
+const (
+	MoneyTransferWorkflow = "MoneyTransfer"
+	// StandingOrderWorkflow runs a MoneyTransfer child for every occurrence of a StandingOrder
+	StandingOrderWorkflow = "StandingOrder"
+)
+
+// Queries
+const (
+	ProgressQuery = "progress"
+	// AMLReviewQuery answers the AMLReviewCase of a transfer waiting for an AMLDecisionSignal
+	AMLReviewQuery = "aml-review"
+	// StandingOrderQuery answers the StandingOrderState of a standing order
+	StandingOrderQuery = "standing-order"
+)
+
+// Transfer stages reported by the progress query
+const (
+	StageScheduled                = "scheduled" // waiting for its execution time
+	StageStarted                  = "started"
+	StageSanctionsScreening       = "sanctions-screening"
+	StageMoneyLaunderingReview    = "aml-review"
+	StageLockingFXRate            = "locking-fx-rate"
+	StageWithdrawing              = "withdrawing"
+	StageDepositing               = "depositing"
+	StageCollectingFee            = "collecting-fee"
+	StageRefunding                = "refunding"
+	StageAwaitingRefundResolution = "awaiting-refund-resolution"
+	StageCompleted                = "completed"
+)
+
+// Signals
+const (
+	RefundResolutionSignal = "refund-resolution"
+	// AMLDecisionSignal carries a reviewer's AMLDecision when reviews are run with signals
+	AMLDecisionSignal = "aml-decision"
+	// AMLReviewOpenedSignal tells a transfer that its MoneyLaunderingCheck waits for a reviewer, which starts the
+	// review SLA
+	AMLReviewOpenedSignal = "aml-review-opened"
+	// EditScheduleSignal carries a customer's ScheduleEdit to a transfer that is not due yet
+	EditScheduleSignal = "edit-schedule"
+	// PauseStandingOrderSignal and ResumeStandingOrderSignal carry the name of whoever paused or resumed it
+	PauseStandingOrderSignal  = "pause-standing-order"
+	ResumeStandingOrderSignal = "resume-standing-order"
+	// AmendStandingOrderSignal carries a StandingOrderAmendment
+	AmendStandingOrderSignal = "amend-standing-order"
+)
+
+// AML review modes. With activity the money laundering service holds the review and completes the
+// MoneyLaunderingCheck activity; with signal the transfer holds it and reviewers send an AMLDecisionSignal.
+const (
+	AMLReviewModeActivity = "activity"
+	AMLReviewModeSignal   = "signal"
+)
+
+// AMLReviewStatusSearchAttribute is a Keyword search attribute kept up to date by transfers reviewed with
+// signals, holding one of the AMLReviewStatus constants.
+const AMLReviewStatusSearchAttribute = "AMLReviewStatus"
+
+// CustomerIDSearchAttribute is a Keyword search attribute holding the customer who started a transfer, set when
+// the customer signed in. The same identity is kept in the CustomerMemo field of the transfer's memo.
+const CustomerIDSearchAttribute = "CustomerID"
+
+const CustomerMemo = "customer"
+
+const (
+	AMLReviewStatusPending   = "pending"
+	AMLReviewStatusEscalated = "escalated"
+	AMLReviewStatusDecided   = "decided"
+	AMLReviewStatusExpired   = "expired"
+)
+
+// FXUnknownCurrencyError is the type of the non-retryable error the FX activities fail with for a currency they
+// do not quote
+const FXUnknownCurrencyError = "UnknownCurrency"
+
+// Refund resolution actions, see RefundResolution
+const (
+	RefundRetry    = "retry"
+	RefundForce    = "force-refund"
+	RefundWriteOff = "write-off"
+)
+
+// Activities
+const (
+	WithdrawActivity             = "Withdraw"
+	DepositActivity              = "Deposit"
+	MoneyLaunderingCheckActivity = "MoneyLaunderingCheck"
+	SanctionsScreenActivity      = "SanctionsScreen"
+	AMLScreenActivity            = "AMLScreen"
+	AMLReviewReminderActivity    = "AMLReviewReminder"
+	EscalateAMLReviewActivity    = "EscalateAMLReview"
+	ExpireAMLReviewActivity      = "ExpireAMLReview"
+	NotifyCustomerActivity       = "NotifyCustomer"
+	IndicativeFXRateActivity     = "IndicativeFXRate"
+	LockFXRateActivity           = "LockFXRate"
+)
+
+// AML review SLA events, see AMLEscalation
+const (
+	AMLReviewReminded  = "reminder"
+	AMLReviewEscalated = "escalated" // moved to the senior reviewers' queue
+	AMLReviewExpired   = "expired"   // the default outcome was applied
+)
+
+// Transfer statuses
+const (
+	StatusSuccess  = "success"
+	StatusFailure  = "failure"
+	StatusRefunded = "refunded"
+	// the deposit and the refund both failed and an operator wrote the money off
+	StatusRefundFailed = "refund_failed"
+	// cancelled by the customer before the deposit; any withdrawal has been refunded
+	StatusCancelled = "cancelled"
+)
+
+// Standing order statuses
+const (
+	StandingOrderActive    = "active"
+	StandingOrderPaused    = "paused"
+	StandingOrderCompleted = "completed" // past its end date or occurrence count
+	StandingOrderCancelled = "cancelled"
+)
+
+// Standing order occurrences that made no transfer; the others have the status of their transfer
+const (
+	// OccurrenceSkipped fell due while the order was paused
+	OccurrenceSkipped = "skipped"
+	// OccurrenceNotStarted could not start its transfer because another workflow holds its ID
+	OccurrenceNotStarted = "not_started"
+)
+
+// Recurrence frequencies
+const (
+	FrequencyDaily   = "daily"
+	FrequencyWeekly  = "weekly"
+	FrequencyMonthly = "monthly"
+)
+
+// Bank transaction statuses
+const (
+	TxnSuccess = "success"
+	TxnFailure = "failure"
+)
+
+// Money laundering check outcomes
+const (
+	MoneyLaunderingApprove = "approve"
+	MoneyLaunderingReject  = "reject"
+)
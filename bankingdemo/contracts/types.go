@@ -0,0 +1,349 @@
+package contracts
+
+import (
+	"fmt"
+	"time"
+)
+
+type Request struct {
+	SourceBank, DestinationBank string
+	SourceAcc, DestinationAcc   string
+	// SourceName and DestinationName are the account holders' names, optional, used for sanctions screening
+	SourceName, DestinationName string
+	Amount                      Money // taken from the source account, in its currency
+	Ref                         string
+	// DestinationCurrency is what the destination account is credited in; Amount is converted when it differs
+	DestinationCurrency string
+	// ReferenceAmount is Amount in the FX service's reference currency, set by MoneyTransfer before screening so
+	// that AML thresholds apply the same whatever the currency
+	ReferenceAmount Money
+	// AcceptedFee is the fee the customer was quoted, if any; the transfer fails rather than charge more
+	AcceptedFee *Money
+	// ExecuteAt future-dates the transfer: it waits until then, moved to the next business day
+	ExecuteAt time.Time
+}
+
+// ScheduleEdit changes a transfer that is not due yet. Fields left at their zero value are not changed; a new
+// Amount must be in the same currency; a nil AcceptedFee keeps the one the customer agreed to before.
+type ScheduleEdit struct {
+	ExecuteAt   time.Time
+	Amount      Money
+	AcceptedFee *Money
+	Editor      string
+}
+
+// AMLAmount is the amount AML thresholds are evaluated against: the reference amount when known.
+func (r Request) AMLAmount() Money {
+	if r.ReferenceAmount.Currency != "" {
+		return r.ReferenceAmount
+	}
+	return r.Amount
+}
+
+type Response struct {
+	Status                         string    // success, failure, refunded, refund_failed, cancelled
+	FailureReason                  string    // populated for failure, refunded, refund_failed and cancelled
+	ScheduledFor                   time.Time // set for a future-dated transfer
+	StartTime                      time.Time // when the transfer got under way, once due
+	MoneyLaunderingCheckFinishTime time.Time
+	WithdrawDoneTime               time.Time
+	RefundDoneTime                 time.Time
+	DepositDoneTime                time.Time
+	RefundAttempts                 int
+	RefundResolution               *RefundResolution // set when an operator had to step in
+	MoneyLaunderingDecision        *AMLDecision      // set when the transfer needed a money laundering check
+	AMLEscalations                 []AMLEscalation   // reminders and escalations while the AML review was waiting
+	FXQuote                        *FXQuote          // the locked rate, set when the currencies differ
+	Fee                            *FeeQuote         // set when the transfer was charged a fee
+	FeeCollectionFailure           string            // set when a fee was withdrawn but not credited to the revenue account
+}
+
+// AMLDecision is the result of the money laundering check.
+type AMLDecision struct {
+	Decision   string // approve, reject
+	Reviewer   string
+	ReasonCode string
+	Comment    string
+	DecidedAt  time.Time
+	// Approvers lists every reviewer who approved when dual control applied, in order
+	Approvers []string
+	// RiskScore and TriggeredRules are the rules engine's assessment the decision was based on
+	RiskScore      int
+	TriggeredRules []string
+}
+
+// AMLScreening is the money laundering service's automatic assessment of a transfer. Decision is nil when a
+// reviewer is needed.
+type AMLScreening struct {
+	Decision          *AMLDecision
+	RiskScore         int
+	TriggeredRules    []AMLRule
+	ApprovalsRequired int // 1, or 2 under dual control
+}
+
+type AMLRule struct {
+	Name   string
+	Score  int
+	Detail string
+}
+
+// AMLReviewCase is what a reviewer sees of a transfer waiting for an AMLDecisionSignal.
+type AMLReviewCase struct {
+	Request       Request
+	SanctionsHits []SanctionsHit
+	Screening     AMLScreening
+	Since         time.Time
+	Approvals     []AMLDecision // collected so far under dual control
+	Reminders     int
+	Escalated     bool
+	Open          bool // false once decided or expired
+	// Decision is the one that closed the review
+	Decision *AMLDecision
+	// Ignored lists the latest decisions the transfer would not accept
+	Ignored []IgnoredAMLDecision
+}
+
+type IgnoredAMLDecision struct {
+	Decision AMLDecision
+	Problem  string
+}
+
+// DecisionOutcome tells whether the transfer has acted on d, a decision sent to it, and what became of it:
+// counted, or ignored for problem. Decisions are told apart by reviewer and DecidedAt.
+func (c AMLReviewCase) DecisionOutcome(d AMLDecision) (handled, counted bool, problem string) {
+	same := func(other AMLDecision) bool {
+		return other.Reviewer == d.Reviewer && other.DecidedAt.Equal(d.DecidedAt)
+	}
+	for _, ignored := range c.Ignored {
+		if same(ignored.Decision) {
+			return true, false, ignored.Problem
+		}
+	}
+	if c.Decision != nil && same(*c.Decision) {
+		return true, true, ""
+	}
+	for _, a := range c.Approvals {
+		if same(a) {
+			return true, true, ""
+		}
+	}
+	if !c.Open {
+		// closed without it, by another decision or the review SLA
+		return true, false, "review is closed"
+	}
+	return false, false, ""
+}
+
+// AMLEscalation is an event of the AML review SLA: a reminder to reviewers, an escalation to senior reviewers or
+// the expiry of the review.
+type AMLEscalation struct {
+	Event string // see the AMLReview constants
+	At    time.Time
+	// Reminder counts the reminders sent so far
+	Reminder int
+}
+
+// AMLReviewNotice tells the money laundering service about an SLA event on the review of Ref.
+type AMLReviewNotice struct {
+	Ref     string
+	Event   AMLEscalation
+	Outcome string // set on expiry: the decision applied in place of a reviewer's
+}
+
+// CustomerNotification is a message to the customer who made the transfer Ref.
+type CustomerNotification struct {
+	Ref     string
+	Message string
+}
+
+// SanctionsHit is a party to a transfer that matched a watch-list entry.
+type SanctionsHit struct {
+	Party      string // source, destination
+	EntryID    string
+	EntryName  string
+	MatchedOn  string  // account, name
+	Value      string  // the account or name that matched
+	Similarity float64 // 1 for an exact match
+}
+
+// FXQuoteRequest asks the FX service to convert Amount to another currency. An empty To is the service's
+// reference currency.
+type FXQuoteRequest struct {
+	Amount Money
+	To     string
+	Ref    string
+}
+
+// FXQuote is a conversion at Rate (units of the converted currency per unit of Amount's), rounded to the minor
+// unit. A locked quote has an ID and is honoured until ExpiresAt; an indicative one has neither.
+type FXQuote struct {
+	ID              string
+	Rate            float64
+	Amount          Money
+	ConvertedAmount Money
+	QuotedAt        time.Time
+	ExpiresAt       time.Time
+}
+
+// FeeQuote is what the clearing house charges for a transfer, on top of its amount and in its currency. A fee
+// is withdrawn with the amount, credited to RevenueBank/RevenueAccount once the deposit succeeds and refunded
+// with the amount otherwise.
+type FeeQuote struct {
+	Items          []FeeItem
+	Total          Money
+	RevenueBank    string
+	RevenueAccount string
+}
+
+type FeeItem struct {
+	Name   string
+	Amount Money // negative for a cap
+}
+
+// Progress is the answer to ProgressQuery: where a transfer is and what has happened so far.
+type Progress struct {
+	Stage                          string // see the Stage constants
+	StageSince                     time.Time
+	Status                         string  // the final status once Stage is completed
+	Request                        Request // as edited while it was scheduled
+	ScheduledFor                   time.Time
+	ScheduleEdits                  int
+	StartTime                      time.Time
+	MoneyLaunderingCheckFinishTime time.Time
+	MoneyLaunderingDecision        *AMLDecision // nil until decided or when no check was needed
+	AMLEscalations                 []AMLEscalation
+	FXQuote                        *FXQuote
+	Fee                            *FeeQuote
+	WithdrawDoneTime               time.Time
+	DepositDoneTime                time.Time
+	RefundDoneTime                 time.Time
+	Attempts                       map[string]int // attempts the workflow itself made, by stage
+	LastFailure                    string
+	CancelRequested                bool
+}
+
+// Cancellable reports whether a cancellation would still be honoured. Once the deposit has started the transfer
+// runs to completion.
+func (p Progress) Cancellable() bool {
+	switch p.Stage {
+	case StageScheduled, StageStarted, StageSanctionsScreening, StageMoneyLaunderingReview, StageLockingFXRate, StageWithdrawing:
+		return true
+	default:
+		return false
+	}
+}
+
+// RefundResolution is sent by an operator to a transfer whose refund keeps failing.
+//   - retry: try the refund again
+//   - force-refund: the money was returned outside of the workflow, mark the transfer refunded
+//   - write-off: give up on the refund and close the transfer as refund_failed
+type RefundResolution struct {
+	Action   string
+	Operator string
+	Comment  string
+}
+
+type BankTransaction struct {
+	AccountID string
+	Amount    Money
+	Reference string
+	IsRefund  bool
+	// IdempotencyKey lets the bank recognise retries of the same transaction. The workflow stubs derive it
+	// via IdempotencyKey when left empty.
+	IdempotencyKey string
+}
+
+type TxnResponse struct {
+	Status        string // success, failure
+	FailureReason string
+}
+
+// StandingOrder repeats a transfer on a schedule until it is cancelled, passes EndAt or has run Count times.
+type StandingOrder struct {
+	Ref string // occurrence n is transferred with reference StandingOrderTransferRef(Ref, n)
+	// Request is the transfer to repeat; its Ref and ExecuteAt are set for each occurrence
+	Request    Request
+	Recurrence Recurrence
+	StartAt    time.Time // the first occurrence; later ones keep its day and time of day
+	EndAt      time.Time // optional: the last day an occurrence may fall on
+	Count      int       // optional: how many occurrences there are, skipped ones included
+	Customer   string    // the customer the transfers are started for, when known
+}
+
+// Recurrence is how often a standing order falls due: every Interval days, weeks or months.
+type Recurrence struct {
+	Frequency string // daily, weekly, monthly
+	Interval  int    // 0 means 1
+}
+
+func (r Recurrence) Validate() error {
+	switch r.Frequency {
+	case FrequencyDaily, FrequencyWeekly, FrequencyMonthly:
+	default:
+		return fmt.Errorf("frequency %q is not one of %s, %s, %s", r.Frequency, FrequencyDaily, FrequencyWeekly, FrequencyMonthly)
+	}
+	if r.Interval < 0 {
+		return fmt.Errorf("interval must not be negative")
+	}
+	return nil
+}
+
+// Occurrence is occurrence n, counting from 0, of a recurrence starting at start, with the time of day kept in
+// loc. A monthly occurrence falls on the last day of a month too short for start's day.
+func (r Recurrence) Occurrence(start time.Time, n int, loc *time.Location) time.Time {
+	interval := r.Interval
+	if interval == 0 {
+		interval = 1
+	}
+	start = start.In(loc)
+	switch r.Frequency {
+	case FrequencyWeekly:
+		return start.AddDate(0, 0, 7*interval*n)
+	case FrequencyMonthly:
+		year, month, day := start.Date()
+		month += time.Month(interval * n)
+		// day 0 of the following month is the last day of this one
+		if last := time.Date(year, month+1, 0, 0, 0, 0, 0, loc).Day(); day > last {
+			day = last
+		}
+		return time.Date(year, month, day, start.Hour(), start.Minute(), start.Second(), 0, loc)
+	default:
+		return start.AddDate(0, 0, interval*n)
+	}
+}
+
+// StandingOrderAmendment changes the occurrences of a standing order still to come. Fields left at their zero
+// value are not changed; a new Amount must be in the same currency, and a nil AcceptedFee keeps the one the
+// customer agreed to before.
+type StandingOrderAmendment struct {
+	Amount      Money
+	AcceptedFee *Money
+	EndAt       time.Time
+	Editor      string
+}
+
+// StandingOrderOccurrence is what became of one occurrence of a standing order.
+type StandingOrderOccurrence struct {
+	N             int
+	Ref           string // of the transfer, empty when skipped
+	DueAt         time.Time
+	Amount        Money
+	Status        string // the transfer's status, or skipped
+	FailureReason string
+}
+
+// StandingOrderState is the answer to StandingOrderQuery, and what a standing order continues as new with.
+type StandingOrderState struct {
+	Order        StandingOrder // as amended
+	Status       string        // see the StandingOrder constants
+	PausedReason string        // why it was paused after failing, empty when the customer paused it
+	Next         int           // the occurrence due next
+	NextDueAt    time.Time     // zero once the order has ended
+	Current      string        // reference of the transfer under way, if any
+	// History holds the most recent occurrences, oldest first
+	History             []StandingOrderOccurrence
+	Transfers           int // occurrences that were transferred, whatever their outcome
+	Failures            int // transfers that did not succeed
+	ConsecutiveFailures int
+	Amendments          int
+}
@@ -0,0 +1,76 @@
+package contracts
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRecurrenceOccurrence(t *testing.T) {
+	london, err := time.LoadLocation("Europe/London")
+	if err != nil {
+		t.Fatal(err)
+	}
+	date := func(year int, month time.Month, day int) time.Time {
+		return time.Date(year, month, day, 9, 30, 0, 0, time.UTC)
+	}
+	tests := []struct {
+		name       string
+		recurrence Recurrence
+		start      time.Time
+		loc        *time.Location
+		want       []time.Time // occurrences 0, 1, 2...
+	}{
+		{
+			name:       "daily",
+			recurrence: Recurrence{Frequency: FrequencyDaily},
+			start:      date(2026, 12, 30),
+			want:       []time.Time{date(2026, 12, 30), date(2026, 12, 31), date(2027, 1, 1)},
+		},
+		{
+			name:       "every other week",
+			recurrence: Recurrence{Frequency: FrequencyWeekly, Interval: 2},
+			start:      date(2026, 12, 21),
+			want:       []time.Time{date(2026, 12, 21), date(2027, 1, 4), date(2027, 1, 18)},
+		},
+		{
+			name:       "monthly from the 31st falls on the last day of shorter months",
+			recurrence: Recurrence{Frequency: FrequencyMonthly},
+			start:      date(2026, 1, 31),
+			want: []time.Time{date(2026, 1, 31), date(2026, 2, 28), date(2026, 3, 31), date(2026, 4, 30),
+				date(2026, 5, 31)},
+		},
+		{
+			name:       "monthly in a leap year",
+			recurrence: Recurrence{Frequency: FrequencyMonthly},
+			start:      date(2028, 1, 31),
+			want:       []time.Time{date(2028, 1, 31), date(2028, 2, 29), date(2028, 3, 31)},
+		},
+		{
+			name:       "quarterly across the year end",
+			recurrence: Recurrence{Frequency: FrequencyMonthly, Interval: 3},
+			start:      date(2026, 11, 30),
+			want:       []time.Time{date(2026, 11, 30), date(2027, 2, 28), date(2027, 5, 30), date(2027, 8, 30)},
+		},
+		{
+			name:       "the time of day is kept across a clock change",
+			recurrence: Recurrence{Frequency: FrequencyDaily},
+			start:      time.Date(2026, 3, 28, 9, 0, 0, 0, london),
+			loc:        london,
+			want: []time.Time{time.Date(2026, 3, 28, 9, 0, 0, 0, london), time.Date(2026, 3, 29, 9, 0, 0, 0, london),
+				time.Date(2026, 3, 30, 9, 0, 0, 0, london)},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			loc := tt.loc
+			if loc == nil {
+				loc = time.UTC
+			}
+			for n, want := range tt.want {
+				if got := tt.recurrence.Occurrence(tt.start, n, loc); !got.Equal(want) {
+					t.Fatalf("occurrence %d is %v, want %v", n, got, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,690 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/arunsworld/temporal-demo/bankingdemo/auth"
+	"github.com/arunsworld/temporal-demo/bankingdemo/contracts"
+	"go.temporal.io/api/enums/v1"
+	"go.temporal.io/api/serviceerror"
+	"go.temporal.io/api/workflow/v1"
+	"go.temporal.io/api/workflowservice/v1"
+)
+
+const apiPrefix = "/api/v1"
+
+const defaultPageSize = 20
+const maxPageSize = 100
+
+type transferRequest struct {
+	SourceBank          string      `json:"sourceBank"`
+	SourceAccount       string      `json:"sourceAccount"`
+	SourceName          string      `json:"sourceName,omitempty"`
+	DestinationBank     string      `json:"destinationBank"`
+	DestinationAccount  string      `json:"destinationAccount"`
+	DestinationName     string      `json:"destinationName,omitempty"`
+	Amount              json.Number `json:"amount"` // a decimal, as a JSON string or number
+	SourceCurrency      string      `json:"sourceCurrency,omitempty"`
+	DestinationCurrency string      `json:"destinationCurrency,omitempty"`
+	Reference           string      `json:"reference"`
+	// AcceptedFee is the most the customer agreed to be charged, as quoted by /api/v1/fee-quotes
+	AcceptedFee json.Number `json:"acceptedFee,omitempty"`
+	// ExecuteAt future-dates the transfer; it is sent then, or on the next business day
+	ExecuteAt *time.Time `json:"executeAt,omitempty"`
+}
+
+// scheduleEdit is the body of PATCH /api/v1/transfers/{reference}, for a transfer that is not yet due
+type scheduleEdit struct {
+	ExecuteAt   *time.Time  `json:"executeAt,omitempty"`
+	Amount      json.Number `json:"amount,omitempty"`
+	AcceptedFee json.Number `json:"acceptedFee,omitempty"`
+}
+
+type transferResource struct {
+	Reference string     `json:"reference"`
+	RunID     string     `json:"runId"`
+	State     string     `json:"state"` // the workflow execution status: running, completed, failed, ...
+	StartTime *time.Time `json:"startTime,omitempty"`
+	CloseTime *time.Time `json:"closeTime,omitempty"`
+	Progress  *progress  `json:"progress,omitempty"`
+	Result    *result    `json:"result,omitempty"`
+}
+
+type progress struct {
+	Stage                   string          `json:"stage"`
+	StageDescription        string          `json:"stageDescription"`
+	StageSince              time.Time       `json:"stageSince"`
+	MoneyLaunderingDecision *amlDecision    `json:"moneyLaunderingDecision,omitempty"`
+	MoneyLaunderingCheckAt  *time.Time      `json:"moneyLaunderingCheckAt,omitempty"`
+	WithdrawnAt             *time.Time      `json:"withdrawnAt,omitempty"`
+	DepositedAt             *time.Time      `json:"depositedAt,omitempty"`
+	RefundedAt              *time.Time      `json:"refundedAt,omitempty"`
+	Attempts                map[string]int  `json:"attempts,omitempty"`
+	LastFailure             string          `json:"lastFailure,omitempty"`
+	AMLEscalations          []amlEscalation `json:"amlEscalations,omitempty"`
+	FXQuote                 *fxQuote        `json:"fxQuote,omitempty"`
+	Fee                     *feeQuote       `json:"fee,omitempty"`
+	ScheduledFor            *time.Time      `json:"scheduledFor,omitempty"`
+	ScheduleEdits           int             `json:"scheduleEdits,omitempty"`
+	CancelRequested         bool            `json:"cancelRequested"`
+	Cancellable             bool            `json:"cancellable"`
+}
+
+type result struct {
+	Status                  string          `json:"status"`
+	FailureReason           string          `json:"failureReason,omitempty"`
+	StartedAt               time.Time       `json:"startedAt"`
+	ScheduledFor            *time.Time      `json:"scheduledFor,omitempty"`
+	MoneyLaunderingCheckAt  *time.Time      `json:"moneyLaunderingCheckAt,omitempty"`
+	WithdrawnAt             *time.Time      `json:"withdrawnAt,omitempty"`
+	DepositedAt             *time.Time      `json:"depositedAt,omitempty"`
+	RefundedAt              *time.Time      `json:"refundedAt,omitempty"`
+	RefundAttempts          int             `json:"refundAttempts,omitempty"`
+	MoneyLaunderingDecision *amlDecision    `json:"moneyLaunderingDecision,omitempty"`
+	AMLEscalations          []amlEscalation `json:"amlEscalations,omitempty"`
+	FXQuote                 *fxQuote        `json:"fxQuote,omitempty"`
+	Fee                     *feeQuote       `json:"fee,omitempty"`
+}
+
+type feeQuote struct {
+	Items []feeItem       `json:"items"`
+	Total contracts.Money `json:"total"`
+	// Debit is what the source account is charged: the amount plus the fee
+	Debit *contracts.Money `json:"debit,omitempty"`
+}
+
+type feeItem struct {
+	Name   string          `json:"name"`
+	Amount contracts.Money `json:"amount"`
+}
+
+type fxQuote struct {
+	QuoteID         string          `json:"quoteId"`
+	From            string          `json:"from"`
+	To              string          `json:"to"`
+	Rate            float64         `json:"rate"`
+	Amount          contracts.Money `json:"amount"`
+	ConvertedAmount contracts.Money `json:"convertedAmount"`
+	ExpiresAt       time.Time       `json:"expiresAt"`
+}
+
+type amlEscalation struct {
+	Event    string    `json:"event"`
+	At       time.Time `json:"at"`
+	Reminder int       `json:"reminder,omitempty"`
+}
+
+type amlDecision struct {
+	Decision       string    `json:"decision"`
+	Reviewer       string    `json:"reviewer"`
+	ReasonCode     string    `json:"reasonCode"`
+	Comment        string    `json:"comment,omitempty"`
+	DecidedAt      time.Time `json:"decidedAt"`
+	Approvers      []string  `json:"approvers,omitempty"`
+	RiskScore      int       `json:"riskScore"`
+	TriggeredRules []string  `json:"triggeredRules,omitempty"`
+}
+
+type transferList struct {
+	Transfers     []transferResource `json:"transfers"`
+	NextPageToken string             `json:"nextPageToken,omitempty"`
+}
+
+type apiError struct {
+	Error   string            `json:"error"`
+	Message string            `json:"message"`
+	Fields  map[string]string `json:"fields,omitempty"`
+}
+
+func (s *service) registerAPIHandlers() {
+	// the API takes no cookies, only JSON bodies, so it needs no CSRF token
+	http.HandleFunc(apiPrefix+"/transfers", auth.Require(s.authn, s.transfersHandler, auth.RoleCustomer))
+	http.HandleFunc(apiPrefix+"/transfers/", auth.Require(s.authn, s.transferHandler, auth.RoleCustomer))
+	http.HandleFunc(apiPrefix+"/fee-quotes", auth.Require(s.authn, s.feeQuotesHandler, auth.RoleCustomer))
+	http.HandleFunc(apiPrefix+"/standing-orders", auth.Require(s.authn, s.standingOrdersAPIHandler, auth.RoleCustomer))
+	http.HandleFunc(apiPrefix+"/standing-orders/", auth.Require(s.authn, s.standingOrderAPIHandler, auth.RoleCustomer))
+	http.HandleFunc(apiPrefix+"/openapi.json", openAPIHandler)
+}
+
+// transfersHandler serves POST and GET /api/v1/transfers
+func (s *service) transfersHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPost:
+		s.createTransfer(w, r)
+	case http.MethodGet:
+		s.listTransfers(w, r)
+	default:
+		writeAPIError(w, http.StatusMethodNotAllowed, "method_not_allowed", "use GET or POST", nil)
+	}
+}
+
+// transferHandler serves GET, PATCH and DELETE /api/v1/transfers/{ref}
+func (s *service) transferHandler(w http.ResponseWriter, r *http.Request) {
+	ref := strings.TrimPrefix(r.URL.Path, apiPrefix+"/transfers/")
+	if ref == "" || strings.Contains(ref, "/") {
+		writeAPIError(w, http.StatusNotFound, "not_found", "no such resource", nil)
+		return
+	}
+	if r.Method == http.MethodPatch {
+		s.editTransfer(w, r, ref)
+		return
+	}
+	if r.Method != http.MethodGet && r.Method != http.MethodDelete {
+		writeAPIError(w, http.StatusMethodNotAllowed, "method_not_allowed", "use GET, PATCH or DELETE", nil)
+		return
+	}
+	resource, err := s.describeTransfer(ref, auth.FromContext(r.Context()))
+	if err != nil {
+		var notFound *serviceerror.NotFound
+		if errors.As(err, &notFound) {
+			writeAPIError(w, http.StatusNotFound, "not_found", "no transfer with reference "+ref, nil)
+			return
+		}
+		writeAPIError(w, http.StatusInternalServerError, "internal", err.Error(), nil)
+		return
+	}
+	if r.Method == http.MethodGet {
+		writeJSON(w, http.StatusOK, resource)
+		return
+	}
+	s.cancelTransfer(w, ref, resource)
+}
+
+// cancelTransfer asks the transfer to stop before its deposit; the outcome shows in its result
+func (s *service) cancelTransfer(w http.ResponseWriter, ref string, resource transferResource) {
+	err := contracts.ErrNotCancellable
+	if resource.Progress != nil {
+		err = contracts.CancelMoneyTransfer(s.ctx, s.workflowClient, ref)
+	}
+	if errors.Is(err, contracts.ErrNotCancellable) {
+		writeAPIError(w, http.StatusConflict, "not_cancellable", "the transfer can no longer be cancelled", nil)
+		return
+	}
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, "internal", err.Error(), nil)
+		return
+	}
+	// the cancellation is applied by the transfer, so it is shown as it was
+	writeJSON(w, http.StatusAccepted, resource)
+}
+
+// editTransfer changes when a future-dated transfer is due, its amount or both, until it is due
+func (s *service) editTransfer(w http.ResponseWriter, r *http.Request, ref string) {
+	if !hasJSONBody(w, r) {
+		return
+	}
+	body := scheduleEdit{}
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&body); err != nil {
+		writeAPIError(w, http.StatusBadRequest, "invalid_json", err.Error(), nil)
+		return
+	}
+	resp, err := s.workflowClient.DescribeWorkflowExecution(s.ctx, contracts.MoneyTransferID(ref), "")
+	if err == nil && !mayAccess(auth.FromContext(r.Context()), resp.WorkflowExecutionInfo) {
+		err = serviceerror.NewNotFound("transfer not found")
+	}
+	if err != nil {
+		var notFound *serviceerror.NotFound
+		if errors.As(err, &notFound) {
+			writeAPIError(w, http.StatusNotFound, "not_found", "no transfer with reference "+ref, nil)
+			return
+		}
+		writeAPIError(w, http.StatusInternalServerError, "internal", err.Error(), nil)
+		return
+	}
+	var p contracts.Progress
+	if resp.WorkflowExecutionInfo.Status == enums.WORKFLOW_EXECUTION_STATUS_RUNNING {
+		if p, err = contracts.QueryMoneyTransferProgress(s.ctx, s.workflowClient, ref); err != nil {
+			writeAPIError(w, http.StatusInternalServerError, "internal", err.Error(), nil)
+			return
+		}
+	}
+	if p.Stage != contracts.StageScheduled {
+		writeAPIError(w, http.StatusConflict, "not_scheduled", "the transfer is already under way", nil)
+		return
+	}
+	if body.ExecuteAt == nil && body.Amount == "" {
+		writeAPIError(w, http.StatusUnprocessableEntity, "validation_failed", "send executeAt, amount or both", nil)
+		return
+	}
+	currency := p.Request.Amount.Currency
+	problems := make(map[string]string)
+	// what is left out is not changed
+	edit := contracts.ScheduleEdit{Editor: auth.FromContext(r.Context()).Subject}
+	if body.ExecuteAt != nil {
+		if !body.ExecuteAt.After(time.Now()) {
+			problems["executeAt"] = "must be in the future"
+		} else if _, err := s.calendar.NextBusinessTime(*body.ExecuteAt); err != nil {
+			problems["executeAt"] = "has no business day within a year after it"
+		} else {
+			edit.ExecuteAt = *body.ExecuteAt
+		}
+	}
+	if body.Amount != "" {
+		amount, problem := parseAmount(string(body.Amount), currency)
+		if problem == "" && amount.Minor <= 0 {
+			problem = "must be a positive amount"
+		}
+		if problem != "" {
+			problems["amount"] = problem
+		}
+		edit.Amount = amount
+		if body.AcceptedFee != "" {
+			fee, problem := parseAmount(string(body.AcceptedFee), currency)
+			if problem != "" {
+				problems["acceptedFee"] = problem
+			}
+			edit.AcceptedFee = &fee
+		} else if p.Request.AcceptedFee != nil && problem == "" && amount != p.Request.Amount {
+			problems["acceptedFee"] = "required when the amount changes"
+		}
+	} else if body.AcceptedFee != "" {
+		problems["acceptedFee"] = "only applies to a new amount"
+	}
+	if len(problems) > 0 {
+		writeAPIError(w, http.StatusUnprocessableEntity, "validation_failed", "the change is invalid", problems)
+		return
+	}
+	if err := contracts.EditScheduledTransfer(s.ctx, s.workflowClient, ref, edit); err != nil {
+		if errors.Is(err, contracts.ErrNotScheduled) {
+			writeAPIError(w, http.StatusConflict, "not_scheduled", "the transfer is already under way", nil)
+			return
+		}
+		writeAPIError(w, http.StatusInternalServerError, "internal", err.Error(), nil)
+		return
+	}
+	resource := newTransferResource(ref, resp.WorkflowExecutionInfo)
+	resource.Progress = newProgress(p)
+	writeJSON(w, http.StatusAccepted, resource)
+}
+
+// feeQuotesHandler serves POST /api/v1/fee-quotes: what a transfer would cost, without starting it
+func (s *service) feeQuotesHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeAPIError(w, http.StatusMethodNotAllowed, "method_not_allowed", "use POST", nil)
+		return
+	}
+	body, ok := readTransferRequest(w, r)
+	if !ok {
+		return
+	}
+	req, problems := s.newRequest(body)
+	delete(problems, "reference")
+	if len(problems) > 0 {
+		writeAPIError(w, http.StatusUnprocessableEntity, "validation_failed", "the transfer request is invalid", problems)
+		return
+	}
+	fee, err := s.fees.Quote(req)
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, "internal", err.Error(), nil)
+		return
+	}
+	result := newFeeQuote(&fee)
+	result.Debit = &contracts.Money{Minor: req.Amount.Minor + fee.Total.Minor, Currency: req.Amount.Currency}
+	writeJSON(w, http.StatusOK, result)
+}
+
+func (s *service) createTransfer(w http.ResponseWriter, r *http.Request) {
+	body, ok := readTransferRequest(w, r)
+	if !ok {
+		return
+	}
+	req, problems := s.newRequest(body)
+	if len(problems) > 0 {
+		writeAPIError(w, http.StatusUnprocessableEntity, "validation_failed", "the transfer request is invalid", problems)
+		return
+	}
+	workflowRun, err := contracts.StartMoneyTransfer(s.ctx, s.workflowClient, req, auth.FromContext(r.Context()).Subject)
+	if err != nil {
+		if errors.Is(err, contracts.ErrDuplicateReference) {
+			writeAPIError(w, http.StatusConflict, "duplicate_reference", err.Error(), nil)
+			return
+		}
+		writeAPIError(w, http.StatusInternalServerError, "internal", err.Error(), nil)
+		return
+	}
+	w.Header().Set("Location", apiPrefix+"/transfers/"+req.Ref)
+	writeJSON(w, http.StatusCreated, transferResource{
+		Reference: req.Ref,
+		RunID:     workflowRun.GetRunID(),
+		State:     stateName(enums.WORKFLOW_EXECUTION_STATUS_RUNNING),
+	})
+}
+
+// readTransferRequest decodes a transferRequest body, answering the client itself when it cannot
+func readTransferRequest(w http.ResponseWriter, r *http.Request) (transferRequest, bool) {
+	if !hasJSONBody(w, r) {
+		return transferRequest{}, false
+	}
+	body := transferRequest{}
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&body); err != nil {
+		writeAPIError(w, http.StatusBadRequest, "invalid_json", err.Error(), nil)
+		return transferRequest{}, false
+	}
+	return body, true
+}
+
+// hasJSONBody answers the client itself when the body is not JSON
+func hasJSONBody(w http.ResponseWriter, r *http.Request) bool {
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType != "application/json" {
+		writeAPIError(w, http.StatusUnsupportedMediaType, "unsupported_media_type", "send application/json", nil)
+		return false
+	}
+	return true
+}
+
+// newRequest fills in the defaults of body and validates it
+func (s *service) newRequest(body transferRequest) (contracts.Request, map[string]string) {
+	sourceCurrency := strings.ToUpper(body.SourceCurrency)
+	if sourceCurrency == "" {
+		sourceCurrency = s.currencies[0]
+	}
+	amount, amountProblem := parseAmount(string(body.Amount), sourceCurrency)
+	req := contracts.Request{
+		SourceBank:          body.SourceBank,
+		SourceAcc:           body.SourceAccount,
+		SourceName:          strings.TrimSpace(body.SourceName),
+		DestinationBank:     body.DestinationBank,
+		DestinationAcc:      body.DestinationAccount,
+		DestinationName:     strings.TrimSpace(body.DestinationName),
+		Amount:              amount,
+		Ref:                 body.Reference,
+		DestinationCurrency: strings.ToUpper(body.DestinationCurrency),
+	}
+	if body.ExecuteAt != nil {
+		req.ExecuteAt = *body.ExecuteAt
+	}
+	if req.DestinationCurrency == "" {
+		req.DestinationCurrency = sourceCurrency
+	}
+	problems := s.validateRequest(req)
+	if amountProblem != "" {
+		problems["amount"] = amountProblem
+	}
+	if body.AcceptedFee != "" {
+		fee, problem := parseAmount(string(body.AcceptedFee), sourceCurrency)
+		if problem != "" {
+			problems["acceptedFee"] = problem
+		}
+		req.AcceptedFee = &fee
+	}
+	return req, problems
+}
+
+// readPageSize reads the pageSize parameter of a list, answering the client itself when it is bad
+func readPageSize(w http.ResponseWriter, r *http.Request) (int, bool) {
+	v := r.URL.Query().Get("pageSize")
+	if v == "" {
+		return defaultPageSize, true
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 1 || n > maxPageSize {
+		writeAPIError(w, http.StatusBadRequest, "invalid_page_size", "pageSize must be between 1 and 100", nil)
+		return 0, false
+	}
+	return n, true
+}
+
+func (s *service) listTransfers(w http.ResponseWriter, r *http.Request) {
+	pageSize, ok := readPageSize(w, r)
+	if !ok {
+		return
+	}
+	query := contracts.MoneyTransferListQuery
+	if identity := auth.FromContext(r.Context()); identity.Subject != "" && !identity.HasRole(auth.RoleOps) {
+		var err error
+		if query, err = contracts.CustomerTransfersQuery(identity.Subject); err != nil {
+			writeAPIError(w, http.StatusForbidden, "forbidden", err.Error(), nil)
+			return
+		}
+	}
+	resp, err := s.workflowClient.ListWorkflow(s.ctx, &workflowservice.ListWorkflowExecutionsRequest{
+		PageSize:      int32(pageSize),
+		NextPageToken: []byte(r.URL.Query().Get("pageToken")),
+		Query:         query,
+	})
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, "internal", err.Error(), nil)
+		return
+	}
+	result := transferList{
+		Transfers:     []transferResource{},
+		NextPageToken: string(resp.NextPageToken),
+	}
+	for _, info := range resp.Executions {
+		ref, ok := contracts.ReferenceFromMoneyTransferID(info.Execution.WorkflowId)
+		if !ok {
+			continue
+		}
+		result.Transfers = append(result.Transfers, newTransferResource(ref, info))
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+// describeTransfer reports a transfer identity may not access as not found
+func (s *service) describeTransfer(ref string, identity auth.Identity) (transferResource, error) {
+	resp, err := s.workflowClient.DescribeWorkflowExecution(s.ctx, contracts.MoneyTransferID(ref), "")
+	if err != nil {
+		return transferResource{}, err
+	}
+	info := resp.WorkflowExecutionInfo
+	if !mayAccess(identity, info) {
+		return transferResource{}, serviceerror.NewNotFound("transfer not found")
+	}
+	resource := newTransferResource(ref, info)
+	switch info.Status {
+	case enums.WORKFLOW_EXECUTION_STATUS_RUNNING:
+		p, err := contracts.QueryMoneyTransferProgress(s.ctx, s.workflowClient, ref)
+		if err != nil {
+			return transferResource{}, err
+		}
+		resource.Progress = newProgress(p)
+	case enums.WORKFLOW_EXECUTION_STATUS_COMPLETED:
+		r, err := contracts.GetMoneyTransferResult(s.ctx, s.workflowClient, ref, info.Execution.RunId)
+		if err != nil {
+			return transferResource{}, err
+		}
+		resource.Result = newResult(r)
+	}
+	return resource, nil
+}
+
+func newTransferResource(ref string, info *workflow.WorkflowExecutionInfo) transferResource {
+	return transferResource{
+		Reference: ref,
+		RunID:     info.Execution.RunId,
+		State:     stateName(info.Status),
+		StartTime: info.StartTime,
+		CloseTime: info.CloseTime,
+	}
+}
+
+func newProgress(p contracts.Progress) *progress {
+	return &progress{
+		Stage:                   p.Stage,
+		StageDescription:        describeStage(p.Stage),
+		StageSince:              p.StageSince,
+		MoneyLaunderingDecision: newAMLDecision(p.MoneyLaunderingDecision),
+		MoneyLaunderingCheckAt:  optionalTime(p.MoneyLaunderingCheckFinishTime),
+		WithdrawnAt:             optionalTime(p.WithdrawDoneTime),
+		DepositedAt:             optionalTime(p.DepositDoneTime),
+		RefundedAt:              optionalTime(p.RefundDoneTime),
+		Attempts:                p.Attempts,
+		LastFailure:             p.LastFailure,
+		AMLEscalations:          newAMLEscalations(p.AMLEscalations),
+		FXQuote:                 newFXQuote(p.FXQuote),
+		Fee:                     newFeeQuote(p.Fee),
+		ScheduledFor:            optionalTime(p.ScheduledFor),
+		ScheduleEdits:           p.ScheduleEdits,
+		CancelRequested:         p.CancelRequested,
+		Cancellable:             p.Cancellable(),
+	}
+}
+
+func newResult(r contracts.Response) *result {
+	return &result{
+		Status:                  r.Status,
+		FailureReason:           r.FailureReason,
+		StartedAt:               r.StartTime,
+		ScheduledFor:            optionalTime(r.ScheduledFor),
+		MoneyLaunderingCheckAt:  optionalTime(r.MoneyLaunderingCheckFinishTime),
+		WithdrawnAt:             optionalTime(r.WithdrawDoneTime),
+		DepositedAt:             optionalTime(r.DepositDoneTime),
+		RefundedAt:              optionalTime(r.RefundDoneTime),
+		RefundAttempts:          r.RefundAttempts,
+		MoneyLaunderingDecision: newAMLDecision(r.MoneyLaunderingDecision),
+		AMLEscalations:          newAMLEscalations(r.AMLEscalations),
+		FXQuote:                 newFXQuote(r.FXQuote),
+		Fee:                     newFeeQuote(r.Fee),
+	}
+}
+
+func newFXQuote(q *contracts.FXQuote) *fxQuote {
+	if q == nil {
+		return nil
+	}
+	return &fxQuote{
+		QuoteID:         q.ID,
+		From:            q.Amount.Currency,
+		To:              q.ConvertedAmount.Currency,
+		Rate:            q.Rate,
+		Amount:          q.Amount,
+		ConvertedAmount: q.ConvertedAmount,
+		ExpiresAt:       q.ExpiresAt,
+	}
+}
+
+func newFeeQuote(q *contracts.FeeQuote) *feeQuote {
+	if q == nil {
+		return nil
+	}
+	result := &feeQuote{Items: []feeItem{}, Total: q.Total}
+	for _, item := range q.Items {
+		result.Items = append(result.Items, feeItem{Name: item.Name, Amount: item.Amount})
+	}
+	return result
+}
+
+func newAMLEscalations(escalations []contracts.AMLEscalation) []amlEscalation {
+	result := []amlEscalation{}
+	for _, e := range escalations {
+		result = append(result, amlEscalation{Event: e.Event, At: e.At, Reminder: e.Reminder})
+	}
+	return result
+}
+
+func newAMLDecision(d *contracts.AMLDecision) *amlDecision {
+	if d == nil {
+		return nil
+	}
+	return &amlDecision{
+		Decision:       d.Decision,
+		Reviewer:       d.Reviewer,
+		ReasonCode:     d.ReasonCode,
+		Comment:        d.Comment,
+		DecidedAt:      d.DecidedAt,
+		Approvers:      d.Approvers,
+		RiskScore:      d.RiskScore,
+		TriggeredRules: d.TriggeredRules,
+	}
+}
+
+func optionalTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
+func stateName(status enums.WorkflowExecutionStatus) string {
+	return strings.ToLower(status.String())
+}
+
+// validateRequest returns the problems with req keyed by the API field name
+func (s *service) validateRequest(req contracts.Request) map[string]string {
+	problems := make(map[string]string)
+	if !s.isKnownBank(req.SourceBank) {
+		problems["sourceBank"] = "must be one of " + strings.Join(s.banks, ", ")
+	}
+	if !s.isKnownBank(req.DestinationBank) {
+		problems["destinationBank"] = "must be one of " + strings.Join(s.banks, ", ")
+	}
+	if req.SourceAcc == "" {
+		problems["sourceAccount"] = "is required"
+	}
+	if req.DestinationAcc == "" {
+		problems["destinationAccount"] = "is required"
+	}
+	if req.SourceBank == req.DestinationBank && req.SourceAcc != "" && req.SourceAcc == req.DestinationAcc {
+		problems["destinationAccount"] = "must differ from the source account"
+	}
+	if req.Amount.Minor <= 0 {
+		problems["amount"] = "must be a positive amount"
+	}
+	if req.Ref == "" {
+		problems["reference"] = "is required"
+	} else if strings.Contains(req.Ref, "#") {
+		// kept for the transfers of standing orders
+		problems["reference"] = "must not contain #"
+	}
+	if !contains(s.currencies, req.Amount.Currency) {
+		problems["sourceCurrency"] = "must be one of " + strings.Join(s.currencies, ", ")
+	}
+	if !contains(s.currencies, req.DestinationCurrency) {
+		problems["destinationCurrency"] = "must be one of " + strings.Join(s.currencies, ", ")
+	}
+	if !req.ExecuteAt.IsZero() && !req.ExecuteAt.After(time.Now()) {
+		problems["executeAt"] = "must be in the future"
+	} else if _, err := s.calendar.NextBusinessTime(req.ExecuteAt); !req.ExecuteAt.IsZero() && err != nil {
+		problems["executeAt"] = "has no business day within a year after it"
+	}
+	return problems
+}
+
+// parseAmount reads a transfer amount in currency. A malformed amount keeps the currency, so that only the amount
+// is reported, and comes with the problem to report.
+func parseAmount(amount, currency string) (contracts.Money, string) {
+	result, err := contracts.ParseMoney(amount, currency)
+	if err != nil {
+		return contracts.Money{Currency: currency}, fmt.Sprintf("must be a plain decimal with at most %d decimal places", contracts.MinorUnitDigits(currency))
+	}
+	return result, ""
+}
+
+func (s *service) isKnownBank(bank string) bool {
+	return contains(s.banks, bank)
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeAPIError(w http.ResponseWriter, status int, code, message string, fields map[string]string) {
+	writeJSON(w, status, apiError{Error: code, Message: message, Fields: fields})
+}
+
+func openAPIHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write([]byte(openAPIDocument))
+}
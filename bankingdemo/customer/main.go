@@ -7,11 +7,18 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
 	"github.com/arunsworld/nursery"
+	"github.com/arunsworld/temporal-demo/bankingdemo/auth"
+	"github.com/arunsworld/temporal-demo/bankingdemo/calendar"
+	"github.com/arunsworld/temporal-demo/bankingdemo/contracts"
+	"github.com/arunsworld/temporal-demo/bankingdemo/fees"
 	temporalgolibs "github.com/arunsworld/temporal-demo/temporal-golibs"
+	"go.temporal.io/sdk/activity"
+	"go.temporal.io/sdk/worker"
 )
 
 func main() {
@@ -20,23 +27,6 @@ func main() {
 	}
 }
 
-type Request struct {
-	SourceBank, DestinationBank string
-	SourceAcc, DestinationAcc   string
-	Amount                      float64
-	Ref                         string
-}
-
-type Response struct {
-	Status                         string // success, failure, refunded
-	FailureReason                  string // populated for failure and refunded
-	StartTime                      time.Time
-	MoneyLaunderingCheckFinishTime time.Time
-	WithdrawDoneTime               time.Time
-	RefundDoneTime                 time.Time
-	DepositDoneTime                time.Time
-}
-
 func run() error {
 	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer cancel()
@@ -47,11 +37,60 @@ func run() error {
 	}
 	defer c.Close()
 
-	newService(ctx, c)
+	banks := []string{contracts.ABBankTaskQueue, contracts.BCBankTaskQueue}
+	if os.Getenv("BANKS") != "" {
+		banks = nil
+		for _, b := range strings.Split(os.Getenv("BANKS"), ",") {
+			if b = strings.TrimSpace(b); b != "" {
+				banks = append(banks, b)
+			}
+		}
+	}
+
+	currencies := []string{"USD", "EUR", "GBP"}
+	if os.Getenv("CURRENCIES") != "" {
+		currencies = nil
+		for _, c := range strings.Split(os.Getenv("CURRENCIES"), ",") {
+			if c = strings.ToUpper(strings.TrimSpace(c)); c != "" {
+				currencies = append(currencies, c)
+			}
+		}
+		if len(currencies) == 0 {
+			return fmt.Errorf("bad CURRENCIES: %q", os.Getenv("CURRENCIES"))
+		}
+	}
+
+	schedule, err := fees.Load(os.Getenv("FEE_SCHEDULE_FILE"))
+	if err != nil {
+		return err
+	}
+
+	cal, err := calendar.Load(os.Getenv("BUSINESS_CALENDAR_FILE"))
+	if err != nil {
+		return err
+	}
+
+	authn, err := auth.FromEnv("Money Transfer App")
+	if err != nil {
+		return err
+	}
+
+	notices := newNotifications()
+	newService(ctx, c, banks, currencies, schedule, cal, notices, authn)
+
+	w := worker.New(c, contracts.CustomerTaskQueue, worker.Options{})
+	w.RegisterActivityWithOptions(notices.notifyActivity, activity.RegisterOptions{
+		Name: contracts.NotifyCustomerActivity,
+	})
 
 	server := &http.Server{Addr: ":9399"}
 
 	return nursery.RunConcurrentlyWithContext(ctx,
+		func(_ context.Context, errCh chan error) {
+			if err := w.Run(worker.InterruptCh()); err != nil {
+				errCh <- err
+			}
+		},
 		func(context.Context, chan error) {
 			log.Println("serving on http://localhost:9399/")
 			if err := server.ListenAndServe(); err != nil {
@@ -101,13 +140,16 @@ func run() error {
 	// if err := workflowRun.Get(ctx, &resp); err != nil {
 	// 	return err
 	// }
-	// fmt.Println(resp.String())
+	// fmt.Println(formatResponse(resp))
 
 	// return nil
 }
 
-func (r Response) String() string {
+func formatResponse(r contracts.Response) string {
 	if r.StartTime.IsZero() {
+		if r.Status == contracts.StatusCancelled && !r.ScheduledFor.IsZero() {
+			return fmt.Sprintf("CANCELLED: %s\n\tScheduled for: %v", r.FailureReason, r.ScheduledFor)
+		}
 		return ""
 	}
 	var withdrawDuration time.Duration
@@ -130,15 +172,61 @@ func (r Response) String() string {
 	if !r.MoneyLaunderingCheckFinishTime.IsZero() {
 		moneyLaunderingCheckDuration = r.MoneyLaunderingCheckFinishTime.Sub(r.StartTime)
 	}
+	return formatStatus(r, withdrawDuration, depositDuration, refundDuration, moneyLaunderingCheckDuration) + formatScheduledFor(r) +
+		formatFXQuote(r.FXQuote) + formatFee(r) + formatMoneyLaunderingDecision(r.MoneyLaunderingDecision) + formatAMLEscalations(r.AMLEscalations)
+}
+
+func formatScheduledFor(r contracts.Response) string {
+	if r.ScheduledFor.IsZero() {
+		return ""
+	}
+	return fmt.Sprintf("\n\tScheduled for: %v", r.ScheduledFor)
+}
+
+func formatFXQuote(q *contracts.FXQuote) string {
+	if q == nil {
+		return ""
+	}
+	return "\n\t" + describeFXQuote(*q)
+}
+
+func describeFXQuote(q contracts.FXQuote) string {
+	return fmt.Sprintf("Exchange: %s = %s at %.6f, quote %s", q.Amount, q.ConvertedAmount, q.Rate, q.ID)
+}
+
+func formatFee(r contracts.Response) string {
+	if r.Fee == nil {
+		return ""
+	}
+	result := "\n\t" + describeFee(*r.Fee)
+	switch {
+	case r.Status == contracts.StatusSuccess:
+	case !r.RefundDoneTime.IsZero():
+		result += ", refunded"
+	case r.WithdrawDoneTime.IsZero():
+		result += ", not charged"
+	}
+	return result
+}
+
+func describeFee(q contracts.FeeQuote) string {
+	items := []string{}
+	for _, item := range q.Items {
+		items = append(items, fmt.Sprintf("%s %s", item.Name, item.Amount))
+	}
+	return fmt.Sprintf("Fee: %s (%s)", q.Total, strings.Join(items, ", "))
+}
+
+func formatStatus(r contracts.Response, withdrawDuration, depositDuration, refundDuration, moneyLaunderingCheckDuration time.Duration) string {
 	switch r.Status {
-	case "success":
+	case contracts.StatusSuccess:
 		return fmt.Sprintf(`SUCCESS:
 	Start: %v
 	MoneyLaunderingCheckFinishTime: [%v] %v
 	Withdraw: [%v] %v
 	Deposit: [%v] %v`, r.StartTime, moneyLaunderingCheckDuration, r.MoneyLaunderingCheckFinishTime,
 			withdrawDuration, r.WithdrawDoneTime, depositDuration, r.DepositDoneTime)
-	case "failure":
+	case contracts.StatusFailure:
 		return fmt.Sprintf(`FAILURE: %s
 	Start: %v
 	MoneyLaunderingCheckFinishTime: [%v] %v
@@ -146,14 +234,68 @@ func (r Response) String() string {
 	Refund: [%v] %v
 	Deposit: [%v] %v`, r.FailureReason, r.StartTime, moneyLaunderingCheckDuration, r.MoneyLaunderingCheckFinishTime,
 			withdrawDuration, r.WithdrawDoneTime, refundDuration, r.RefundDoneTime, depositDuration, r.DepositDoneTime)
-	case "refunded":
+	case contracts.StatusRefunded:
 		return fmt.Sprintf(`REFUNDED: %s
 	Start: %v
 	MoneyLaunderingCheckFinishTime: [%v] %v
 	Withdraw: [%v] %v
+	Refund: [%v] %v
+	Refund attempts: %d%s`, r.FailureReason, r.StartTime, moneyLaunderingCheckDuration, r.MoneyLaunderingCheckFinishTime,
+			withdrawDuration, r.WithdrawDoneTime, refundDuration, r.RefundDoneTime, r.RefundAttempts, formatRefundResolution(r.RefundResolution))
+	case contracts.StatusCancelled:
+		return fmt.Sprintf(`CANCELLED: %s
+	Start: %v
+	MoneyLaunderingCheckFinishTime: [%v] %v
+	Withdraw: [%v] %v
 	Refund: [%v] %v`, r.FailureReason, r.StartTime, moneyLaunderingCheckDuration, r.MoneyLaunderingCheckFinishTime,
 			withdrawDuration, r.WithdrawDoneTime, refundDuration, r.RefundDoneTime)
+	case contracts.StatusRefundFailed:
+		return fmt.Sprintf(`REFUND FAILED: %s
+	Start: %v
+	MoneyLaunderingCheckFinishTime: [%v] %v
+	Withdraw: [%v] %v
+	Refund attempts: %d%s`, r.FailureReason, r.StartTime, moneyLaunderingCheckDuration, r.MoneyLaunderingCheckFinishTime,
+			withdrawDuration, r.WithdrawDoneTime, r.RefundAttempts, formatRefundResolution(r.RefundResolution))
+	default:
+		return ""
+	}
+}
+
+func formatMoneyLaunderingDecision(d *contracts.AMLDecision) string {
+	if d == nil {
+		return ""
+	}
+	reviewers := d.Reviewer
+	if len(d.Approvers) > 0 {
+		reviewers = strings.Join(d.Approvers, " and ")
+	}
+	return fmt.Sprintf("\n\tAML decision: %s by %s, reason %s (%s), risk score %d", d.Decision, reviewers, d.ReasonCode, d.Comment, d.RiskScore)
+}
+
+func formatAMLEscalations(escalations []contracts.AMLEscalation) string {
+	result := ""
+	for _, e := range escalations {
+		result += fmt.Sprintf("\n\tAML review %s at %v", describeAMLEscalation(e), e.At)
+	}
+	return result
+}
+
+func describeAMLEscalation(e contracts.AMLEscalation) string {
+	switch e.Event {
+	case contracts.AMLReviewReminded:
+		return fmt.Sprintf("reminder %d", e.Reminder)
+	case contracts.AMLReviewEscalated:
+		return "escalated to senior reviewers"
+	case contracts.AMLReviewExpired:
+		return "expired"
 	default:
+		return e.Event
+	}
+}
+
+func formatRefundResolution(r *contracts.RefundResolution) string {
+	if r == nil {
 		return ""
 	}
+	return fmt.Sprintf("\n\tResolution: %s by %s (%s)", r.Action, r.Operator, r.Comment)
 }
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/arunsworld/temporal-demo/bankingdemo/contracts"
+)
+
+type notification struct {
+	At      time.Time
+	Message string
+}
+
+// notifications stands in for email or SMS: messages workflows send to customers are logged and kept in memory
+// to be shown on the status page.
+type notifications struct {
+	mu    sync.Mutex
+	byRef map[string][]notification
+}
+
+func newNotifications() *notifications {
+	return &notifications{byRef: make(map[string][]notification)}
+}
+
+func (n *notifications) notifyActivity(_ context.Context, cn contracts.CustomerNotification) error {
+	log.Printf("NOTIFY customer of %s: %s", cn.Ref, cn.Message)
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.byRef[cn.Ref] = append(n.byRef[cn.Ref], notification{At: time.Now(), Message: cn.Message})
+	return nil
+}
+
+func (n *notifications) forRef(ref string) []notification {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return append([]notification(nil), n.byRef[ref]...)
+}
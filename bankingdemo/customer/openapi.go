@@ -0,0 +1,473 @@
+package main
+
+// openAPIDocument describes the JSON API and is served at /api/v1/openapi.json
+const openAPIDocument = `{
+  "openapi": "3.0.3",
+  "info": {
+    "title": "Money Transfer API",
+    "version": "1.0.0"
+  },
+  "security": [{"basicAuth": []}, {"bearerAuth": []}],
+  "paths": {
+    "/api/v1/transfers": {
+      "post": {
+        "summary": "Start a transfer",
+        "requestBody": {
+          "required": true,
+          "content": {"application/json": {"schema": {"$ref": "#/components/schemas/TransferRequest"}}}
+        },
+        "responses": {
+          "201": {"description": "Transfer started", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Transfer"}}}},
+          "400": {"description": "Malformed body", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Error"}}}},
+          "401": {"$ref": "#/components/responses/Unauthenticated"},
+          "403": {"$ref": "#/components/responses/Forbidden"},
+          "409": {"description": "Reference already used", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Error"}}}},
+          "415": {"description": "Body is not application/json", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Error"}}}},
+          "422": {"description": "Validation failed", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Error"}}}}
+        }
+      },
+      "get": {
+        "summary": "List transfers, most recent first",
+        "description": "Customers only see their own transfers; ops see every transfer",
+        "parameters": [
+          {"name": "pageSize", "in": "query", "schema": {"type": "integer", "minimum": 1, "maximum": 100, "default": 20}},
+          {"name": "pageToken", "in": "query", "schema": {"type": "string"}}
+        ],
+        "responses": {
+          "200": {"description": "A page of transfers", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/TransferList"}}}},
+          "400": {"description": "Bad paging parameters", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Error"}}}},
+          "401": {"$ref": "#/components/responses/Unauthenticated"},
+          "403": {"$ref": "#/components/responses/Forbidden"}
+        }
+      }
+    },
+    "/api/v1/fee-quotes": {
+      "post": {
+        "summary": "Quote the fee of a transfer without starting it",
+        "description": "Takes the same body as starting a transfer; the reference may be left out",
+        "requestBody": {
+          "required": true,
+          "content": {"application/json": {"schema": {"$ref": "#/components/schemas/TransferRequest"}}}
+        },
+        "responses": {
+          "200": {"description": "The fee", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/FeeQuote"}}}},
+          "400": {"description": "Malformed body", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Error"}}}},
+          "401": {"$ref": "#/components/responses/Unauthenticated"},
+          "403": {"$ref": "#/components/responses/Forbidden"},
+          "415": {"description": "Body is not application/json", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Error"}}}},
+          "422": {"description": "Validation failed", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Error"}}}}
+        }
+      }
+    },
+    "/api/v1/transfers/{ref}": {
+      "get": {
+        "summary": "Get a transfer with its live progress or final result",
+        "description": "Another customer's transfer is reported as not found",
+        "parameters": [
+          {"name": "ref", "in": "path", "required": true, "schema": {"type": "string"}}
+        ],
+        "responses": {
+          "200": {"description": "The transfer", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Transfer"}}}},
+          "401": {"$ref": "#/components/responses/Unauthenticated"},
+          "403": {"$ref": "#/components/responses/Forbidden"},
+          "404": {"description": "Unknown reference", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Error"}}}}
+        }
+      },
+      "patch": {
+        "summary": "Change when a future-dated transfer is due, or its amount",
+        "description": "Only while the transfer is scheduled; the change is applied by the transfer, so the response shows it as it was",
+        "parameters": [
+          {"name": "ref", "in": "path", "required": true, "schema": {"type": "string"}}
+        ],
+        "requestBody": {
+          "required": true,
+          "content": {"application/json": {"schema": {"$ref": "#/components/schemas/ScheduleEdit"}}}
+        },
+        "responses": {
+          "202": {"description": "Change sent", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Transfer"}}}},
+          "400": {"description": "Malformed body", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Error"}}}},
+          "401": {"$ref": "#/components/responses/Unauthenticated"},
+          "403": {"$ref": "#/components/responses/Forbidden"},
+          "404": {"description": "Unknown reference", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Error"}}}},
+          "409": {"description": "The transfer is already under way", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Error"}}}},
+          "415": {"description": "Body is not application/json", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Error"}}}},
+          "422": {"description": "Validation failed", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Error"}}}}
+        }
+      },
+      "delete": {
+        "summary": "Cancel a transfer that has not reached its deposit",
+        "description": "Money already withdrawn is refunded. The cancellation is applied by the transfer, so the response shows it as it was; its result ends up cancelled, or whatever it completed with if the deposit came first",
+        "parameters": [
+          {"name": "ref", "in": "path", "required": true, "schema": {"type": "string"}}
+        ],
+        "responses": {
+          "202": {"description": "Cancellation requested", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Transfer"}}}},
+          "401": {"$ref": "#/components/responses/Unauthenticated"},
+          "403": {"$ref": "#/components/responses/Forbidden"},
+          "404": {"description": "Unknown reference", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Error"}}}},
+          "409": {"description": "The transfer can no longer be cancelled", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Error"}}}}
+        }
+      }
+    },
+    "/api/v1/standing-orders": {
+      "post": {
+        "summary": "Set up a standing order",
+        "description": "The transfer is repeated from startAt until the order is cancelled, passes endAt or has made count payments. Each payment falling on a non-business day is made on the next one, and is charged the fee",
+        "requestBody": {
+          "required": true,
+          "content": {"application/json": {"schema": {"$ref": "#/components/schemas/StandingOrderRequest"}}}
+        },
+        "responses": {
+          "201": {"description": "Standing order set up", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/StandingOrder"}}}},
+          "400": {"description": "Malformed body", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Error"}}}},
+          "401": {"$ref": "#/components/responses/Unauthenticated"},
+          "403": {"$ref": "#/components/responses/Forbidden"},
+          "409": {"description": "Reference already used", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Error"}}}},
+          "415": {"description": "Body is not application/json", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Error"}}}},
+          "422": {"description": "Validation failed", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Error"}}}}
+        }
+      },
+      "get": {
+        "summary": "List standing orders, most recent first",
+        "description": "Customers only see their own standing orders; ops see every one",
+        "parameters": [
+          {"name": "pageSize", "in": "query", "schema": {"type": "integer", "minimum": 1, "maximum": 100, "default": 20}},
+          {"name": "pageToken", "in": "query", "schema": {"type": "string"}}
+        ],
+        "responses": {
+          "200": {"description": "A page of standing orders", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/StandingOrderList"}}}},
+          "400": {"description": "Bad paging parameters", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Error"}}}},
+          "401": {"$ref": "#/components/responses/Unauthenticated"},
+          "403": {"$ref": "#/components/responses/Forbidden"}
+        }
+      }
+    },
+    "/api/v1/standing-orders/{ref}": {
+      "get": {
+        "summary": "Get a standing order with its recent payments",
+        "description": "Another customer's standing order is reported as not found",
+        "parameters": [
+          {"name": "ref", "in": "path", "required": true, "schema": {"type": "string"}}
+        ],
+        "responses": {
+          "200": {"description": "The standing order", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/StandingOrder"}}}},
+          "401": {"$ref": "#/components/responses/Unauthenticated"},
+          "403": {"$ref": "#/components/responses/Forbidden"},
+          "404": {"description": "Unknown reference", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Error"}}}}
+        }
+      },
+      "patch": {
+        "summary": "Amend the payments still to come",
+        "description": "The change is applied by the standing order, so the response shows it as it was",
+        "parameters": [
+          {"name": "ref", "in": "path", "required": true, "schema": {"type": "string"}}
+        ],
+        "requestBody": {
+          "required": true,
+          "content": {"application/json": {"schema": {"$ref": "#/components/schemas/StandingOrderAmendment"}}}
+        },
+        "responses": {
+          "202": {"description": "Amendment sent", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/StandingOrder"}}}},
+          "400": {"description": "Malformed body", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Error"}}}},
+          "401": {"$ref": "#/components/responses/Unauthenticated"},
+          "403": {"$ref": "#/components/responses/Forbidden"},
+          "404": {"description": "Unknown reference", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Error"}}}},
+          "409": {"description": "The standing order has ended", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Error"}}}},
+          "415": {"description": "Body is not application/json", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Error"}}}},
+          "422": {"description": "Validation failed", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Error"}}}}
+        }
+      },
+      "delete": {
+        "summary": "Cancel a standing order",
+        "description": "A payment already under way is not cancelled with it",
+        "parameters": [
+          {"name": "ref", "in": "path", "required": true, "schema": {"type": "string"}}
+        ],
+        "responses": {
+          "202": {"description": "Cancellation sent", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/StandingOrder"}}}},
+          "401": {"$ref": "#/components/responses/Unauthenticated"},
+          "403": {"$ref": "#/components/responses/Forbidden"},
+          "404": {"description": "Unknown reference", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Error"}}}},
+          "409": {"description": "The standing order has ended", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Error"}}}}
+        }
+      }
+    },
+    "/api/v1/standing-orders/{ref}/pause": {
+      "post": {
+        "summary": "Pause a standing order; payments falling due while it is paused are skipped",
+        "parameters": [
+          {"name": "ref", "in": "path", "required": true, "schema": {"type": "string"}}
+        ],
+        "responses": {
+          "202": {"description": "Pause sent", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/StandingOrder"}}}},
+          "401": {"$ref": "#/components/responses/Unauthenticated"},
+          "403": {"$ref": "#/components/responses/Forbidden"},
+          "404": {"description": "Unknown reference", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Error"}}}},
+          "409": {"description": "The standing order has ended", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Error"}}}}
+        }
+      }
+    },
+    "/api/v1/standing-orders/{ref}/resume": {
+      "post": {
+        "summary": "Resume a standing order paused by the customer or after failed payments",
+        "parameters": [
+          {"name": "ref", "in": "path", "required": true, "schema": {"type": "string"}}
+        ],
+        "responses": {
+          "202": {"description": "Resumption sent", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/StandingOrder"}}}},
+          "401": {"$ref": "#/components/responses/Unauthenticated"},
+          "403": {"$ref": "#/components/responses/Forbidden"},
+          "404": {"description": "Unknown reference", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Error"}}}},
+          "409": {"description": "The standing order has ended", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Error"}}}}
+        }
+      }
+    }
+  },
+  "components": {
+    "securitySchemes": {
+      "basicAuth": {"type": "http", "scheme": "basic"},
+      "bearerAuth": {"type": "http", "scheme": "bearer", "bearerFormat": "JWT"}
+    },
+    "responses": {
+      "Unauthenticated": {"description": "Missing or bad credentials", "content": {"text/plain": {"schema": {"type": "string"}}}},
+      "Forbidden": {"description": "The user lacks the customer role", "content": {"text/plain": {"schema": {"type": "string"}}}}
+    },
+    "schemas": {
+      "TransferRequest": {
+        "type": "object",
+        "required": ["sourceBank", "sourceAccount", "destinationBank", "destinationAccount", "amount", "reference"],
+        "properties": {
+          "sourceBank": {"type": "string", "example": "abbank"},
+          "sourceAccount": {"type": "string"},
+          "sourceName": {"type": "string", "description": "Source account holder, used for sanctions screening"},
+          "destinationBank": {"type": "string", "example": "bcbank"},
+          "destinationAccount": {"type": "string"},
+          "destinationName": {"type": "string", "description": "Destination account holder, used for sanctions screening"},
+          "amount": {"type": "string", "pattern": "^[0-9]+(\\.[0-9]+)?$", "example": "125.50", "description": "A positive decimal in sourceCurrency with no more decimal places than it has; a JSON number is also accepted"},
+          "sourceCurrency": {"type": "string", "example": "USD", "description": "Currency the source account pays in, defaults to the first supported currency"},
+          "destinationCurrency": {"type": "string", "example": "EUR", "description": "Currency the destination receives, defaults to sourceCurrency"},
+          "reference": {"type": "string", "description": "Unique per transfer, without #"},
+          "acceptedFee": {"type": "string", "example": "2.50", "description": "The most the customer agreed to be charged, usually the quoted total; the transfer fails rather than charge more"},
+          "executeAt": {"type": "string", "format": "date-time", "description": "Send the transfer then rather than now, or on the next business day when it is not one"}
+        }
+      },
+      "ScheduleEdit": {
+        "type": "object",
+        "description": "Send executeAt, amount or both; what is left out is not changed",
+        "minProperties": 1,
+        "properties": {
+          "executeAt": {"type": "string", "format": "date-time"},
+          "amount": {"type": "string", "pattern": "^[0-9]+(\\.[0-9]+)?$", "example": "125.50", "description": "In the transfer's source currency"},
+          "acceptedFee": {"type": "string", "example": "2.50", "description": "The most the customer agrees to be charged for the new amount. Required when the amount changes and the transfer was started with an acceptedFee; otherwise the previous one is kept"}
+        }
+      },
+      "Transfer": {
+        "type": "object",
+        "properties": {
+          "reference": {"type": "string"},
+          "runId": {"type": "string"},
+          "state": {"type": "string", "enum": ["running", "completed", "failed", "canceled", "terminated", "continuedasnew", "timedout"]},
+          "startTime": {"type": "string", "format": "date-time"},
+          "closeTime": {"type": "string", "format": "date-time"},
+          "progress": {"$ref": "#/components/schemas/Progress"},
+          "result": {"$ref": "#/components/schemas/Result"}
+        }
+      },
+      "Progress": {
+        "type": "object",
+        "properties": {
+          "stage": {"type": "string", "enum": ["scheduled", "started", "sanctions-screening", "aml-review", "locking-fx-rate", "withdrawing", "depositing", "collecting-fee", "refunding", "awaiting-refund-resolution", "completed"]},
+          "stageDescription": {"type": "string"},
+          "stageSince": {"type": "string", "format": "date-time"},
+          "moneyLaunderingDecision": {"$ref": "#/components/schemas/AMLDecision"},
+          "moneyLaunderingCheckAt": {"type": "string", "format": "date-time"},
+          "withdrawnAt": {"type": "string", "format": "date-time"},
+          "depositedAt": {"type": "string", "format": "date-time"},
+          "refundedAt": {"type": "string", "format": "date-time"},
+          "attempts": {"type": "object", "additionalProperties": {"type": "integer"}},
+          "lastFailure": {"type": "string"},
+          "amlEscalations": {"type": "array", "items": {"$ref": "#/components/schemas/AMLEscalation"}},
+          "fxQuote": {"$ref": "#/components/schemas/FXQuote"},
+          "fee": {"$ref": "#/components/schemas/FeeQuote"},
+          "scheduledFor": {"type": "string", "format": "date-time", "description": "When a future-dated transfer is due, moved onto a business day"},
+          "scheduleEdits": {"type": "integer"},
+          "cancelRequested": {"type": "boolean"},
+          "cancellable": {"type": "boolean"}
+        }
+      },
+      "Result": {
+        "type": "object",
+        "properties": {
+          "status": {"type": "string", "enum": ["success", "failure", "refunded", "refund_failed", "cancelled"]},
+          "failureReason": {"type": "string"},
+          "startedAt": {"type": "string", "format": "date-time"},
+          "scheduledFor": {"type": "string", "format": "date-time"},
+          "moneyLaunderingCheckAt": {"type": "string", "format": "date-time"},
+          "withdrawnAt": {"type": "string", "format": "date-time"},
+          "depositedAt": {"type": "string", "format": "date-time"},
+          "refundedAt": {"type": "string", "format": "date-time"},
+          "refundAttempts": {"type": "integer"},
+          "moneyLaunderingDecision": {"$ref": "#/components/schemas/AMLDecision"},
+          "amlEscalations": {"type": "array", "items": {"$ref": "#/components/schemas/AMLEscalation"}},
+          "fxQuote": {"$ref": "#/components/schemas/FXQuote"},
+          "fee": {"$ref": "#/components/schemas/FeeQuote"}
+        }
+      },
+      "FXQuote": {
+        "type": "object",
+        "description": "The exchange rate locked for the transfer, set when the currencies differ",
+        "properties": {
+          "quoteId": {"type": "string"},
+          "from": {"type": "string"},
+          "to": {"type": "string"},
+          "rate": {"type": "number", "description": "Units of to per unit of from"},
+          "amount": {"$ref": "#/components/schemas/Money"},
+          "convertedAmount": {"$ref": "#/components/schemas/Money", "description": "What the destination account is credited"},
+          "expiresAt": {"type": "string", "format": "date-time"}
+        }
+      },
+      "FeeQuote": {
+        "type": "object",
+        "description": "The fee charged on top of the amount, in its currency; refunded when the transfer does not complete",
+        "properties": {
+          "items": {"type": "array", "items": {"$ref": "#/components/schemas/FeeItem"}},
+          "total": {"$ref": "#/components/schemas/Money"},
+          "debit": {"$ref": "#/components/schemas/Money", "description": "Amount plus fee, only in quotes"}
+        }
+      },
+      "FeeItem": {
+        "type": "object",
+        "properties": {
+          "name": {"type": "string", "enum": ["flat", "percentage", "tiered", "minimum fee", "fee cap"]},
+          "amount": {"$ref": "#/components/schemas/Money", "description": "Negative for the fee cap"}
+        }
+      },
+      "Money": {
+        "type": "object",
+        "description": "An exact amount",
+        "properties": {
+          "amount": {"type": "string", "example": "125.50", "description": "Decimal with the currency's number of decimal places"},
+          "currency": {"type": "string", "example": "EUR"}
+        }
+      },
+      "AMLEscalation": {
+        "type": "object",
+        "properties": {
+          "event": {"type": "string", "enum": ["reminder", "escalated", "expired"]},
+          "at": {"type": "string", "format": "date-time"},
+          "reminder": {"type": "integer", "description": "Number of the reminder"}
+        }
+      },
+      "AMLDecision": {
+        "type": "object",
+        "properties": {
+          "decision": {"type": "string", "enum": ["approve", "reject"]},
+          "reviewer": {"type": "string"},
+          "reasonCode": {"type": "string"},
+          "comment": {"type": "string"},
+          "decidedAt": {"type": "string", "format": "date-time"},
+          "approvers": {"type": "array", "items": {"type": "string"}, "description": "Set when two approvals were required"},
+          "riskScore": {"type": "integer", "description": "Score the rules engine gave the transfer"},
+          "triggeredRules": {"type": "array", "items": {"type": "string"}, "description": "Rules that contributed to the score"}
+        }
+      },
+      "TransferList": {
+        "type": "object",
+        "properties": {
+          "transfers": {"type": "array", "items": {"$ref": "#/components/schemas/Transfer"}},
+          "nextPageToken": {"type": "string"}
+        }
+      },
+      "StandingOrderRequest": {
+        "type": "object",
+        "required": ["transfer", "recurrence", "startAt"],
+        "properties": {
+          "transfer": {"$ref": "#/components/schemas/TransferRequest", "description": "The payment to repeat, without executeAt; its reference names the standing order and must not contain # or /"},
+          "recurrence": {"$ref": "#/components/schemas/Recurrence"},
+          "startAt": {"type": "string", "format": "date-time", "description": "The first payment; later ones keep its day and time of day"},
+          "endAt": {"type": "string", "format": "date-time", "description": "No payment falls due after it"},
+          "count": {"type": "integer", "minimum": 1, "description": "How many payments there are, skipped ones included"}
+        }
+      },
+      "Recurrence": {
+        "type": "object",
+        "required": ["frequency"],
+        "properties": {
+          "frequency": {"type": "string", "enum": ["daily", "weekly", "monthly"], "description": "A monthly payment falls on the last day of a month too short for its day"},
+          "interval": {"type": "integer", "minimum": 1, "default": 1}
+        }
+      },
+      "StandingOrderAmendment": {
+        "type": "object",
+        "properties": {
+          "amount": {"type": "string", "pattern": "^[0-9]+(\\.[0-9]+)?$", "example": "550.00", "description": "In the source currency of the standing order"},
+          "acceptedFee": {"type": "string", "example": "2.50", "description": "The most the customer agrees to be charged on each payment of the new amount. Required when the amount changes and the standing order has an acceptedFee; otherwise the previous one is kept"},
+          "endAt": {"type": "string", "format": "date-time"}
+        }
+      },
+      "StandingOrder": {
+        "type": "object",
+        "properties": {
+          "reference": {"type": "string"},
+          "runId": {"type": "string"},
+          "state": {"type": "string", "enum": ["running", "completed", "failed", "canceled", "terminated", "continuedasnew", "timedout"]},
+          "startTime": {"type": "string", "format": "date-time"},
+          "closeTime": {"type": "string", "format": "date-time"},
+          "order": {"$ref": "#/components/schemas/StandingOrderDetail"}
+        }
+      },
+      "StandingOrderDetail": {
+        "type": "object",
+        "properties": {
+          "status": {"type": "string", "enum": ["active", "paused", "completed", "cancelled"]},
+          "pausedReason": {"type": "string", "description": "Set when the standing order paused itself after payments in a row failed"},
+          "sourceBank": {"type": "string"},
+          "sourceAccount": {"type": "string"},
+          "destinationBank": {"type": "string"},
+          "destinationAccount": {"type": "string"},
+          "amount": {"$ref": "#/components/schemas/Money"},
+          "destinationCurrency": {"type": "string"},
+          "acceptedFee": {"$ref": "#/components/schemas/Money"},
+          "recurrence": {"$ref": "#/components/schemas/Recurrence"},
+          "startAt": {"type": "string", "format": "date-time"},
+          "endAt": {"type": "string", "format": "date-time"},
+          "count": {"type": "integer"},
+          "nextDueAt": {"type": "string", "format": "date-time"},
+          "currentTransfer": {"type": "string", "description": "Reference of the payment under way"},
+          "transfers": {"type": "integer"},
+          "failures": {"type": "integer"},
+          "consecutiveFailures": {"type": "integer"},
+          "amendments": {"type": "integer"},
+          "history": {"type": "array", "description": "The most recent payments, oldest first", "items": {"$ref": "#/components/schemas/StandingOrderPayment"}}
+        }
+      },
+      "StandingOrderPayment": {
+        "type": "object",
+        "properties": {
+          "number": {"type": "integer", "description": "Counting from 1"},
+          "reference": {"type": "string", "description": "Of the transfer, REF#NUMBER; absent when skipped"},
+          "dueAt": {"type": "string", "format": "date-time"},
+          "amount": {"$ref": "#/components/schemas/Money"},
+          "status": {"type": "string", "enum": ["success", "failure", "refunded", "refund_failed", "cancelled", "skipped", "not_started"]},
+          "failureReason": {"type": "string"}
+        }
+      },
+      "StandingOrderList": {
+        "type": "object",
+        "properties": {
+          "standingOrders": {"type": "array", "items": {"$ref": "#/components/schemas/StandingOrder"}},
+          "nextPageToken": {"type": "string"}
+        }
+      },
+      "Error": {
+        "type": "object",
+        "properties": {
+          "error": {"type": "string"},
+          "message": {"type": "string"},
+          "fields": {"type": "object", "additionalProperties": {"type": "string"}}
+        }
+      }
+    }
+  }
+}
+`
@@ -2,14 +2,21 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"html/template"
 	"log"
 	"net/http"
-	"strconv"
+	"sort"
+	"strings"
 	"time"
 
+	"github.com/arunsworld/temporal-demo/bankingdemo/auth"
+	"github.com/arunsworld/temporal-demo/bankingdemo/calendar"
+	"github.com/arunsworld/temporal-demo/bankingdemo/contracts"
+	"github.com/arunsworld/temporal-demo/bankingdemo/fees"
 	"go.temporal.io/api/enums/v1"
+	"go.temporal.io/api/workflow/v1"
 	"go.temporal.io/sdk/client"
 )
 
@@ -17,25 +24,54 @@ type service struct {
 	ctx            context.Context
 	workflowClient client.Client
 	tmpl           *template.Template
+	banks          []string
+	currencies     []string // the first is the default
+	fees           fees.Schedule
+	calendar       calendar.Calendar
+	notifications  *notifications
+	authn          auth.Authenticator
 }
 
-func newService(ctx context.Context, c client.Client) *service {
+func newService(ctx context.Context, c client.Client, banks, currencies []string, schedule fees.Schedule,
+	cal calendar.Calendar, notices *notifications, authn auth.Authenticator) *service {
 	tmpl := template.Must(template.New("index").Parse(indexHTML))
+	tmpl = template.Must(tmpl.New("confirm").Parse(confirmHTML))
+	tmpl = template.Must(tmpl.New("edit").Parse(editHTML))
 	tmpl = template.Must(tmpl.New("success").Parse(successHTML))
 	tmpl = template.Must(tmpl.New("status").Parse(statusHTML))
 	tmpl = template.Must(tmpl.New("running").Parse(runningWorkflowHTML))
+	tmpl = template.Must(tmpl.New("refunds").Parse(refundsHTML))
+	tmpl = template.Must(tmpl.New("standing-orders").Parse(standingOrdersHTML))
+	tmpl = template.Must(tmpl.New("standing-order").Parse(standingOrderHTML))
 	result := &service{
 		ctx:            ctx,
 		workflowClient: c,
 		tmpl:           tmpl,
+		banks:          banks,
+		currencies:     currencies,
+		fees:           schedule,
+		calendar:       cal,
+		notifications:  notices,
+		authn:          authn,
 	}
 	result.registerHandlers()
+	result.registerAPIHandlers()
 	return result
 }
 
 func (s *service) registerHandlers() {
-	http.HandleFunc("/", s.indexHandler)
-	http.HandleFunc("/status", s.statusHandler)
+	http.HandleFunc("/", s.protect(s.indexHandler, auth.RoleCustomer))
+	http.HandleFunc("/status", s.protect(s.statusHandler, auth.RoleCustomer, auth.RoleOps))
+	http.HandleFunc("/cancel", s.protect(s.cancelHandler, auth.RoleCustomer, auth.RoleOps))
+	http.HandleFunc("/edit", s.protect(s.editHandler, auth.RoleCustomer))
+	http.HandleFunc("/standing-orders", s.protect(s.standingOrdersHandler, auth.RoleCustomer))
+	http.HandleFunc("/standing-order", s.protect(s.standingOrderHandler, auth.RoleCustomer))
+	http.HandleFunc("/ops/refunds", s.protect(s.refundsHandler, auth.RoleOps))
+}
+
+// protect lets only users holding one of roles through to the page h, and only with a CSRF token when they post
+func (s *service) protect(h http.HandlerFunc, roles ...string) http.HandlerFunc {
+	return auth.Require(s.authn, auth.ProtectCSRF(h), roles...)
 }
 
 type FormField struct {
@@ -45,7 +81,9 @@ type FormField struct {
 
 var formFields = []FormField{
 	{Field: "faccount", Label: "Source account"},
+	{Field: "fname", Label: "Source account holder (optional)"},
 	{Field: "daccount", Label: "Destination account"},
+	{Field: "dname", Label: "Destination account holder (optional)"},
 	{Field: "amount", Label: "Amount"},
 	{Field: "ref", Label: "Reference"},
 }
@@ -53,9 +91,17 @@ var formFields = []FormField{
 func (s *service) indexHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method == "GET" {
 		data := struct {
+			Banks      []string
+			Currencies []string
 			FormFields []FormField
+			Timezone   string
+			CSRF       string
 		}{
+			Banks:      s.banks,
+			Currencies: s.currencies,
 			FormFields: formFields,
+			Timezone:   s.calendar.Location().String(),
+			CSRF:       auth.CSRFToken(w, r),
 		}
 		if err := s.tmpl.ExecuteTemplate(w, "index", data); err != nil {
 			w.WriteHeader(http.StatusInternalServerError)
@@ -69,17 +115,33 @@ func (s *service) indexHandler(w http.ResponseWriter, r *http.Request) {
 			fmt.Fprintf(w, "ERROR: %v", err)
 			return
 		}
-		req, err := parseFormFields(r)
+		req, err := s.parseFormFields(r)
 		if err != nil {
 			w.WriteHeader(http.StatusInternalServerError)
 			fmt.Fprintf(w, "ERROR: %v", err)
 			return
 		}
-		options := client.StartWorkflowOptions{
-			TaskQueue: "clearing-house",
-			ID:        fmt.Sprintf("MT: %s", req.Ref),
+		if r.Form.Get("confirm") == "" {
+			hidden := formValues(r, "fbank", "dbank", "fcurrency", "dcurrency", "when")
+			for _, f := range formFields {
+				hidden = append(hidden, formValues(r, f.Field)...)
+			}
+			s.confirmTransfer(w, r, req, "/", hidden, "")
+			return
+		}
+		fee, problem := parseAmount(r.Form.Get("fee"), req.Amount.Currency)
+		if problem != "" {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprintf(w, "ERROR: fee %s", problem)
+			return
+		}
+		req.AcceptedFee = &fee
+		workflowRun, err := contracts.StartMoneyTransfer(s.ctx, s.workflowClient, req, auth.FromContext(r.Context()).Subject)
+		if errors.Is(err, contracts.ErrDuplicateReference) {
+			w.WriteHeader(http.StatusConflict)
+			fmt.Fprintf(w, "ERROR: %v", err)
+			return
 		}
-		workflowRun, err := s.workflowClient.ExecuteWorkflow(s.ctx, options, "MoneyTransfer", req)
 		if err != nil {
 			w.WriteHeader(http.StatusInternalServerError)
 			fmt.Fprintf(w, "ERROR: %v", err)
@@ -101,35 +163,115 @@ func (s *service) indexHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-func parseFormFields(r *http.Request) (Request, error) {
-	amtStr := r.Form.Get("amount")
-	amt, err := strconv.ParseFloat(amtStr, 64)
+type hiddenField struct {
+	Name  string
+	Value string
+}
+
+func formValues(r *http.Request, names ...string) []hiddenField {
+	result := []hiddenField{}
+	for _, name := range names {
+		result = append(result, hiddenField{Name: name, Value: r.Form.Get(name)})
+	}
+	return result
+}
+
+// confirmTransfer shows the customer the fee, and when a future-dated transfer falls due, before it is sent.
+// Confirming posts hidden back to action with the fee the customer accepted. repeats describes how a standing
+// order repeats the transfer, which is charged the fee every time.
+func (s *service) confirmTransfer(w http.ResponseWriter, r *http.Request, req contracts.Request, action string, hidden []hiddenField,
+	repeats string) {
+	fee, err := s.fees.Quote(req)
 	if err != nil {
-		return Request{}, fmt.Errorf("amount is an invalid number")
+		w.WriteHeader(http.StatusInternalServerError)
+		fmt.Fprintf(w, "ERROR: %v", err)
+		return
 	}
-	req := Request{
-		SourceBank:      "abbank",
-		SourceAcc:       r.Form.Get("faccount"),
-		DestinationBank: "bcbank",
-		DestinationAcc:  r.Form.Get("daccount"),
-		Ref:             r.Form.Get("ref"),
-		Amount:          amt,
+	data := struct {
+		Request contracts.Request
+		Fee     contracts.FeeQuote
+		Debit   contracts.Money
+		Due     string
+		Moved   bool // the execution time was not on a business day
+		Repeats string
+		Action  string
+		Hidden  []hiddenField
+		CSRF    string
+	}{
+		Request: req,
+		Fee:     fee,
+		Debit:   contracts.Money{Minor: req.Amount.Minor + fee.Total.Minor, Currency: req.Amount.Currency},
+		Repeats: repeats,
+		Action:  action,
+		Hidden:  hidden,
+		CSRF:    auth.CSRFToken(w, r),
+	}
+	if !req.ExecuteAt.IsZero() {
+		due, err := s.calendar.NextBusinessTime(req.ExecuteAt)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprintf(w, "ERROR: %v", err)
+			return
+		}
+		data.Due = formatDue(due)
+		data.Moved = !due.Equal(req.ExecuteAt)
 	}
-	if req.SourceAcc == "" {
-		return Request{}, fmt.Errorf("source account is empty")
+	if err := s.tmpl.ExecuteTemplate(w, "confirm", data); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		fmt.Fprintf(w, "ERROR: %v", err)
+		return
 	}
-	if req.DestinationAcc == "" {
-		return Request{}, fmt.Errorf("destination account is empty")
+	w.Header().Add("Content-Type", "text/html; charset=utf-8")
+}
+
+func (s *service) parseFormFields(r *http.Request) (contracts.Request, error) {
+	amt, problem := parseAmount(strings.TrimSpace(r.Form.Get("amount")), r.Form.Get("fcurrency"))
+	if problem != "" {
+		return contracts.Request{}, fmt.Errorf("amount %s", problem)
 	}
-	if req.Ref == "" {
-		return Request{}, fmt.Errorf("reference is empty")
+	req := contracts.Request{
+		SourceBank:          r.Form.Get("fbank"),
+		SourceAcc:           r.Form.Get("faccount"),
+		SourceName:          strings.TrimSpace(r.Form.Get("fname")),
+		DestinationBank:     r.Form.Get("dbank"),
+		DestinationAcc:      r.Form.Get("daccount"),
+		DestinationName:     strings.TrimSpace(r.Form.Get("dname")),
+		Ref:                 r.Form.Get("ref"),
+		Amount:              amt,
+		DestinationCurrency: r.Form.Get("dcurrency"),
 	}
-	return req, nil
+	if when := strings.TrimSpace(r.Form.Get("when")); when != "" {
+		executeAt, err := s.parseWhen(when)
+		if err != nil {
+			return contracts.Request{}, err
+		}
+		req.ExecuteAt = executeAt
+	}
+	problems := s.validateRequest(req)
+	if len(problems) == 0 {
+		return req, nil
+	}
+	return contracts.Request{}, problemsError(problems)
+}
+
+// problemsError lists validation problems, by field, in one error
+func problemsError(problems map[string]string) error {
+	fields := make([]string, 0, len(problems))
+	for field := range problems {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	messages := []string{}
+	for _, field := range fields {
+		messages = append(messages, field+" "+problems[field])
+	}
+	return fmt.Errorf("%s", strings.Join(messages, "; "))
 }
 
 func (s *service) statusHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method == "GET" {
-		if err := s.tmpl.ExecuteTemplate(w, "status", nil); err != nil {
+		data := struct{ CSRF string }{CSRF: auth.CSRFToken(w, r)}
+		if err := s.tmpl.ExecuteTemplate(w, "status", data); err != nil {
 			w.WriteHeader(http.StatusInternalServerError)
 			fmt.Fprintf(w, "ERROR: %v", err)
 			return
@@ -146,43 +288,88 @@ func (s *service) statusHandler(w http.ResponseWriter, r *http.Request) {
 			http.Redirect(w, r, "/status", http.StatusSeeOther)
 			return
 		}
-		wid := fmt.Sprintf("MT: %s", ref)
-		resp, err := s.workflowClient.DescribeWorkflowExecution(s.ctx, wid, "")
+		resp, err := s.workflowClient.DescribeWorkflowExecution(s.ctx, contracts.MoneyTransferID(ref), "")
 		if err != nil {
 			log.Println(err)
 			w.WriteHeader(http.StatusBadRequest)
 			fmt.Fprintf(w, "Unable to get reference (%v). Go back and try again.", err)
 			return
 		}
+		if !mayAccess(auth.FromContext(r.Context()), resp.WorkflowExecutionInfo) {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, "Unable to get reference (not found). Go back and try again.")
+			return
+		}
 		status := resp.WorkflowExecutionInfo.Status
 		switch status {
 		case enums.WORKFLOW_EXECUTION_STATUS_COMPLETED:
 			runID := resp.WorkflowExecutionInfo.Execution.RunId
-			resp := Response{}
-			if err := s.workflowClient.GetWorkflow(s.ctx, wid, runID).Get(s.ctx, &resp); err != nil {
+			resp, err := contracts.GetMoneyTransferResult(s.ctx, s.workflowClient, ref, runID)
+			if err != nil {
 				w.WriteHeader(http.StatusInternalServerError)
 				fmt.Fprintf(w, "ERROR: %v", err)
 				return
 			}
-			fmt.Fprint(w, resp.String())
+			fmt.Fprint(w, formatResponse(resp))
+			for _, n := range s.notifications.forRef(ref) {
+				fmt.Fprintf(w, "\n\nMessage from us (%v): %s", n.At, n.Message)
+			}
 		case enums.WORKFLOW_EXECUTION_STATUS_RUNNING:
+			progress, err := contracts.QueryMoneyTransferProgress(s.ctx, s.workflowClient, ref)
+			if err != nil {
+				w.WriteHeader(http.StatusInternalServerError)
+				fmt.Fprintf(w, "ERROR: %v", err)
+				return
+			}
 			pad := []pendingActivityDetails{}
 			for _, v := range resp.PendingActivities {
 				lastFailure := "None"
 				if v.LastFailure != nil {
-					lastFailure = v.LastFailure.String()
+					lastFailure = v.LastFailure.GetMessage()
+				}
+				var lastStarted time.Duration
+				if v.LastStartedTime != nil {
+					lastStarted = time.Since(*v.LastStartedTime).Round(time.Second)
 				}
 				pad = append(pad, pendingActivityDetails{
 					ActivityName: v.ActivityType.Name,
 					State:        v.State.String(),
-					LastStarted:  time.Since(*v.LastStartedTime),
+					Attempt:      v.Attempt,
+					LastStarted:  lastStarted,
 					LastFailure:  lastFailure,
 				})
 			}
 			data := struct {
+				Ref               string
+				Stage             string
+				Progress          contracts.Progress
 				PendingActivities []pendingActivityDetails
+				AMLEscalations    []string
+				Notifications     []notification
+				FXQuote           string
+				Fee               string
+				Due               string
+				CSRF              string
 			}{
+				Ref:               ref,
+				Stage:             describeStage(progress.Stage),
+				Progress:          progress,
 				PendingActivities: pad,
+				Notifications:     s.notifications.forRef(ref),
+				CSRF:              auth.CSRFToken(w, r),
+			}
+			if progress.FXQuote != nil {
+				data.FXQuote = describeFXQuote(*progress.FXQuote)
+			}
+			if progress.Fee != nil {
+				data.Fee = describeFee(*progress.Fee)
+			}
+			if !progress.ScheduledFor.IsZero() {
+				data.Due = formatDue(progress.ScheduledFor)
+			}
+			for _, e := range progress.AMLEscalations {
+				data.AMLEscalations = append(data.AMLEscalations,
+					fmt.Sprintf("AML review %s at %s", describeAMLEscalation(e), e.At.Format("15:04:05 Jan 2")))
 			}
 			if err := s.tmpl.ExecuteTemplate(w, "running", data); err != nil {
 				w.WriteHeader(http.StatusInternalServerError)
@@ -196,13 +383,263 @@ func (s *service) statusHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+func (s *service) cancelHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		fmt.Fprintf(w, "ERROR: %v", err)
+		return
+	}
+	ref := r.Form.Get("ref")
+	if ref == "" {
+		http.Redirect(w, r, "/status", http.StatusSeeOther)
+		return
+	}
+	resp, err := s.workflowClient.DescribeWorkflowExecution(s.ctx, contracts.MoneyTransferID(ref), "")
+	if err == nil && !mayAccess(auth.FromContext(r.Context()), resp.WorkflowExecutionInfo) {
+		err = fmt.Errorf("not found")
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprintf(w, "Unable to cancel reference (%v). Go back and try again.", err)
+		return
+	}
+	if err := contracts.CancelMoneyTransfer(s.ctx, s.workflowClient, ref); err != nil {
+		if errors.Is(err, contracts.ErrNotCancellable) {
+			w.WriteHeader(http.StatusConflict)
+			fmt.Fprint(w, "The transfer has already reached the destination bank and can no longer be cancelled.")
+			return
+		}
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(w, "Unable to cancel reference (%v). Go back and try again.", err)
+		return
+	}
+	fmt.Fprintf(w, "Cancellation requested for %s. Any money already withdrawn will be refunded. Check the status page for the outcome.", ref)
+}
+
+// refundsHandler lets operators resolve transfers whose refund keeps failing
+func (s *service) refundsHandler(w http.ResponseWriter, r *http.Request) {
+	data := struct {
+		Actions  []string
+		Message  string
+		Operator string // the signed in operator, asked for when authentication is off
+		CSRF     string
+	}{
+		Actions:  []string{contracts.RefundRetry, contracts.RefundForce, contracts.RefundWriteOff},
+		Operator: auth.FromContext(r.Context()).Subject,
+		CSRF:     auth.CSRFToken(w, r),
+	}
+	if r.Method != "GET" {
+		if err := r.ParseForm(); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			fmt.Fprintf(w, "ERROR: %v", err)
+			return
+		}
+		ref := r.Form.Get("ref")
+		resolution := contracts.RefundResolution{
+			Action:   r.Form.Get("action"),
+			Operator: data.Operator,
+			Comment:  r.Form.Get("comment"),
+		}
+		if resolution.Operator == "" {
+			resolution.Operator = r.Form.Get("operator")
+		}
+		if ref == "" || resolution.Operator == "" {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, "Reference and operator are required. Go back and try again.")
+			return
+		}
+		if err := contracts.ResolveRefund(s.ctx, s.workflowClient, ref, resolution); err != nil {
+			if errors.Is(err, contracts.ErrNotAwaitingRefundResolution) {
+				w.WriteHeader(http.StatusConflict)
+				fmt.Fprintf(w, "%s is not waiting for a refund resolution. Go back and refresh the list.", ref)
+				return
+			}
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprintf(w, "Unable to resolve reference (%v). Go back and try again.", err)
+			return
+		}
+		data.Message = fmt.Sprintf("Sent %s for %s", resolution.Action, ref)
+	}
+	if err := s.tmpl.ExecuteTemplate(w, "refunds", data); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		fmt.Fprintf(w, "ERROR: %v", err)
+		return
+	}
+	w.Header().Add("Content-Type", "text/html; charset=utf-8")
+}
+
+// whenLayout is what a datetime-local input posts
+const whenLayout = "2006-01-02T15:04"
+
+// parseWhen reads a date and time in the calendar's time zone
+func (s *service) parseWhen(when string) (time.Time, error) {
+	result, err := time.ParseInLocation(whenLayout, when, s.calendar.Location())
+	if err != nil {
+		return time.Time{}, fmt.Errorf("execution time %q is not a date and time", when)
+	}
+	return result, nil
+}
+
+func formatDue(t time.Time) string {
+	return t.Format("Mon Jan 2 2006 15:04 MST")
+}
+
+// editHandler changes when a future-dated transfer falls due, or its amount, until it does. As for a new
+// transfer, the change is only sent once the customer has confirmed the fee.
+func (s *service) editHandler(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		fmt.Fprintf(w, "ERROR: %v", err)
+		return
+	}
+	ref := r.Form.Get("ref")
+	if ref == "" {
+		http.Redirect(w, r, "/status", http.StatusSeeOther)
+		return
+	}
+	resp, err := s.workflowClient.DescribeWorkflowExecution(s.ctx, contracts.MoneyTransferID(ref), "")
+	if err == nil && !mayAccess(auth.FromContext(r.Context()), resp.WorkflowExecutionInfo) {
+		err = fmt.Errorf("not found")
+	}
+	var progress contracts.Progress
+	if err == nil {
+		progress, err = contracts.QueryMoneyTransferProgress(s.ctx, s.workflowClient, ref)
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprintf(w, "Unable to edit reference (%v). Go back and try again.", err)
+		return
+	}
+	if progress.Stage != contracts.StageScheduled {
+		w.WriteHeader(http.StatusConflict)
+		fmt.Fprint(w, "The transfer is already under way and can no longer be changed.")
+		return
+	}
+	req := progress.Request
+	if r.Method == "GET" {
+		data := struct {
+			Ref      string
+			When     string
+			Amount   string
+			Currency string
+			Due      string
+			Timezone string
+			CSRF     string
+		}{
+			Ref:      ref,
+			When:     req.ExecuteAt.In(s.calendar.Location()).Format(whenLayout),
+			Amount:   req.Amount.Decimal(),
+			Currency: req.Amount.Currency,
+			Due:      formatDue(progress.ScheduledFor),
+			Timezone: s.calendar.Location().String(),
+			CSRF:     auth.CSRFToken(w, r),
+		}
+		if err := s.tmpl.ExecuteTemplate(w, "edit", data); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			fmt.Fprintf(w, "ERROR: %v", err)
+			return
+		}
+		w.Header().Add("Content-Type", "text/html; charset=utf-8")
+		return
+	}
+
+	executeAt, err := s.parseWhen(strings.TrimSpace(r.Form.Get("when")))
+	if err == nil && !executeAt.After(time.Now()) {
+		err = fmt.Errorf("execution time must be in the future")
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(w, "ERROR: %v", err)
+		return
+	}
+	amount, problem := parseAmount(strings.TrimSpace(r.Form.Get("amount")), req.Amount.Currency)
+	if problem == "" && amount.Minor <= 0 {
+		problem = "must be a positive amount"
+	}
+	if problem != "" {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(w, "ERROR: amount %s", problem)
+		return
+	}
+	due, err := s.calendar.NextBusinessTime(executeAt)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(w, "ERROR: %v", err)
+		return
+	}
+	req.ExecuteAt, req.Amount = executeAt, amount
+	if r.Form.Get("confirm") == "" {
+		s.confirmTransfer(w, r, req, "/edit", formValues(r, "ref", "when", "amount"), "")
+		return
+	}
+	fee, problem := parseAmount(r.Form.Get("fee"), req.Amount.Currency)
+	if problem != "" {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(w, "ERROR: fee %s", problem)
+		return
+	}
+	edit := contracts.ScheduleEdit{
+		ExecuteAt:   executeAt,
+		Amount:      amount,
+		AcceptedFee: &fee,
+		Editor:      auth.FromContext(r.Context()).Subject,
+	}
+	if err := contracts.EditScheduledTransfer(s.ctx, s.workflowClient, ref, edit); err != nil {
+		if errors.Is(err, contracts.ErrNotScheduled) {
+			w.WriteHeader(http.StatusConflict)
+			fmt.Fprint(w, "The transfer is already under way and can no longer be changed.")
+			return
+		}
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(w, "Unable to edit reference (%v). Go back and try again.", err)
+		return
+	}
+	fmt.Fprintf(w, "Changes sent for %s: %s due %s. Check the status page to see them applied.", ref, amount,
+		formatDue(due))
+}
+
+// mayAccess reports whether the signed in user may see and act on a transfer or standing order: only the customer
+// who started it can, unless the user is in ops or authentication is off.
+func mayAccess(identity auth.Identity, info *workflow.WorkflowExecutionInfo) bool {
+	if identity.Subject == "" || identity.HasRole(auth.RoleOps) {
+		return true
+	}
+	return contracts.MoneyTransferCustomer(info) == identity.Subject
+}
+
 type pendingActivityDetails struct {
 	ActivityName string
 	State        string
+	Attempt      int32
 	LastStarted  time.Duration
 	LastFailure  string
 }
 
+var stageDescriptions = map[string]string{
+	contracts.StageScheduled:                "Scheduled, waiting until it is due",
+	contracts.StageStarted:                  "Starting",
+	contracts.StageSanctionsScreening:       "Running compliance checks",
+	contracts.StageMoneyLaunderingReview:    "Awaiting AML review",
+	contracts.StageLockingFXRate:            "Locking the exchange rate",
+	contracts.StageWithdrawing:              "Withdrawing from source account",
+	contracts.StageDepositing:               "Depositing to destination account",
+	contracts.StageCollectingFee:            "Collecting the transfer fee",
+	contracts.StageRefunding:                "Refunding source account",
+	contracts.StageAwaitingRefundResolution: "Refund failed, awaiting our operations team",
+	contracts.StageCompleted:                "Completed",
+}
+
+func describeStage(stage string) string {
+	if d, ok := stageDescriptions[stage]; ok {
+		return d
+	}
+	return stage
+}
+
 const indexHTML = `
 <!doctype html>
 <html>
@@ -214,15 +651,112 @@ const indexHTML = `
 	<body>
 		<h1>Money Transfer App</h1>
 		<form action="/" method="post">
+			<input type="hidden" name="csrf_token" value="{{.CSRF}}">
+			<div style="margin-bottom: 0.5rem;">
+				<label for="fbank">Source bank:</label>
+				<select name="fbank">
+					{{range .Banks}}<option value="{{.}}">{{.}}</option>{{end}}
+				</select>
+			</div>
+			<div style="margin-bottom: 0.5rem;">
+				<label for="dbank">Destination bank:</label>
+				<select name="dbank">
+					{{range $i, $v := .Banks}}<option value="{{.}}" {{if eq $i 1}}selected{{end}}>{{.}}</option>{{end}}
+				</select>
+			</div>
+			<div style="margin-bottom: 0.5rem;">
+				<label for="fcurrency">Pay in:</label>
+				<select name="fcurrency">
+					{{range .Currencies}}<option value="{{.}}">{{.}}</option>{{end}}
+				</select>
+				<label for="dcurrency">Recipient receives:</label>
+				<select name="dcurrency">
+					{{range .Currencies}}<option value="{{.}}">{{.}}</option>{{end}}
+				</select>
+			</div>
 			{{range $i, $v := .FormFields}}
 			<div style="margin-bottom: 0.5rem;">
 				<label for="{{.Field}}">{{.Label}}:</label>
 				<input type="text" name="{{.Field}}" {{if eq $i 0}}autofocus{{end}}>
 			</div>
 			{{end}}
+			<div style="margin-bottom: 0.5rem;">
+				<label for="when">Send on (optional, {{.Timezone}}):</label>
+				<input type="datetime-local" name="when">
+			</div>
 			
 			<button type="submit">Go</button>
 		</form>
+		<div>
+			<p><a href="/standing-orders">Standing orders</a></p>
+		</div>
+	</body>
+</html>
+`
+
+const confirmHTML = `
+<!doctype html>
+<html>
+	<head>
+		<meta charset="UTF-8">
+		<meta name="viewport" content="width=device-width, initial-scale=1.0">
+		<title>Money Transfer App - Confirm</title>
+	</head>
+	<body>
+		<h1>Money Transfer App</h1>
+		<h3>Please confirm your transfer</h3>
+		<ul>
+			<li>From: {{.Request.SourceAcc}} at {{.Request.SourceBank}}</li>
+			<li>To: {{.Request.DestinationAcc}} at {{.Request.DestinationBank}}</li>
+			{{if .Due}}<li>{{if .Repeats}}First due{{else}}Due{{end}}: {{.Due}}{{if .Moved}}, the next business day{{end}}</li>{{end}}
+			{{if .Repeats}}<li>Repeats {{.Repeats}}; each payment is charged the fee</li>{{end}}
+			<li>Amount: {{.Request.Amount}}{{if ne .Request.DestinationCurrency .Request.Amount.Currency}}, received in {{.Request.DestinationCurrency}} at the rate locked when it is sent{{end}}</li>
+			{{range .Fee.Items}}<li>Fee ({{.Name}}): {{.Amount}}</li>{{end}}
+			<li>Total fee: {{.Fee.Total}}</li>
+			<li>Taken from your account: {{.Debit}}</li>
+		</ul>
+		<p>The fee is refunded if the transfer does not go through.</p>
+		<form action="{{.Action}}" method="post">
+			<input type="hidden" name="csrf_token" value="{{.CSRF}}">
+			{{range .Hidden}}<input type="hidden" name="{{.Name}}" value="{{.Value}}">
+			{{end}}<input type="hidden" name="fee" value="{{.Fee.Total.Decimal}}">
+			<button type="submit" name="confirm" value="yes">Confirm</button>
+		</form>
+		<div>
+			<p><a href="/">Start again</a></p>
+		</div>
+	</body>
+</html>
+`
+
+const editHTML = `
+<!doctype html>
+<html>
+	<head>
+		<meta charset="UTF-8">
+		<meta name="viewport" content="width=device-width, initial-scale=1.0">
+		<title>Money Transfer App - Change Transfer</title>
+	</head>
+	<body>
+		<h1>Change Scheduled Transfer</h1>
+		<p>Reference {{.Ref}} is due {{.Due}}.</p>
+		<form action="/edit" method="post">
+			<input type="hidden" name="csrf_token" value="{{.CSRF}}">
+			<input type="hidden" name="ref" value="{{.Ref}}">
+			<div style="margin-bottom: 0.5rem;">
+				<label for="when">Send on ({{.Timezone}}):</label>
+				<input type="datetime-local" name="when" value="{{.When}}">
+			</div>
+			<div style="margin-bottom: 0.5rem;">
+				<label for="amount">Amount ({{.Currency}}):</label>
+				<input type="text" name="amount" value="{{.Amount}}">
+			</div>
+
+			<button type="submit">Go</button>
+		</form>
+		<div>
+			<p><a href="/status">Check another reference</a></p>
+		</div>
 	</body>
 </html>
 `
@@ -262,6 +796,7 @@ const statusHTML = `
 	<body>
 		<h1>Check Status</h1>
 		<form action="/status" method="post">
+			<input type="hidden" name="csrf_token" value="{{.CSRF}}">
 			<div style="margin-bottom: 0.5rem;">
 				<label for="ref">Reference:</label>
 				<input type="text" name="ref" autofocus>
@@ -285,12 +820,86 @@ const runningWorkflowHTML = `
 	</head>
 	<body>
 		<h1>Money Transfer In Progress</h1>
+		<h3>{{.Stage}} since {{.Progress.StageSince.Format "15:04:05 Jan 2"}}</h3>
+		<ul>
+			<li>Reference: {{.Ref}}</li>
+			{{if .Due}}<li>Due: {{.Due}}{{if eq .Progress.Stage "scheduled"}} (<a href="/edit?ref={{.Ref}}">change</a>){{end}}</li>{{end}}
+			{{if not .Progress.StartTime.IsZero}}<li>Started: {{.Progress.StartTime.Format "15:04:05 Jan 2"}}</li>{{end}}
+			{{if .FXQuote}}<li>{{.FXQuote}}</li>{{end}}
+			{{if .Fee}}<li>{{.Fee}}</li>{{end}}
+			{{with .Progress.MoneyLaunderingDecision}}<li>AML decision: {{.Decision}} ({{.ReasonCode}}) at {{.DecidedAt.Format "15:04:05 Jan 2"}}</li>{{end}}
+			{{if not .Progress.WithdrawDoneTime.IsZero}}<li>Withdrawn: {{.Progress.WithdrawDoneTime.Format "15:04:05 Jan 2"}}</li>{{end}}
+			{{if not .Progress.DepositDoneTime.IsZero}}<li>Deposited: {{.Progress.DepositDoneTime.Format "15:04:05 Jan 2"}}</li>{{end}}
+			{{range $stage, $attempts := .Progress.Attempts}}<li>Attempts at {{$stage}}: {{$attempts}}</li>{{end}}
+			{{if .Progress.LastFailure}}<li>Last failure: {{.Progress.LastFailure}}</li>{{end}}
+			{{range .AMLEscalations}}<li>{{.}}</li>{{end}}
+			{{if .Progress.CancelRequested}}<li>Cancellation requested</li>{{end}}
+		</ul>
+		{{if .Notifications}}
+		<h3>Messages from us</h3>
+		<ul>
+			{{range .Notifications}}<li>{{.At.Format "15:04:05 Jan 2"}}: {{.Message}}</li>{{end}}
+		</ul>
+		{{end}}
+		{{if and .Progress.Cancellable (not .Progress.CancelRequested)}}
+		<form action="/cancel" method="post">
+			<input type="hidden" name="csrf_token" value="{{.CSRF}}">
+			<input type="hidden" name="ref" value="{{.Ref}}">
+			<button type="submit">Cancel transfer</button>
+		</form>
+		{{end}}
+		{{if .PendingActivities}}
 		<h3>Pending Activities</h3>
 		<ol>
 			{{range .PendingActivities}}
-			<li>{{.ActivityName}}. State: {{.State}}. Last Started: {{.LastStarted}}. Last failure: {{.LastFailure}}</li>
+			<li>{{.ActivityName}}. State: {{.State}}. Attempt: {{.Attempt}}. Last Started: {{.LastStarted}} ago. Last failure: {{.LastFailure}}</li>
 			{{end}}
 		</ol>
+		{{end}}
+		<div>
+			<p><a href="/status">Check another reference</a></p>
+		</div>
+	</body>
+</html>
+`
+
+const refundsHTML = `
+<html>
+	<head>
+		<meta charset="UTF-8">
+		<meta name="viewport" content="width=device-width, initial-scale=1.0">
+		<title>Money Transfer App - Failed Refunds</title>
+	</head>
+	<body>
+		<h1>Resolve Failed Refund</h1>
+		{{if .Message}}<p>{{.Message}}</p>{{end}}
+		<form action="/ops/refunds" method="post">
+			<input type="hidden" name="csrf_token" value="{{.CSRF}}">
+			<div style="margin-bottom: 0.5rem;">
+				<label for="ref">Reference:</label>
+				<input type="text" name="ref" autofocus>
+			</div>
+			<div style="margin-bottom: 0.5rem;">
+				<label for="action">Action:</label>
+				<select name="action">
+					{{range .Actions}}<option value="{{.}}">{{.}}</option>{{end}}
+				</select>
+			</div>
+			{{if .Operator}}
+			<p>Operator: {{.Operator}}</p>
+			{{else}}
+			<div style="margin-bottom: 0.5rem;">
+				<label for="operator">Operator:</label>
+				<input type="text" name="operator">
+			</div>
+			{{end}}
+			<div style="margin-bottom: 0.5rem;">
+				<label for="comment">Comment:</label>
+				<input type="text" name="comment">
+			</div>
+
+			<button type="submit">Go</button>
+		</form>
 	</body>
 </html>
 `
@@ -0,0 +1,543 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/arunsworld/temporal-demo/bankingdemo/auth"
+	"github.com/arunsworld/temporal-demo/bankingdemo/contracts"
+	"go.temporal.io/api/enums/v1"
+	"go.temporal.io/api/serviceerror"
+	"go.temporal.io/api/workflow/v1"
+	"go.temporal.io/api/workflowservice/v1"
+)
+
+const untilLayout = "2006-01-02"
+
+var frequencies = []string{contracts.FrequencyMonthly, contracts.FrequencyWeekly, contracts.FrequencyDaily}
+
+type standingOrderRow struct {
+	Ref       string
+	State     string
+	StartTime string
+}
+
+// standingOrdersHandler lists the customer's standing orders and sets up new ones. Like a transfer, a new order
+// is only started once the customer has confirmed the fee charged on each occurrence.
+func (s *service) standingOrdersHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method == "GET" {
+		resp, err := s.listStandingOrders(auth.FromContext(r.Context()), maxPageSize, nil)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			fmt.Fprintf(w, "ERROR: %v", err)
+			return
+		}
+		data := struct {
+			Orders      []standingOrderRow
+			Banks       []string
+			Currencies  []string
+			FormFields  []FormField
+			Frequencies []string
+			Timezone    string
+			CSRF        string
+		}{
+			Banks:       s.banks,
+			Currencies:  s.currencies,
+			FormFields:  formFields,
+			Frequencies: frequencies,
+			Timezone:    s.calendar.Location().String(),
+			CSRF:        auth.CSRFToken(w, r),
+		}
+		for _, info := range resp.Executions {
+			ref, ok := contracts.ReferenceFromStandingOrderID(info.Execution.WorkflowId)
+			if !ok {
+				continue
+			}
+			row := standingOrderRow{Ref: ref, State: stateName(info.Status)}
+			if info.StartTime != nil {
+				row.StartTime = info.StartTime.Format("15:04:05 Jan 2")
+			}
+			data.Orders = append(data.Orders, row)
+		}
+		if err := s.tmpl.ExecuteTemplate(w, "standing-orders", data); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			fmt.Fprintf(w, "ERROR: %v", err)
+			return
+		}
+		w.Header().Add("Content-Type", "text/html; charset=utf-8")
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		fmt.Fprintf(w, "ERROR: %v", err)
+		return
+	}
+	order, err := s.parseStandingOrderForm(r)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(w, "ERROR: %v", err)
+		return
+	}
+	if r.Form.Get("confirm") == "" {
+		hidden := formValues(r, "fbank", "dbank", "fcurrency", "dcurrency", "when", "frequency", "interval", "count", "until")
+		for _, f := range formFields {
+			hidden = append(hidden, formValues(r, f.Field)...)
+		}
+		req := order.Request
+		req.ExecuteAt = order.StartAt
+		s.confirmTransfer(w, r, req, "/standing-orders", hidden, s.describeRecurrence(order))
+		return
+	}
+	fee, problem := parseAmount(r.Form.Get("fee"), order.Request.Amount.Currency)
+	if problem != "" {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(w, "ERROR: fee %s", problem)
+		return
+	}
+	order.Request.AcceptedFee = &fee
+	if _, err := contracts.StartStandingOrder(s.ctx, s.workflowClient, order); err != nil {
+		if errors.Is(err, contracts.ErrDuplicateStandingOrder) {
+			w.WriteHeader(http.StatusConflict)
+		} else {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		fmt.Fprintf(w, "ERROR: %v", err)
+		return
+	}
+	http.Redirect(w, r, "/standing-order?ref="+url.QueryEscape(order.Ref), http.StatusSeeOther)
+}
+
+// parseStandingOrderForm reads the transfer form, whose "when" is the first occurrence, and how it repeats
+func (s *service) parseStandingOrderForm(r *http.Request) (contracts.StandingOrder, error) {
+	req, err := s.parseFormFields(r)
+	if err != nil {
+		return contracts.StandingOrder{}, err
+	}
+	order := contracts.StandingOrder{
+		Ref:        req.Ref,
+		Recurrence: contracts.Recurrence{Frequency: r.Form.Get("frequency")},
+		StartAt:    req.ExecuteAt,
+		Customer:   auth.FromContext(r.Context()).Subject,
+	}
+	req.ExecuteAt = time.Time{}
+	order.Request = req
+	problems := make(map[string]string)
+	if v := strings.TrimSpace(r.Form.Get("interval")); v != "" {
+		if order.Recurrence.Interval, err = strconv.Atoi(v); err != nil {
+			problems["interval"] = "must be a whole number"
+		}
+	}
+	if v := strings.TrimSpace(r.Form.Get("count")); v != "" {
+		if order.Count, err = strconv.Atoi(v); err != nil {
+			problems["count"] = "must be a whole number"
+		}
+	}
+	if v := strings.TrimSpace(r.Form.Get("until")); v != "" {
+		until, err := time.ParseInLocation(untilLayout, v, s.calendar.Location())
+		if err != nil {
+			problems["until"] = "must be a date"
+		}
+		order.EndAt = endOfDay(until)
+	}
+	for field, problem := range s.validateStandingOrder(order) {
+		problems[field] = problem
+	}
+	if len(problems) > 0 {
+		return contracts.StandingOrder{}, problemsError(problems)
+	}
+	return order, nil
+}
+
+// validateStandingOrder checks how an order repeats; its transfer is checked by validateRequest
+func (s *service) validateStandingOrder(order contracts.StandingOrder) map[string]string {
+	problems := make(map[string]string)
+	if strings.ContainsAny(order.Ref, "#/") {
+		problems["reference"] = "must not contain # or /"
+	}
+	if err := order.Recurrence.Validate(); err != nil {
+		problems["frequency"] = "must be one of " + strings.Join(frequencies, ", ")
+		if order.Recurrence.Interval < 0 {
+			problems["interval"] = "must not be negative"
+		}
+	}
+	if order.StartAt.IsZero() {
+		problems["startAt"] = "is required"
+	} else if !order.StartAt.After(time.Now()) {
+		problems["startAt"] = "must be in the future"
+	}
+	if order.Count < 0 {
+		problems["count"] = "must not be negative"
+	}
+	if !order.EndAt.IsZero() && order.EndAt.Before(order.StartAt) {
+		problems["endAt"] = "must not be before the first occurrence"
+	}
+	return problems
+}
+
+// endOfDay is the last moment of the day t falls on, in t's location
+func endOfDay(t time.Time) time.Time {
+	year, month, day := t.Date()
+	return time.Date(year, month, day+1, 0, 0, 0, 0, t.Location()).Add(-time.Nanosecond)
+}
+
+func (s *service) describeRecurrence(order contracts.StandingOrder) string {
+	unit := map[string]string{
+		contracts.FrequencyDaily:   "day",
+		contracts.FrequencyWeekly:  "week",
+		contracts.FrequencyMonthly: "month",
+	}[order.Recurrence.Frequency]
+	result := "every " + unit
+	if order.Recurrence.Interval > 1 {
+		result = fmt.Sprintf("every %d %ss", order.Recurrence.Interval, unit)
+	}
+	if order.Count > 0 {
+		result += fmt.Sprintf(", %d times", order.Count)
+	}
+	if !order.EndAt.IsZero() {
+		result += ", until " + order.EndAt.In(s.calendar.Location()).Format("Jan 2 2006")
+	}
+	return result
+}
+
+// standingOrderHandler shows a standing order and lets its customer pause, resume, amend or cancel it. An
+// amendment is confirmed like a new order, with the fee of its amount.
+func (s *service) standingOrderHandler(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		fmt.Fprintf(w, "ERROR: %v", err)
+		return
+	}
+	ref := r.Form.Get("ref")
+	if ref == "" {
+		http.Redirect(w, r, "/standing-orders", http.StatusSeeOther)
+		return
+	}
+	identity := auth.FromContext(r.Context())
+	state, info, err := s.describeStandingOrder(ref, identity)
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprintf(w, "Unable to get standing order (%v). Go back and try again.", err)
+		return
+	}
+	running := info.Status == enums.WORKFLOW_EXECUTION_STATUS_RUNNING
+
+	if r.Method == "GET" {
+		data := struct {
+			Ref           string
+			State         contracts.StandingOrderState
+			Running       bool
+			Repeats       string
+			StartAt       string
+			NextDue       string
+			Until         string
+			Notifications []notification
+			CSRF          string
+		}{
+			Ref:           ref,
+			State:         state,
+			Running:       running,
+			Repeats:       s.describeRecurrence(state.Order),
+			StartAt:       formatDue(state.Order.StartAt.In(s.calendar.Location())),
+			Notifications: s.notifications.forRef(contracts.StandingOrderID(ref)),
+			CSRF:          auth.CSRFToken(w, r),
+		}
+		if !state.NextDueAt.IsZero() {
+			data.NextDue = formatDue(state.NextDueAt.In(s.calendar.Location()))
+		}
+		if !state.Order.EndAt.IsZero() {
+			data.Until = state.Order.EndAt.In(s.calendar.Location()).Format(untilLayout)
+		}
+		if err := s.tmpl.ExecuteTemplate(w, "standing-order", data); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			fmt.Fprintf(w, "ERROR: %v", err)
+			return
+		}
+		w.Header().Add("Content-Type", "text/html; charset=utf-8")
+		return
+	}
+
+	if !running {
+		w.WriteHeader(http.StatusConflict)
+		fmt.Fprint(w, "The standing order has ended and can no longer be changed.")
+		return
+	}
+	switch op := r.Form.Get("op"); op {
+	case "pause":
+		err = contracts.PauseStandingOrder(s.ctx, s.workflowClient, ref, identity.Subject)
+	case "resume":
+		err = contracts.ResumeStandingOrder(s.ctx, s.workflowClient, ref, identity.Subject)
+	case "cancel":
+		err = contracts.CancelStandingOrder(s.ctx, s.workflowClient, ref)
+	case "amend":
+		var endAt *time.Time
+		untilProblem := ""
+		if v := strings.TrimSpace(r.Form.Get("until")); v != "" {
+			until, err := time.ParseInLocation(untilLayout, v, s.calendar.Location())
+			if err != nil {
+				untilProblem = "must be a date"
+			}
+			until = endOfDay(until)
+			endAt = &until
+		}
+		amendment, problems := s.newAmendment(state, strings.TrimSpace(r.Form.Get("amount")), "", endAt)
+		if untilProblem != "" {
+			problems["until"] = untilProblem
+		}
+		if len(problems) > 0 {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprintf(w, "ERROR: %v", problemsError(problems))
+			return
+		}
+		if r.Form.Get("confirm") == "" {
+			order := state.Order
+			order.Request.Amount = amendment.Amount
+			if !amendment.EndAt.IsZero() {
+				order.EndAt = amendment.EndAt
+			}
+			s.confirmTransfer(w, r, order.Request, "/standing-order", formValues(r, "ref", "op", "amount", "until"),
+				s.describeRecurrence(order))
+			return
+		}
+		fee, problem := parseAmount(r.Form.Get("fee"), amendment.Amount.Currency)
+		if problem != "" {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprintf(w, "ERROR: fee %s", problem)
+			return
+		}
+		amendment.AcceptedFee = &fee
+		amendment.Editor = identity.Subject
+		err = contracts.AmendStandingOrder(s.ctx, s.workflowClient, ref, amendment)
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(w, "ERROR: unknown operation %q", op)
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(w, "Unable to update standing order (%v). Go back and try again.", err)
+		return
+	}
+	http.Redirect(w, r, "/standing-order?ref="+url.QueryEscape(ref), http.StatusSeeOther)
+}
+
+// newAmendment reads a new amount, and optionally the fee accepted for it, in the currency of the order
+func (s *service) newAmendment(state contracts.StandingOrderState, amount, acceptedFee string, endAt *time.Time) (contracts.StandingOrderAmendment, map[string]string) {
+	currency := state.Order.Request.Amount.Currency
+	problems := make(map[string]string)
+	result := contracts.StandingOrderAmendment{}
+	if amount != "" {
+		money, problem := parseAmount(amount, currency)
+		if problem == "" && money.Minor <= 0 {
+			problem = "must be a positive amount"
+		}
+		if problem != "" {
+			problems["amount"] = problem
+		}
+		result.Amount = money
+	}
+	if acceptedFee != "" {
+		fee, problem := parseAmount(acceptedFee, currency)
+		if problem != "" {
+			problems["acceptedFee"] = problem
+		}
+		result.AcceptedFee = &fee
+	} else if current := state.Order.Request; current.AcceptedFee != nil && result.Amount.Currency != "" &&
+		problems["amount"] == "" && result.Amount != current.Amount {
+		problems["acceptedFee"] = "required when the amount changes"
+	}
+	if endAt != nil {
+		if endAt.Before(state.Order.StartAt) {
+			problems["endAt"] = "must not be before the first occurrence"
+		}
+		result.EndAt = *endAt
+	}
+	if result.Amount.Currency == "" && result.EndAt.IsZero() {
+		problems["amount"] = "or an end is required"
+	}
+	return result, problems
+}
+
+// describeStandingOrder answers the state of a standing order, reporting one identity may not access as not
+// found
+func (s *service) describeStandingOrder(ref string, identity auth.Identity) (contracts.StandingOrderState, *workflow.WorkflowExecutionInfo, error) {
+	resp, err := s.workflowClient.DescribeWorkflowExecution(s.ctx, contracts.StandingOrderID(ref), "")
+	if err != nil {
+		return contracts.StandingOrderState{}, nil, err
+	}
+	info := resp.WorkflowExecutionInfo
+	if !mayAccess(identity, info) {
+		return contracts.StandingOrderState{}, nil, serviceerror.NewNotFound("standing order not found")
+	}
+	var state contracts.StandingOrderState
+	switch info.Status {
+	case enums.WORKFLOW_EXECUTION_STATUS_RUNNING:
+		state, err = contracts.QueryStandingOrder(s.ctx, s.workflowClient, ref)
+	case enums.WORKFLOW_EXECUTION_STATUS_COMPLETED:
+		state, err = contracts.GetStandingOrderResult(s.ctx, s.workflowClient, ref, info.Execution.RunId)
+	default:
+		err = fmt.Errorf("standing order is %s", stateName(info.Status))
+	}
+	return state, info, err
+}
+
+// listStandingOrders answers the standing orders identity may see, most recent first
+func (s *service) listStandingOrders(identity auth.Identity, pageSize int, pageToken []byte) (*workflowservice.ListWorkflowExecutionsResponse, error) {
+	query := contracts.StandingOrderListQuery
+	if identity.Subject != "" && !identity.HasRole(auth.RoleOps) {
+		var err error
+		if query, err = contracts.CustomerStandingOrdersQuery(identity.Subject); err != nil {
+			return nil, err
+		}
+	}
+	return s.workflowClient.ListWorkflow(s.ctx, &workflowservice.ListWorkflowExecutionsRequest{
+		PageSize:      int32(pageSize),
+		NextPageToken: pageToken,
+		Query:         query,
+	})
+}
+
+const standingOrdersHTML = `
+<!doctype html>
+<html>
+	<head>
+		<meta charset="UTF-8">
+		<meta name="viewport" content="width=device-width, initial-scale=1.0">
+		<title>Money Transfer App - Standing Orders</title>
+	</head>
+	<body>
+		<h1>Standing Orders</h1>
+		{{if .Orders}}
+		<ul>
+			{{range .Orders}}<li><a href="/standing-order?ref={{.Ref}}">{{.Ref}}</a>: {{.State}}, set up {{.StartTime}}</li>
+			{{end}}
+		</ul>
+		{{else}}
+		<p>You have no standing orders.</p>
+		{{end}}
+		<h3>New standing order</h3>
+		<form action="/standing-orders" method="post">
+			<input type="hidden" name="csrf_token" value="{{.CSRF}}">
+			<div style="margin-bottom: 0.5rem;">
+				<label for="fbank">Source bank:</label>
+				<select name="fbank">
+					{{range .Banks}}<option value="{{.}}">{{.}}</option>{{end}}
+				</select>
+			</div>
+			<div style="margin-bottom: 0.5rem;">
+				<label for="dbank">Destination bank:</label>
+				<select name="dbank">
+					{{range $i, $v := .Banks}}<option value="{{.}}" {{if eq $i 1}}selected{{end}}>{{.}}</option>{{end}}
+				</select>
+			</div>
+			<div style="margin-bottom: 0.5rem;">
+				<label for="fcurrency">Pay in:</label>
+				<select name="fcurrency">
+					{{range .Currencies}}<option value="{{.}}">{{.}}</option>{{end}}
+				</select>
+				<label for="dcurrency">Recipient receives:</label>
+				<select name="dcurrency">
+					{{range .Currencies}}<option value="{{.}}">{{.}}</option>{{end}}
+				</select>
+			</div>
+			{{range $i, $v := .FormFields}}
+			<div style="margin-bottom: 0.5rem;">
+				<label for="{{.Field}}">{{.Label}}:</label>
+				<input type="text" name="{{.Field}}">
+			</div>
+			{{end}}
+			<div style="margin-bottom: 0.5rem;">
+				<label for="when">First payment ({{.Timezone}}):</label>
+				<input type="datetime-local" name="when">
+			</div>
+			<div style="margin-bottom: 0.5rem;">
+				<label for="frequency">Repeat every</label>
+				<input type="number" name="interval" min="1" value="1" style="width: 3rem;">
+				<select name="frequency">
+					{{range .Frequencies}}<option value="{{.}}">{{.}}</option>{{end}}
+				</select>
+			</div>
+			<div style="margin-bottom: 0.5rem;">
+				<label for="count">Number of payments (optional):</label>
+				<input type="number" name="count" min="1">
+				<label for="until">or until (optional):</label>
+				<input type="date" name="until">
+			</div>
+
+			<button type="submit">Go</button>
+		</form>
+		<div>
+			<p><a href="/">Make a single transfer</a></p>
+		</div>
+	</body>
+</html>
+`
+
+const standingOrderHTML = `
+<!doctype html>
+<html>
+	<head>
+		<meta charset="UTF-8">
+		<meta name="viewport" content="width=device-width, initial-scale=1.0">
+		<title>Money Transfer App - Standing Order</title>
+	</head>
+	<body>
+		<h1>Standing Order {{.Ref}}</h1>
+		<h3>{{.State.Status}}{{if .State.PausedReason}}: {{.State.PausedReason}}{{end}}</h3>
+		<ul>
+			{{with .State.Order.Request}}<li>{{.Amount}} from {{.SourceAcc}} at {{.SourceBank}} to {{.DestinationAcc}} at {{.DestinationBank}}{{if ne .DestinationCurrency .Amount.Currency}}, received in {{.DestinationCurrency}}{{end}}</li>{{end}}
+			<li>Repeats {{.Repeats}}, from {{.StartAt}}</li>
+			{{if .NextDue}}<li>Next payment: {{.NextDue}}</li>{{end}}
+			{{if .State.Current}}<li>Transfer under way: {{.State.Current}}</li>{{end}}
+			<li>Transfers made: {{.State.Transfers}}, of which did not go through: {{.State.Failures}}</li>
+			{{if .State.Amendments}}<li>Amendments: {{.State.Amendments}}</li>{{end}}
+		</ul>
+		{{if .State.History}}
+		<h3>Recent payments</h3>
+		<ol>
+			{{range .State.History}}<li>{{.DueAt.Format "Jan 2 2006 15:04"}}: {{.Amount}}, {{.Status}}{{if .Ref}} ({{.Ref}}){{end}}{{if .FailureReason}}: {{.FailureReason}}{{end}}</li>
+			{{end}}
+		</ol>
+		{{end}}
+		{{if .Notifications}}
+		<h3>Messages from us</h3>
+		<ul>
+			{{range .Notifications}}<li>{{.At.Format "15:04:05 Jan 2"}}: {{.Message}}</li>{{end}}
+		</ul>
+		{{end}}
+		{{if .Running}}
+		<form action="/standing-order" method="post">
+			<input type="hidden" name="csrf_token" value="{{.CSRF}}">
+			<input type="hidden" name="ref" value="{{.Ref}}">
+			{{if eq .State.Status "paused"}}<button type="submit" name="op" value="resume">Resume</button>
+			{{else}}<button type="submit" name="op" value="pause">Pause</button>{{end}}
+			<button type="submit" name="op" value="cancel">Cancel standing order</button>
+		</form>
+		<h3>Amend</h3>
+		<form action="/standing-order" method="post">
+			<input type="hidden" name="csrf_token" value="{{.CSRF}}">
+			<input type="hidden" name="ref" value="{{.Ref}}">
+			<input type="hidden" name="op" value="amend">
+			<div style="margin-bottom: 0.5rem;">
+				<label for="amount">Amount ({{.State.Order.Request.Amount.Currency}}):</label>
+				<input type="text" name="amount" value="{{.State.Order.Request.Amount.Decimal}}">
+			</div>
+			<div style="margin-bottom: 0.5rem;">
+				<label for="until">Until (optional):</label>
+				<input type="date" name="until" value="{{.Until}}">
+			</div>
+
+			<button type="submit">Go</button>
+		</form>
+		{{end}}
+		<div>
+			<p><a href="/standing-orders">All standing orders</a></p>
+		</div>
+	</body>
+</html>
+`
@@ -0,0 +1,304 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/arunsworld/temporal-demo/bankingdemo/auth"
+	"github.com/arunsworld/temporal-demo/bankingdemo/contracts"
+	"go.temporal.io/api/enums/v1"
+	"go.temporal.io/api/serviceerror"
+	"go.temporal.io/api/workflow/v1"
+)
+
+type standingOrderRequest struct {
+	// Transfer is the transfer to repeat; its reference names the standing order and it has no executeAt
+	Transfer   transferRequest `json:"transfer"`
+	Recurrence recurrence      `json:"recurrence"`
+	StartAt    *time.Time      `json:"startAt"`
+	EndAt      *time.Time      `json:"endAt,omitempty"`
+	Count      int             `json:"count,omitempty"`
+}
+
+type recurrence struct {
+	Frequency string `json:"frequency"`
+	Interval  int    `json:"interval,omitempty"`
+}
+
+// standingOrderAmendment is the body of PATCH /api/v1/standing-orders/{reference}
+type standingOrderAmendment struct {
+	Amount      json.Number `json:"amount,omitempty"`
+	AcceptedFee json.Number `json:"acceptedFee,omitempty"`
+	EndAt       *time.Time  `json:"endAt,omitempty"`
+}
+
+type standingOrderResource struct {
+	Reference string               `json:"reference"`
+	RunID     string               `json:"runId"`
+	State     string               `json:"state"` // the workflow execution status
+	StartTime *time.Time           `json:"startTime,omitempty"`
+	CloseTime *time.Time           `json:"closeTime,omitempty"`
+	Order     *standingOrderDetail `json:"order,omitempty"`
+}
+
+type standingOrderDetail struct {
+	Status              string           `json:"status"`
+	PausedReason        string           `json:"pausedReason,omitempty"`
+	SourceBank          string           `json:"sourceBank"`
+	SourceAccount       string           `json:"sourceAccount"`
+	DestinationBank     string           `json:"destinationBank"`
+	DestinationAccount  string           `json:"destinationAccount"`
+	Amount              contracts.Money  `json:"amount"`
+	DestinationCurrency string           `json:"destinationCurrency"`
+	AcceptedFee         *contracts.Money `json:"acceptedFee,omitempty"`
+	Recurrence          recurrence       `json:"recurrence"`
+	StartAt             time.Time        `json:"startAt"`
+	EndAt               *time.Time       `json:"endAt,omitempty"`
+	Count               int              `json:"count,omitempty"`
+	NextDueAt           *time.Time       `json:"nextDueAt,omitempty"`
+	CurrentTransfer     string           `json:"currentTransfer,omitempty"`
+	Transfers           int              `json:"transfers"`
+	Failures            int              `json:"failures"`
+	ConsecutiveFailures int              `json:"consecutiveFailures"`
+	Amendments          int              `json:"amendments"`
+	History             []occurrence     `json:"history"`
+}
+
+type occurrence struct {
+	Number        int             `json:"number"` // counting from 1
+	Reference     string          `json:"reference,omitempty"`
+	DueAt         time.Time       `json:"dueAt"`
+	Amount        contracts.Money `json:"amount"`
+	Status        string          `json:"status"`
+	FailureReason string          `json:"failureReason,omitempty"`
+}
+
+type standingOrderList struct {
+	StandingOrders []standingOrderResource `json:"standingOrders"`
+	NextPageToken  string                  `json:"nextPageToken,omitempty"`
+}
+
+// standingOrdersAPIHandler serves POST and GET /api/v1/standing-orders
+func (s *service) standingOrdersAPIHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPost:
+		s.createStandingOrder(w, r)
+	case http.MethodGet:
+		pageSize, ok := readPageSize(w, r)
+		if !ok {
+			return
+		}
+		resp, err := s.listStandingOrders(auth.FromContext(r.Context()), pageSize, []byte(r.URL.Query().Get("pageToken")))
+		if err != nil {
+			writeAPIError(w, http.StatusInternalServerError, "internal", err.Error(), nil)
+			return
+		}
+		result := standingOrderList{
+			StandingOrders: []standingOrderResource{},
+			NextPageToken:  string(resp.NextPageToken),
+		}
+		for _, info := range resp.Executions {
+			ref, ok := contracts.ReferenceFromStandingOrderID(info.Execution.WorkflowId)
+			if !ok {
+				continue
+			}
+			result.StandingOrders = append(result.StandingOrders, newStandingOrderResource(ref, info))
+		}
+		writeJSON(w, http.StatusOK, result)
+	default:
+		writeAPIError(w, http.StatusMethodNotAllowed, "method_not_allowed", "use GET or POST", nil)
+	}
+}
+
+func (s *service) createStandingOrder(w http.ResponseWriter, r *http.Request) {
+	if !hasJSONBody(w, r) {
+		return
+	}
+	body := standingOrderRequest{}
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&body); err != nil {
+		writeAPIError(w, http.StatusBadRequest, "invalid_json", err.Error(), nil)
+		return
+	}
+	executeAt := body.Transfer.ExecuteAt
+	body.Transfer.ExecuteAt = nil
+	req, transferProblems := s.newRequest(body.Transfer)
+	order := contracts.StandingOrder{
+		Ref:        req.Ref,
+		Request:    req,
+		Recurrence: contracts.Recurrence{Frequency: body.Recurrence.Frequency, Interval: body.Recurrence.Interval},
+		Count:      body.Count,
+		Customer:   auth.FromContext(r.Context()).Subject,
+	}
+	if body.StartAt != nil {
+		order.StartAt = *body.StartAt
+	}
+	if body.EndAt != nil {
+		order.EndAt = *body.EndAt
+	}
+	problems := make(map[string]string)
+	for field, problem := range transferProblems {
+		problems["transfer."+field] = problem
+	}
+	if executeAt != nil {
+		problems["transfer.executeAt"] = "use startAt"
+	}
+	for field, problem := range s.validateStandingOrder(order) {
+		switch field {
+		case "reference":
+			field = "transfer.reference"
+		case "frequency", "interval":
+			field = "recurrence." + field
+		}
+		problems[field] = problem
+	}
+	if len(problems) > 0 {
+		writeAPIError(w, http.StatusUnprocessableEntity, "validation_failed", "the standing order is invalid", problems)
+		return
+	}
+	run, err := contracts.StartStandingOrder(s.ctx, s.workflowClient, order)
+	if err != nil {
+		if errors.Is(err, contracts.ErrDuplicateStandingOrder) {
+			writeAPIError(w, http.StatusConflict, "duplicate_reference", err.Error(), nil)
+			return
+		}
+		writeAPIError(w, http.StatusInternalServerError, "internal", err.Error(), nil)
+		return
+	}
+	w.Header().Set("Location", apiPrefix+"/standing-orders/"+order.Ref)
+	writeJSON(w, http.StatusCreated, standingOrderResource{
+		Reference: order.Ref,
+		RunID:     run.GetRunID(),
+		State:     stateName(enums.WORKFLOW_EXECUTION_STATUS_RUNNING),
+	})
+}
+
+// standingOrderAPIHandler serves /api/v1/standing-orders/{reference} and its pause and resume actions
+func (s *service) standingOrderAPIHandler(w http.ResponseWriter, r *http.Request) {
+	ref, action, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, apiPrefix+"/standing-orders/"), "/")
+	if ref == "" || (action != "" && action != "pause" && action != "resume") {
+		writeAPIError(w, http.StatusNotFound, "not_found", "no such resource", nil)
+		return
+	}
+	allowed := "GET, PATCH or DELETE"
+	if action != "" {
+		allowed = "POST"
+	}
+	switch {
+	case action == "" && r.Method == http.MethodGet,
+		action == "" && r.Method == http.MethodPatch,
+		action == "" && r.Method == http.MethodDelete,
+		action != "" && r.Method == http.MethodPost:
+	default:
+		writeAPIError(w, http.StatusMethodNotAllowed, "method_not_allowed", "use "+allowed, nil)
+		return
+	}
+
+	identity := auth.FromContext(r.Context())
+	state, info, err := s.describeStandingOrder(ref, identity)
+	var notFound *serviceerror.NotFound
+	if errors.As(err, &notFound) {
+		writeAPIError(w, http.StatusNotFound, "not_found", "no standing order with reference "+ref, nil)
+		return
+	}
+	if err != nil && info == nil {
+		writeAPIError(w, http.StatusInternalServerError, "internal", err.Error(), nil)
+		return
+	}
+	resource := newStandingOrderResource(ref, info)
+	if err == nil {
+		resource.Order = newStandingOrderDetail(state)
+	}
+	if r.Method == http.MethodGet {
+		writeJSON(w, http.StatusOK, resource)
+		return
+	}
+	if info.Status != enums.WORKFLOW_EXECUTION_STATUS_RUNNING {
+		writeAPIError(w, http.StatusConflict, "ended", "the standing order has ended", nil)
+		return
+	}
+
+	switch {
+	case action == "pause":
+		err = contracts.PauseStandingOrder(s.ctx, s.workflowClient, ref, identity.Subject)
+	case action == "resume":
+		err = contracts.ResumeStandingOrder(s.ctx, s.workflowClient, ref, identity.Subject)
+	case r.Method == http.MethodDelete:
+		err = contracts.CancelStandingOrder(s.ctx, s.workflowClient, ref)
+	default:
+		if !hasJSONBody(w, r) {
+			return
+		}
+		body := standingOrderAmendment{}
+		decoder := json.NewDecoder(r.Body)
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&body); err != nil {
+			writeAPIError(w, http.StatusBadRequest, "invalid_json", err.Error(), nil)
+			return
+		}
+		amendment, problems := s.newAmendment(state, string(body.Amount), string(body.AcceptedFee), body.EndAt)
+		if len(problems) > 0 {
+			writeAPIError(w, http.StatusUnprocessableEntity, "validation_failed", "the amendment is invalid", problems)
+			return
+		}
+		amendment.Editor = identity.Subject
+		err = contracts.AmendStandingOrder(s.ctx, s.workflowClient, ref, amendment)
+	}
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, "internal", err.Error(), nil)
+		return
+	}
+	// the change is applied by the standing order, so the order is shown as it was
+	writeJSON(w, http.StatusAccepted, resource)
+}
+
+func newStandingOrderResource(ref string, info *workflow.WorkflowExecutionInfo) standingOrderResource {
+	return standingOrderResource{
+		Reference: ref,
+		RunID:     info.Execution.RunId,
+		State:     stateName(info.Status),
+		StartTime: info.StartTime,
+		CloseTime: info.CloseTime,
+	}
+}
+
+func newStandingOrderDetail(state contracts.StandingOrderState) *standingOrderDetail {
+	req := state.Order.Request
+	result := &standingOrderDetail{
+		Status:              state.Status,
+		PausedReason:        state.PausedReason,
+		SourceBank:          req.SourceBank,
+		SourceAccount:       req.SourceAcc,
+		DestinationBank:     req.DestinationBank,
+		DestinationAccount:  req.DestinationAcc,
+		Amount:              req.Amount,
+		DestinationCurrency: req.DestinationCurrency,
+		AcceptedFee:         req.AcceptedFee,
+		Recurrence:          recurrence{Frequency: state.Order.Recurrence.Frequency, Interval: state.Order.Recurrence.Interval},
+		StartAt:             state.Order.StartAt,
+		EndAt:               optionalTime(state.Order.EndAt),
+		Count:               state.Order.Count,
+		NextDueAt:           optionalTime(state.NextDueAt),
+		CurrentTransfer:     state.Current,
+		Transfers:           state.Transfers,
+		Failures:            state.Failures,
+		ConsecutiveFailures: state.ConsecutiveFailures,
+		Amendments:          state.Amendments,
+		History:             []occurrence{},
+	}
+	for _, o := range state.History {
+		result.History = append(result.History, occurrence{
+			Number:        o.N + 1,
+			Reference:     o.Ref,
+			DueAt:         o.DueAt,
+			Amount:        o.Amount,
+			Status:        o.Status,
+			FailureReason: o.FailureReason,
+		})
+	}
+	return result
+}
@@ -0,0 +1,46 @@
+package faults
+
+import (
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+)
+
+// Handler exposes the profile for runtime changes:
+//   - GET returns the current profile
+//   - PUT or POST replaces it with the JSON body, sent as application/json; unknown fields are rejected
+//   - DELETE clears every fault
+func (i *Injector) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+		case http.MethodPut, http.MethodPost:
+			if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType != "application/json" {
+				w.WriteHeader(http.StatusUnsupportedMediaType)
+				fmt.Fprint(w, "ERROR: send application/json")
+				return
+			}
+			p := Profile{}
+			decoder := json.NewDecoder(r.Body)
+			decoder.DisallowUnknownFields()
+			if err := decoder.Decode(&p); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				fmt.Fprintf(w, "ERROR: %v", err)
+				return
+			}
+			if err := i.SetProfile(p); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				fmt.Fprintf(w, "ERROR: %v", err)
+				return
+			}
+		case http.MethodDelete:
+			_ = i.SetProfile(Profile{})
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(i.Profile())
+	})
+}
@@ -0,0 +1,62 @@
+package faults
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+)
+
+/*
+LoadProfile relies on the following, each optionally prefixed:
+  - FAULTS_FILE: JSON encoded Profile
+  - FAULT_TRANSIENT_PROBABILITY
+  - FAULT_BUSINESS_FAILURE_PROBABILITY
+  - FAULT_BUSINESS_FAILURE_REASON
+  - FAULT_LATENCY_PROBABILITY
+  - FAULT_LATENCY
+  - FAULT_HANG_PROBABILITY
+
+The file is read first; the variables then override its default faults.
+*/
+func LoadProfile(prefix string) (Profile, error) {
+	result := Profile{}
+	if file := os.Getenv(prefix + "FAULTS_FILE"); file != "" {
+		contents, err := os.ReadFile(file)
+		if err != nil {
+			return Profile{}, err
+		}
+		if err := json.Unmarshal(contents, &result); err != nil {
+			return Profile{}, fmt.Errorf("bad %sFAULTS_FILE %s: %w", prefix, file, err)
+		}
+	}
+	probabilities := map[string]*float64{
+		"FAULT_TRANSIENT_PROBABILITY":        &result.Default.TransientErrorProbability,
+		"FAULT_BUSINESS_FAILURE_PROBABILITY": &result.Default.BusinessFailureProbability,
+		"FAULT_LATENCY_PROBABILITY":          &result.Default.LatencyProbability,
+		"FAULT_HANG_PROBABILITY":             &result.Default.HangProbability,
+	}
+	for name, target := range probabilities {
+		v := os.Getenv(prefix + name)
+		if v == "" {
+			continue
+		}
+		p, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return Profile{}, fmt.Errorf("bad %s%s: %w", prefix, name, err)
+		}
+		*target = p
+	}
+	if v := os.Getenv(prefix + "FAULT_BUSINESS_FAILURE_REASON"); v != "" {
+		result.Default.BusinessFailureReason = v
+	}
+	if v := os.Getenv(prefix + "FAULT_LATENCY"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return Profile{}, fmt.Errorf("bad %sFAULT_LATENCY: %w", prefix, err)
+		}
+		result.Default.Latency = Duration(d)
+	}
+	return result, result.Validate()
+}
@@ -0,0 +1,217 @@
+// Package faults injects configurable failures into activities so that every failure path of a workflow can be
+// exercised on demand.
+package faults
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+)
+
+var ErrTransient = errors.New("injected transient error")
+
+const defaultFailureReason = "Injected Failure"
+
+// Faults are the probabilities (0 to 1) of each kind of fault for a single activity call.
+type Faults struct {
+	TransientErrorProbability  float64  `json:"transientErrorProbability,omitempty"`
+	BusinessFailureProbability float64  `json:"businessFailureProbability,omitempty"`
+	BusinessFailureReason      string   `json:"businessFailureReason,omitempty"`
+	LatencyProbability         float64  `json:"latencyProbability,omitempty"`
+	Latency                    Duration `json:"latency,omitempty"`
+	HangProbability            float64  `json:"hangProbability,omitempty"`
+}
+
+// Rule applies Faults to calls matching all of its non-empty fields. Reference matches as a substring.
+type Rule struct {
+	Activity  string `json:"activity,omitempty"`
+	AccountID string `json:"accountId,omitempty"`
+	Reference string `json:"reference,omitempty"`
+	Faults
+}
+
+func (r Rule) matches(activity, accountID, reference string) bool {
+	if r.Activity != "" && r.Activity != activity {
+		return false
+	}
+	if r.AccountID != "" && r.AccountID != accountID {
+		return false
+	}
+	if r.Reference != "" && !strings.Contains(reference, r.Reference) {
+		return false
+	}
+	return true
+}
+
+// Profile is the complete fault configuration. The first matching rule wins, then the activity specific
+// faults, then the default.
+type Profile struct {
+	Default    Faults            `json:"default"`
+	Activities map[string]Faults `json:"activities,omitempty"`
+	Rules      []Rule            `json:"rules,omitempty"`
+}
+
+func (p Profile) Validate() error {
+	if err := p.Default.validate(); err != nil {
+		return fmt.Errorf("default: %w", err)
+	}
+	for name, f := range p.Activities {
+		if err := f.validate(); err != nil {
+			return fmt.Errorf("activity %s: %w", name, err)
+		}
+	}
+	for i, r := range p.Rules {
+		if err := r.Faults.validate(); err != nil {
+			return fmt.Errorf("rule %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+func (f Faults) validate() error {
+	for name, p := range map[string]float64{
+		"transientErrorProbability":  f.TransientErrorProbability,
+		"businessFailureProbability": f.BusinessFailureProbability,
+		"latencyProbability":         f.LatencyProbability,
+		"hangProbability":            f.HangProbability,
+	} {
+		if p < 0 || p > 1 {
+			return fmt.Errorf("%s must be between 0 and 1, got %v", name, p)
+		}
+	}
+	if f.Latency < 0 {
+		return fmt.Errorf("latency must not be negative")
+	}
+	return nil
+}
+
+func (p Profile) faultsFor(activity, accountID, reference string) Faults {
+	for _, r := range p.Rules {
+		if r.matches(activity, accountID, reference) {
+			return r.Faults
+		}
+	}
+	if f, ok := p.Activities[activity]; ok {
+		return f
+	}
+	return p.Default
+}
+
+// Decision is the outcome of rolling the dice for one activity call.
+type Decision struct {
+	Delay         time.Duration
+	Hang          bool
+	Transient     bool
+	FailureReason string // non-empty when a business failure should be reported
+}
+
+// Wait applies the delay and hang of the decision and returns ErrTransient if a transient error was chosen.
+// A hang only ends when ctx does, which is how the activity timeouts get exercised.
+func (d Decision) Wait(ctx context.Context) error {
+	if d.Delay > 0 {
+		select {
+		case <-time.After(d.Delay):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	if d.Hang {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	if d.Transient {
+		return ErrTransient
+	}
+	return nil
+}
+
+type Injector struct {
+	mu      sync.RWMutex
+	profile Profile
+
+	randMu sync.Mutex
+	rand   *rand.Rand
+}
+
+func NewInjector(p Profile) (*Injector, error) {
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
+	return &Injector{
+		profile: p,
+		rand:    rand.New(rand.NewSource(time.Now().UnixNano())),
+	}, nil
+}
+
+func (i *Injector) Profile() Profile {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	return i.profile
+}
+
+func (i *Injector) SetProfile(p Profile) error {
+	if err := p.Validate(); err != nil {
+		return err
+	}
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.profile = p
+	return nil
+}
+
+func (i *Injector) Decide(activity, accountID, reference string) Decision {
+	f := i.Profile().faultsFor(activity, accountID, reference)
+	result := Decision{}
+	if i.roll(f.LatencyProbability) {
+		result.Delay = time.Duration(f.Latency)
+	}
+	if i.roll(f.HangProbability) {
+		result.Hang = true
+		return result
+	}
+	if i.roll(f.TransientErrorProbability) {
+		result.Transient = true
+		return result
+	}
+	if i.roll(f.BusinessFailureProbability) {
+		result.FailureReason = f.BusinessFailureReason
+		if result.FailureReason == "" {
+			result.FailureReason = defaultFailureReason
+		}
+	}
+	return result
+}
+
+func (i *Injector) roll(probability float64) bool {
+	if probability <= 0 {
+		return false
+	}
+	i.randMu.Lock()
+	defer i.randMu.Unlock()
+	return i.rand.Float64() < probability
+}
+
+// Duration is a time.Duration that reads and writes as a string such as "1.5s".
+type Duration time.Duration
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return fmt.Errorf("duration must be a string like \"2s\": %w", err)
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
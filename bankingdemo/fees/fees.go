@@ -0,0 +1,260 @@
+// Package fees prices transfers from a fee schedule. The clearing house charges what the schedule says; the
+// customer service quotes from the same schedule before a transfer is submitted.
+package fees
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"strings"
+
+	"github.com/arunsworld/temporal-demo/bankingdemo/contracts"
+)
+
+var ErrNoRevenueAccount = errors.New("no revenue account for the currency")
+
+// Fee item names
+const (
+	ItemFlat       = "flat"
+	ItemPercentage = "percentage"
+	ItemTiered     = "tiered"
+	ItemMinimum    = "minimum fee"
+	ItemMaximum    = "fee cap"
+)
+
+// Tier charges Percent of the part of the amount up to UpTo that earlier tiers have not covered. The last tier
+// has no UpTo and covers the rest.
+type Tier struct {
+	UpTo    string  `json:"upTo,omitempty"`
+	Percent float64 `json:"percent"`
+}
+
+// Rule prices the transfers matching all of its non-empty bank and currency fields. Amounts are decimal strings
+// in major units of the transfer's currency, such as "2.50", with no more decimal places than it has, so a rule
+// without a currency only takes whole amounts. Components left out are not charged. Min and Max cap the sum of
+// the components.
+type Rule struct {
+	SourceBank      string  `json:"sourceBank,omitempty"`
+	DestinationBank string  `json:"destinationBank,omitempty"`
+	Currency        string  `json:"currency,omitempty"`
+	Flat            string  `json:"flat,omitempty"`
+	Percent         float64 `json:"percent,omitempty"`
+	Tiers           []Tier  `json:"tiers,omitempty"`
+	Min             string  `json:"min,omitempty"`
+	Max             string  `json:"max,omitempty"`
+}
+
+// narrowestCurrency has the fewest decimal places of any currency; a rule without a currency may apply to it, so
+// its amounts are checked against it when the schedule is loaded.
+const narrowestCurrency = "JPY"
+
+// minor reads amount, a rule's decimal string, in minor units of currency. Left out is zero.
+func minor(amount, currency string) (int64, error) {
+	if amount == "" {
+		return 0, nil
+	}
+	m, err := contracts.ParseMoney(amount, currency)
+	return m.Minor, err
+}
+
+func (r Rule) matches(req contracts.Request) bool {
+	return (r.SourceBank == "" || r.SourceBank == req.SourceBank) &&
+		(r.DestinationBank == "" || r.DestinationBank == req.DestinationBank) &&
+		(r.Currency == "" || r.Currency == req.Amount.Currency)
+}
+
+// specificity ranks a bank pair above a single bank above a currency alone
+func (r Rule) specificity() int {
+	result := 0
+	if r.SourceBank != "" {
+		result += 2
+	}
+	if r.DestinationBank != "" {
+		result += 2
+	}
+	if r.Currency != "" {
+		result++
+	}
+	return result
+}
+
+// Schedule is the complete fee configuration. The most specific matching rule prices a transfer, the earliest
+// one on a tie; a transfer no rule matches is free.
+type Schedule struct {
+	Rules []Rule `json:"rules,omitempty"`
+	// RevenueAccounts maps a currency, or * for any other, to the clearing house's BANK/ACCOUNT that fees in it
+	// are credited to
+	RevenueAccounts map[string]string `json:"revenueAccounts,omitempty"`
+}
+
+// Load reads a JSON encoded Schedule. No path is an empty schedule: transfers are free.
+func Load(path string) (Schedule, error) {
+	if path == "" {
+		return Schedule{}, nil
+	}
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return Schedule{}, err
+	}
+	result := Schedule{}
+	if err := json.Unmarshal(contents, &result); err != nil {
+		return Schedule{}, fmt.Errorf("bad fee schedule %s: %w", path, err)
+	}
+	if err := result.Validate(); err != nil {
+		return Schedule{}, fmt.Errorf("bad fee schedule %s: %w", path, err)
+	}
+	return result, nil
+}
+
+func (s Schedule) Validate() error {
+	for i, r := range s.Rules {
+		if err := r.validate(); err != nil {
+			return fmt.Errorf("rule %d: %w", i, err)
+		}
+	}
+	for currency, account := range s.RevenueAccounts {
+		if bank, acc, ok := strings.Cut(account, "/"); !ok || bank == "" || acc == "" {
+			return fmt.Errorf("revenue account of %s: %q is not BANK/ACCOUNT", currency, account)
+		}
+	}
+	return nil
+}
+
+func (r Rule) validate() error {
+	currency := r.Currency
+	if currency == "" {
+		currency = narrowestCurrency
+	}
+	parse := func(amount string) (int64, error) {
+		m, err := minor(amount, currency)
+		if err != nil && r.Currency == "" {
+			err = fmt.Errorf("%w; name the rule's currency to use decimal places", err)
+		}
+		return m, err
+	}
+	amounts := map[string]int64{}
+	for name, v := range map[string]string{"flat": r.Flat, "min": r.Min, "max": r.Max} {
+		m, err := parse(v)
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		amounts[name] = m
+	}
+	if r.Percent < 0 || math.IsNaN(r.Percent) || math.IsInf(r.Percent, 0) {
+		return errors.New("percent must not be negative")
+	}
+	if r.Max != "" && amounts["min"] > amounts["max"] {
+		return fmt.Errorf("min %s is above max %s", r.Min, r.Max)
+	}
+	covered := int64(0)
+	for i, t := range r.Tiers {
+		if t.Percent < 0 || math.IsNaN(t.Percent) || math.IsInf(t.Percent, 0) {
+			return fmt.Errorf("tier %d: percent must not be negative", i)
+		}
+		upTo, err := parse(t.UpTo)
+		if err != nil {
+			return fmt.Errorf("tier %d: upTo: %w", i, err)
+		}
+		// only the last tier may leave upTo out
+		if (t.UpTo != "" || i < len(r.Tiers)-1) && upTo <= covered {
+			return fmt.Errorf("tier %d: upTo must be above the previous tier's", i)
+		}
+		covered = upTo
+	}
+	return nil
+}
+
+// Quote prices req, in the currency of its amount. A fee comes with the revenue account it is credited to. A rule
+// with an amount the currency cannot express exactly is an error rather than rounded.
+func (s Schedule) Quote(req contracts.Request) (contracts.FeeQuote, error) {
+	currency := req.Amount.Currency
+	result := contracts.FeeQuote{Total: contracts.Money{Currency: currency}}
+	rule, ok := s.rule(req)
+	if !ok {
+		return result, nil
+	}
+	flat, err := minor(rule.Flat, currency)
+	if err != nil {
+		return contracts.FeeQuote{}, fmt.Errorf("flat fee: %w", err)
+	}
+	floor, err := minor(rule.Min, currency)
+	if err != nil {
+		return contracts.FeeQuote{}, fmt.Errorf("minimum fee: %w", err)
+	}
+	ceiling, err := minor(rule.Max, currency)
+	if err != nil {
+		return contracts.FeeQuote{}, fmt.Errorf("fee cap: %w", err)
+	}
+	tiered, err := tieredFee(req.Amount, rule.Tiers)
+	if err != nil {
+		return contracts.FeeQuote{}, err
+	}
+	add := func(name string, minor int64) {
+		if minor == 0 {
+			return
+		}
+		result.Items = append(result.Items, contracts.FeeItem{Name: name, Amount: contracts.Money{Minor: minor, Currency: currency}})
+		result.Total.Minor += minor
+	}
+	add(ItemFlat, flat)
+	add(ItemPercentage, percentOf(req.Amount.Minor, rule.Percent))
+	add(ItemTiered, tiered)
+	if result.Total.Minor < floor {
+		add(ItemMinimum, floor-result.Total.Minor)
+	}
+	if rule.Max != "" && result.Total.Minor > ceiling {
+		add(ItemMaximum, ceiling-result.Total.Minor)
+	}
+	if result.Total.IsZero() {
+		return result, nil
+	}
+	account, ok := s.RevenueAccounts[currency]
+	if !ok {
+		account, ok = s.RevenueAccounts["*"]
+	}
+	if !ok {
+		return contracts.FeeQuote{}, fmt.Errorf("%w: %s", ErrNoRevenueAccount, currency)
+	}
+	result.RevenueBank, result.RevenueAccount, _ = strings.Cut(account, "/")
+	return result, nil
+}
+
+func (s Schedule) rule(req contracts.Request) (Rule, bool) {
+	result, found := Rule{}, false
+	for _, r := range s.Rules {
+		if r.matches(req) && (!found || r.specificity() > result.specificity()) {
+			result, found = r, true
+		}
+	}
+	return result, found
+}
+
+// percentOf is percent of minor units, rounded to the nearest one
+func percentOf(minor int64, percent float64) int64 {
+	return int64(math.Round(float64(minor) * percent / 100))
+}
+
+// tieredFee charges each slice of amount at its tier's rate, like income tax bands
+func tieredFee(amount contracts.Money, tiers []Tier) (int64, error) {
+	fee, covered := 0.0, int64(0)
+	for i, t := range tiers {
+		upTo := amount.Minor
+		if t.UpTo != "" {
+			bound, err := minor(t.UpTo, amount.Currency)
+			if err != nil {
+				return 0, fmt.Errorf("tier %d: %w", i, err)
+			}
+			if bound < upTo {
+				upTo = bound
+			}
+		}
+		if upTo <= covered {
+			break
+		}
+		fee += float64(upTo-covered) * t.Percent / 100
+		covered = upTo
+	}
+	return int64(math.Round(fee)), nil
+}
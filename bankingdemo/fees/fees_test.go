@@ -0,0 +1,195 @@
+package fees
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/arunsworld/temporal-demo/bankingdemo/contracts"
+)
+
+func TestQuote(t *testing.T) {
+	request := func(amount, currency string) contracts.Request {
+		m, err := contracts.ParseMoney(amount, currency)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return contracts.Request{SourceBank: "abbank", DestinationBank: "bcbank", Amount: m}
+	}
+	type item struct {
+		name  string
+		minor int64
+	}
+	revenue := map[string]string{"*": "abbank/FEES"}
+	tiers := []Tier{{UpTo: "1000", Percent: 1}, {UpTo: "5000", Percent: 0.5}, {Percent: 0.1}}
+	tests := []struct {
+		name      string
+		rules     []Rule
+		revenue   map[string]string
+		req       contracts.Request
+		wantItems []item
+		wantTotal int64
+		wantErr   bool
+	}{
+		{
+			name:      "no rule is free",
+			req:       request("100", "USD"),
+			wantTotal: 0,
+		},
+		{
+			name:      "flat",
+			rules:     []Rule{{Flat: "1.25"}},
+			req:       request("100", "USD"),
+			wantItems: []item{{ItemFlat, 125}},
+			wantTotal: 125,
+		},
+		{
+			name:      "percentage rounds to the nearest minor unit",
+			rules:     []Rule{{Percent: 0.15}},
+			req:       request("123.45", "USD"),
+			wantItems: []item{{ItemPercentage, 19}},
+			wantTotal: 19,
+		},
+		{
+			name:      "tiered within the first tier",
+			rules:     []Rule{{Tiers: tiers}},
+			req:       request("500", "USD"),
+			wantItems: []item{{ItemTiered, 500}},
+			wantTotal: 500,
+		},
+		{
+			name:  "tiered across every tier",
+			rules: []Rule{{Tiers: tiers}},
+			req:   request("10000", "USD"),
+			// 1% of 1000, 0.5% of 4000 and 0.1% of 5000
+			wantItems: []item{{ItemTiered, 3500}},
+			wantTotal: 3500,
+		},
+		{
+			name:      "minimum tops up the components",
+			rules:     []Rule{{Flat: "1", Percent: 0.1, Min: "2", Max: "25"}},
+			req:       request("100", "USD"),
+			wantItems: []item{{ItemFlat, 100}, {ItemPercentage, 10}, {ItemMinimum, 90}},
+			wantTotal: 200,
+		},
+		{
+			name:      "maximum caps the components",
+			rules:     []Rule{{Flat: "1", Percent: 0.1, Min: "2", Max: "25"}},
+			req:       request("50000", "USD"),
+			wantItems: []item{{ItemFlat, 100}, {ItemPercentage, 5000}, {ItemMaximum, -2600}},
+			wantTotal: 2500,
+		},
+		{
+			name:      "between min and max",
+			rules:     []Rule{{Flat: "1", Percent: 0.1, Min: "2", Max: "25"}},
+			req:       request("5000", "USD"),
+			wantItems: []item{{ItemFlat, 100}, {ItemPercentage, 500}},
+			wantTotal: 600,
+		},
+		{
+			name:      "the most specific rule applies",
+			rules:     []Rule{{Flat: "1"}, {Currency: "USD", Flat: "2"}, {SourceBank: "abbank", DestinationBank: "bcbank", Flat: "3"}},
+			req:       request("100", "USD"),
+			wantItems: []item{{ItemFlat, 300}},
+			wantTotal: 300,
+		},
+		{
+			name:      "amounts in a currency with three decimal places",
+			rules:     []Rule{{Currency: "KWD", Flat: "0.005"}},
+			req:       request("100", "KWD"),
+			wantItems: []item{{ItemFlat, 5}},
+			wantTotal: 5,
+		},
+		{
+			name:    "an amount finer than the currency is rejected, not rounded",
+			rules:   []Rule{{Flat: "0.005"}},
+			req:     request("100", "USD"),
+			wantErr: true,
+		},
+		{
+			name:    "a minimum finer than the currency is rejected",
+			rules:   []Rule{{Min: "0.5"}},
+			req:     request("100", "JPY"),
+			wantErr: true,
+		},
+		{
+			name:    "a tier bound finer than the currency is rejected",
+			rules:   []Rule{{Tiers: []Tier{{UpTo: "1000.5", Percent: 1}, {Percent: 0.5}}}},
+			req:     request("5000", "JPY"),
+			wantErr: true,
+		},
+		{
+			name:    "no revenue account",
+			rules:   []Rule{{Flat: "1"}},
+			revenue: map[string]string{"EUR": "abbank/FEES"},
+			req:     request("100", "USD"),
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := Schedule{Rules: tt.rules, RevenueAccounts: revenue}
+			if tt.revenue != nil {
+				s.RevenueAccounts = tt.revenue
+			}
+			got, err := s.Quote(tt.req)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("got %+v, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			currency := tt.req.Amount.Currency
+			if got.Total != (contracts.Money{Minor: tt.wantTotal, Currency: currency}) {
+				t.Fatalf("got total %s, want %d minor units", got.Total, tt.wantTotal)
+			}
+			if len(got.Items) != len(tt.wantItems) {
+				t.Fatalf("got items %+v, want %+v", got.Items, tt.wantItems)
+			}
+			for i, want := range tt.wantItems {
+				if got.Items[i].Name != want.name || got.Items[i].Amount != (contracts.Money{Minor: want.minor, Currency: currency}) {
+					t.Fatalf("item %d is %s %s, want %s %d minor units", i, got.Items[i].Name, got.Items[i].Amount, want.name, want.minor)
+				}
+			}
+			if tt.wantTotal != 0 && (got.RevenueBank != "abbank" || got.RevenueAccount != "FEES") {
+				t.Fatalf("credited to %s/%s, want abbank/FEES", got.RevenueBank, got.RevenueAccount)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		json    string
+		wantErr bool
+	}{
+		{name: "valid", json: `{"rules": [{"flat": "1", "percent": 0.1, "min": "2", "max": "25"}], "revenueAccounts": {"*": "abbank/FEES"}}`},
+		{name: "fraction of the rule's currency", json: `{"rules": [{"currency": "USD", "flat": "0.005"}]}`, wantErr: true},
+		{name: "fraction of a currency with three decimal places", json: `{"rules": [{"currency": "KWD", "flat": "0.005"}]}`},
+		{name: "fraction without a currency", json: `{"rules": [{"flat": "0.5"}]}`, wantErr: true},
+		{name: "tier bound with a fraction without a currency", json: `{"rules": [{"tiers": [{"upTo": "999.99", "percent": 1}, {"percent": 1}]}]}`, wantErr: true},
+		{name: "whole amounts without a currency", json: `{"rules": [{"flat": "1", "tiers": [{"upTo": "1000", "percent": 1}, {"percent": 0.5}]}]}`},
+		{name: "a number rather than a decimal string", json: `{"rules": [{"flat": 1}]}`, wantErr: true},
+		{name: "negative", json: `{"rules": [{"min": "-1"}]}`, wantErr: true},
+		{name: "negative percent", json: `{"rules": [{"percent": -1}]}`, wantErr: true},
+		{name: "min above max", json: `{"rules": [{"min": "30", "max": "25"}]}`, wantErr: true},
+		{name: "tiers out of order", json: `{"rules": [{"tiers": [{"upTo": "1000", "percent": 1}, {"upTo": "500", "percent": 1}, {"percent": 1}]}]}`, wantErr: true},
+		{name: "open tier before the last", json: `{"rules": [{"tiers": [{"percent": 1}, {"percent": 1}]}]}`, wantErr: true},
+		{name: "bad revenue account", json: `{"revenueAccounts": {"USD": "FEES"}}`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := Schedule{}
+			err := json.Unmarshal([]byte(tt.json), &s)
+			if err == nil {
+				err = s.Validate()
+			}
+			if tt.wantErr != (err != nil) {
+				t.Fatalf("got %v, want an error: %v", err, tt.wantErr)
+			}
+		})
+	}
+}
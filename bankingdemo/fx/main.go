@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/arunsworld/temporal-demo/bankingdemo/contracts"
+	temporalgolibs "github.com/arunsworld/temporal-demo/temporal-golibs"
+	"go.temporal.io/sdk/activity"
+	"go.temporal.io/sdk/worker"
+)
+
+func main() {
+	if err := run(); err != nil {
+		log.Fatal(err)
+	}
+}
+
+/*
+Relies on:
+  - FX_RATES_FILE: JSON rate table, {"base": "USD", "rates": {"EUR": 0.92}} (default: a built-in USD table)
+  - FX_QUOTE_TTL: how long a locked quote is honoured (default 30s)
+*/
+
+const defaultQuoteTTL = 30 * time.Second
+
+func run() error {
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	rates, err := loadRates(os.Getenv("FX_RATES_FILE"))
+	if err != nil {
+		return err
+	}
+	quoteTTL := defaultQuoteTTL
+	if v := os.Getenv("FX_QUOTE_TTL"); v != "" {
+		if quoteTTL, err = time.ParseDuration(v); err != nil || quoteTTL <= 0 {
+			return fmt.Errorf("bad FX_QUOTE_TTL: %q", v)
+		}
+	}
+	srv := &service{rates: rates, quoteTTL: quoteTTL}
+	log.Printf("quoting %d currencies against %s", len(rates.Rates), rates.Base)
+
+	c, err := temporalgolibs.NewClient(ctx, "default")
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	w := worker.New(c, contracts.FXTaskQueue, worker.Options{})
+	w.RegisterActivityWithOptions(srv.indicativeActivity, activity.RegisterOptions{
+		Name: contracts.IndicativeFXRateActivity,
+	})
+	w.RegisterActivityWithOptions(srv.lockActivity, activity.RegisterOptions{
+		Name: contracts.LockFXRateActivity,
+	})
+
+	return w.Run(worker.InterruptCh())
+}
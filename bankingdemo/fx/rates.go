@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/arunsworld/temporal-demo/bankingdemo/contracts"
+	"github.com/google/uuid"
+	"go.temporal.io/sdk/temporal"
+)
+
+// rateTable holds the rate of every currency against Base. Cross rates go through Base.
+type rateTable struct {
+	Base  string             `json:"base"`
+	Rates map[string]float64 `json:"rates"` // units of the currency per unit of Base
+}
+
+// used when FX_RATES_FILE is not set
+var defaultRates = rateTable{
+	Base: "USD",
+	Rates: map[string]float64{
+		"USD": 1,
+		"EUR": 0.92,
+		"GBP": 0.79,
+		"INR": 83.10,
+		"JPY": 151.20,
+	},
+}
+
+func loadRates(path string) (rateTable, error) {
+	if path == "" {
+		return defaultRates, nil
+	}
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return rateTable{}, err
+	}
+	result := rateTable{}
+	if err := json.Unmarshal(contents, &result); err != nil {
+		return rateTable{}, fmt.Errorf("bad rates file %s: %w", path, err)
+	}
+	result.Base = strings.ToUpper(result.Base)
+	if result.Base == "" {
+		return rateTable{}, fmt.Errorf("bad rates file %s: no base currency", path)
+	}
+	rates := map[string]float64{result.Base: 1}
+	for currency, rate := range result.Rates {
+		if rate <= 0 || math.IsNaN(rate) || math.IsInf(rate, 0) {
+			return rateTable{}, fmt.Errorf("bad rates file %s: rate of %s must be positive", path, currency)
+		}
+		rates[strings.ToUpper(currency)] = rate
+	}
+	if rates[result.Base] != 1 {
+		return rateTable{}, fmt.Errorf("bad rates file %s: rate of the base currency must be 1", path)
+	}
+	result.Rates = rates
+	return result, nil
+}
+
+// rate is the units of to per unit of from
+func (t rateTable) rate(from, to string) (float64, error) {
+	fromRate, ok := t.Rates[from]
+	if !ok {
+		return 0, unknownCurrency(from)
+	}
+	toRate, ok := t.Rates[to]
+	if !ok {
+		return 0, unknownCurrency(to)
+	}
+	return toRate / fromRate, nil
+}
+
+func unknownCurrency(currency string) error {
+	return temporal.NewNonRetryableApplicationError(fmt.Sprintf("unknown currency %q", currency), contracts.FXUnknownCurrencyError, nil)
+}
+
+type service struct {
+	rates    rateTable
+	quoteTTL time.Duration
+}
+
+func (s *service) quote(req contracts.FXQuoteRequest) (contracts.FXQuote, error) {
+	to := req.To
+	if to == "" {
+		to = s.rates.Base
+	}
+	rate, err := s.rates.rate(req.Amount.Currency, to)
+	if err != nil {
+		return contracts.FXQuote{}, err
+	}
+	return contracts.FXQuote{
+		Rate:            rate,
+		Amount:          req.Amount,
+		ConvertedAmount: contracts.MoneyFromFloat(req.Amount.Float64()*rate, to),
+		QuotedAt:        time.Now(),
+	}, nil
+}
+
+// indicativeActivity converts at the current rate, e.g. to the reference currency for AML thresholds
+func (s *service) indicativeActivity(_ context.Context, req contracts.FXQuoteRequest) (contracts.FXQuote, error) {
+	return s.quote(req)
+}
+
+// lockActivity fixes the rate for a transfer until the quote expires
+func (s *service) lockActivity(_ context.Context, req contracts.FXQuoteRequest) (contracts.FXQuote, error) {
+	result, err := s.quote(req)
+	if err != nil {
+		return contracts.FXQuote{}, err
+	}
+	result.ID = uuid.NewString()
+	result.ExpiresAt = result.QuotedAt.Add(s.quoteTTL)
+	log.Printf("locked %s/%s at %.6f until %v, quote %s. Ref: [%s]", result.Amount.Currency, result.ConvertedAmount.Currency, result.Rate,
+		result.ExpiresAt.Format(time.RFC3339), result.ID, req.Ref)
+	return result, nil
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"os"
+	"sync"
+	"time"
+)
+
+// Audit events
+const (
+	auditScreened     = "screened" // decided by the rules engine without a review
+	auditReviewOpened = "review-opened"
+	auditApproval     = "approval" // first of two approvals under dual control
+	auditDecision     = "decision"
+	auditCompleted    = "completed"
+	auditDropped      = "dropped"
+	auditReminder     = "reminder"  // the review SLA reminded reviewers
+	auditEscalated    = "escalated" // moved to the senior reviewers
+	auditExpired      = "expired"   // the review SLA applied its default outcome
+)
+
+type auditEntry struct {
+	Time       time.Time
+	Ref        string
+	ReviewID   string
+	Event      string
+	Reviewer   string `json:",omitempty"`
+	Decision   string `json:",omitempty"`
+	ReasonCode string `json:",omitempty"`
+	Comment    string `json:",omitempty"`
+}
+
+// auditLog is append-only: entries are never changed or removed.
+type auditLog interface {
+	Append(e auditEntry) error
+	Entries(ref string) ([]auditEntry, error) // every entry when ref is empty, oldest first
+}
+
+// jsonlAuditLog appends one JSON document per line to a file, or keeps the entries in memory when path is empty.
+type jsonlAuditLog struct {
+	mu      sync.Mutex
+	path    string
+	entries []auditEntry
+}
+
+func newAuditLog(path string) *jsonlAuditLog {
+	return &jsonlAuditLog{path: path}
+}
+
+func (l *jsonlAuditLog) Append(e auditEntry) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.path == "" {
+		l.entries = append(l.entries, e)
+		return nil
+	}
+	line, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	// replicas may append to the same file
+	unlock, err := lockFile(l.path+".lock", true)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	f, err := os.OpenFile(l.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func (l *jsonlAuditLog) Entries(ref string) ([]auditEntry, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	all := l.entries
+	if l.path != "" {
+		var err error
+		if all, err = l.readFile(); err != nil {
+			return nil, err
+		}
+	}
+	result := []auditEntry{}
+	for _, e := range all {
+		if ref == "" || e.Ref == ref {
+			result = append(result, e)
+		}
+	}
+	return result, nil
+}
+
+func (l *jsonlAuditLog) readFile() ([]auditEntry, error) {
+	f, err := os.Open(l.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	result := []auditEntry{}
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		e := auditEntry{}
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			return nil, err
+		}
+		result = append(result, e)
+	}
+	return result, scanner.Err()
+}
@@ -0,0 +1,8 @@
+//go:build !unix
+
+package main
+
+// lockFile is a no-op where advisory file locks are not available; replicas must not share a store there.
+func lockFile(string, bool) (func(), error) {
+	return func() {}, nil
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/arunsworld/temporal-demo/bankingdemo/contracts"
+	"go.temporal.io/api/serviceerror"
+	"go.temporal.io/sdk/temporal"
+)
+
+// heartbeatReviews heartbeats the activity of every pending review each interval until ctx is done, so that a
+// workflow notices within its HeartbeatTimeout when this service is gone and retries the activity, which then
+// reattaches to the review. Reviews whose activity was cancelled or no longer exists are dropped.
+func (s *service) heartbeatReviews(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.heartbeatOnce(ctx)
+		}
+	}
+}
+
+func (s *service) heartbeatOnce(ctx context.Context) {
+	reviews, err := s.pendingReviews()
+	if err != nil {
+		log.Printf("unable to list reviews to heartbeat: %v", err)
+		return
+	}
+	for _, r := range reviews {
+		if len(r.Token) == 0 {
+			continue
+		}
+		err := s.workflowClient.RecordActivityHeartbeat(ctx, r.Token)
+		var notFound *serviceerror.NotFound
+		switch {
+		case err == nil:
+		case temporal.IsCanceledError(err):
+			s.dropReview(ctx, r, "activity cancelled")
+		case errors.As(err, &notFound):
+			s.dropReview(ctx, r, "activity no longer exists")
+		default:
+			log.Printf("unable to heartbeat the review of %s: %v", r.Request.Ref, err)
+		}
+	}
+}
+
+// dropReview forgets a review whose activity was cancelled or has gone, unless the activity was retried or the
+// review decided in the meantime. A cancelled activity is then acknowledged.
+func (s *service) dropReview(ctx context.Context, stale pendingReview, reason string) {
+	review, dropped, err := s.forgetReview(stale)
+	if err != nil {
+		log.Printf("unable to drop the review of %s: %v", stale.Request.Ref, err)
+		return
+	}
+	if !dropped {
+		return
+	}
+	log.Printf("dropped the review of %s: %s", review.Request.Ref, reason)
+	s.recordAudit(review, auditDropped, &contracts.AMLDecision{Comment: reason})
+	err = s.workflowClient.CompleteActivity(ctx, review.Token, nil, temporal.NewCanceledError())
+	var notFound *serviceerror.NotFound
+	if err != nil && !errors.As(err, &notFound) {
+		log.Printf("unable to acknowledge the cancellation of %s: %v", review.Request.Ref, err)
+	}
+}
+
+// forgetReview deletes the stored copy of stale unless it has since been retried or decided
+func (s *service) forgetReview(stale pendingReview) (pendingReview, bool, error) {
+	unlock, err := s.store.Lock()
+	if err != nil {
+		return pendingReview{}, false, err
+	}
+	defer unlock()
+
+	review, ok, err := s.store.Review(stale.ID)
+	if err != nil || !ok || review.Decision != nil || string(review.Token) != string(stale.Token) {
+		return pendingReview{}, false, err
+	}
+	return review, true, s.store.DeleteReview(review.ID)
+}
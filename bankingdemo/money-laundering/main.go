@@ -2,13 +2,18 @@ package main
 
 import (
 	"context"
+	"fmt"
 	"log"
 	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
 	"syscall"
+	"time"
 
 	"github.com/arunsworld/nursery"
+	"github.com/arunsworld/temporal-demo/bankingdemo/auth"
+	"github.com/arunsworld/temporal-demo/bankingdemo/contracts"
 	temporalgolibs "github.com/arunsworld/temporal-demo/temporal-golibs"
 	"go.temporal.io/sdk/activity"
 	"go.temporal.io/sdk/worker"
@@ -20,6 +25,52 @@ func main() {
 	}
 }
 
+/*
+Relies on:
+  - AML_REVIEW_MODE: activity (reviews held here, in the store) or signal (held by the transfers and found through
+    visibility), must match the clearing house (default activity)
+  - AML_STORE_FILE: where pending reviews and the transfer history of the velocity and structuring rules are kept
+    across restarts and shared by replicas (default aml-store.json); :memory: keeps them in memory only
+  - AML_AUDIT_FILE: append-only audit log of reviews and decisions (default aml-audit.jsonl); :memory: as above
+  - AML_DUAL_CONTROL_THRESHOLD: amount in the FX reference currency from which two reviewers must approve
+    (default 10000, 0 disables)
+  - AML_RULES_FILE: JSON rules that screen every transfer (default: send 1000 or more to a reviewer)
+  - AML_RULES_RELOAD_INTERVAL: how often the rules and watch-list files are checked for changes (default 10s)
+  - AML_WATCHLIST_FILE: sanctions watch list, .csv or .json (default: none, nothing is a hit)
+  - AML_HEARTBEAT_INTERVAL: how often the activities of pending reviews are heartbeated (default 30s), keep it well
+    under the clearing house's AML_REVIEW_HEARTBEAT_TIMEOUT
+  - AML_WATCHLIST_NAME_MATCH: similarity from 0 to 1 at which a name matches a listed name (default 0.85)
+  - AUTH_*: who may review, see auth.FromEnv
+*/
+
+const defaultStoreFile = "aml-store.json"
+const defaultAuditFile = "aml-audit.jsonl"
+const defaultDualControlThreshold = 10000.0
+const defaultRulesReloadInterval = 10 * time.Second
+const defaultHeartbeatInterval = 30 * time.Second
+
+func newAuditLogFromEnv() auditLog {
+	path := os.Getenv("AML_AUDIT_FILE")
+	switch path {
+	case "":
+		path = defaultAuditFile
+	case ":memory:":
+		path = ""
+	}
+	return newAuditLog(path)
+}
+
+func newStore() (reviewStore, error) {
+	path := os.Getenv("AML_STORE_FILE")
+	switch path {
+	case "":
+		path = defaultStoreFile
+	case ":memory:":
+		return newMemoryStore(), nil
+	}
+	return newFileStore(path)
+}
+
 func run() error {
 	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer cancel()
@@ -30,11 +81,81 @@ func run() error {
 	}
 	defer c.Close()
 
-	srv := newService(c)
+	store, err := newStore()
+	if err != nil {
+		return err
+	}
+	dualControlThreshold := defaultDualControlThreshold
+	if v := os.Getenv("AML_DUAL_CONTROL_THRESHOLD"); v != "" {
+		if dualControlThreshold, err = strconv.ParseFloat(v, 64); err != nil {
+			return fmt.Errorf("bad AML_DUAL_CONTROL_THRESHOLD: %w", err)
+		}
+	}
+
+	rules, err := newRulesEngine(os.Getenv("AML_RULES_FILE"), store)
+	if err != nil {
+		return err
+	}
+	nameMatchThreshold := defaultNameMatchThreshold
+	if v := os.Getenv("AML_WATCHLIST_NAME_MATCH"); v != "" {
+		if nameMatchThreshold, err = strconv.ParseFloat(v, 64); err != nil || nameMatchThreshold <= 0 || nameMatchThreshold > 1 {
+			return fmt.Errorf("bad AML_WATCHLIST_NAME_MATCH: %q", v)
+		}
+	}
+	watchList, err := newWatchList(os.Getenv("AML_WATCHLIST_FILE"), nameMatchThreshold)
+	if err != nil {
+		return err
+	}
+	reloadInterval := defaultRulesReloadInterval
+	if v := os.Getenv("AML_RULES_RELOAD_INTERVAL"); v != "" {
+		if reloadInterval, err = time.ParseDuration(v); err != nil || reloadInterval <= 0 {
+			return fmt.Errorf("bad AML_RULES_RELOAD_INTERVAL: %q", v)
+		}
+	}
+
+	heartbeatInterval := defaultHeartbeatInterval
+	if v := os.Getenv("AML_HEARTBEAT_INTERVAL"); v != "" {
+		if heartbeatInterval, err = time.ParseDuration(v); err != nil || heartbeatInterval <= 0 {
+			return fmt.Errorf("bad AML_HEARTBEAT_INTERVAL: %q", v)
+		}
+	}
+
+	reviewMode := contracts.AMLReviewModeActivity
+	if v := os.Getenv("AML_REVIEW_MODE"); v != "" {
+		if v != contracts.AMLReviewModeActivity && v != contracts.AMLReviewModeSignal {
+			return fmt.Errorf("bad AML_REVIEW_MODE: %q", v)
+		}
+		reviewMode = v
+	}
+
+	authn, err := auth.FromEnv("Money Laundering Service")
+	if err != nil {
+		return err
+	}
+
+	srv := newService(c, store, newAuditLogFromEnv(), rules, watchList, reviewMode, dualControlThreshold, authn)
+	if err := srv.resumeCompletions(ctx); err != nil {
+		return err
+	}
 
-	w := worker.New(c, "money-laundering", worker.Options{})
+	w := worker.New(c, contracts.MoneyLaunderingTaskQueue, worker.Options{})
 	w.RegisterActivityWithOptions(srv.temporalActivity, activity.RegisterOptions{
-		Name: "MoneyLaunderingCheck",
+		Name: contracts.MoneyLaunderingCheckActivity,
+	})
+	w.RegisterActivityWithOptions(srv.screenActivity, activity.RegisterOptions{
+		Name: contracts.AMLScreenActivity,
+	})
+	w.RegisterActivityWithOptions(srv.sanctionsActivity, activity.RegisterOptions{
+		Name: contracts.SanctionsScreenActivity,
+	})
+	w.RegisterActivityWithOptions(srv.reminderActivity, activity.RegisterOptions{
+		Name: contracts.AMLReviewReminderActivity,
+	})
+	w.RegisterActivityWithOptions(srv.escalateActivity, activity.RegisterOptions{
+		Name: contracts.EscalateAMLReviewActivity,
+	})
+	w.RegisterActivityWithOptions(srv.expireActivity, activity.RegisterOptions{
+		Name: contracts.ExpireAMLReviewActivity,
 	})
 
 	server := &http.Server{Addr: ":9999"}
@@ -55,5 +176,14 @@ func run() error {
 			<-ctx.Done()
 			server.Close()
 		},
+		func(ctx context.Context, _ chan error) {
+			srv.heartbeatReviews(ctx, heartbeatInterval)
+		},
+		func(ctx context.Context, _ chan error) {
+			reloadEvery(ctx.Done(), reloadInterval, rules.path, rules.reload)
+		},
+		func(ctx context.Context, _ chan error) {
+			reloadEvery(ctx.Done(), reloadInterval, watchList.path, watchList.reload)
+		},
 	)
 }
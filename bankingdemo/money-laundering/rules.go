@@ -0,0 +1,336 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/arunsworld/temporal-demo/bankingdemo/contracts"
+	"github.com/arunsworld/temporal-demo/bankingdemo/faults"
+)
+
+// Outcomes of a risk assessment
+const (
+	outcomeApprove = "approve"
+	outcomeReject  = "reject"
+	outcomeManual  = "manual"
+)
+
+const rulesEngineReviewer = "rules-engine"
+
+// rulesConfig scores every transfer; the total decides whether it is approved, rejected or sent to a reviewer.
+// Rules left out of the file are disabled.
+type rulesConfig struct {
+	AutoApproveBelow    int                  `json:"autoApproveBelow"`
+	AutoRejectAtOrAbove int                  `json:"autoRejectAtOrAbove"`
+	LargeAmount         *largeAmountRule     `json:"largeAmount,omitempty"`
+	Velocity            *velocityRule        `json:"velocity,omitempty"`
+	Structuring         *structuringRule     `json:"structuring,omitempty"`
+	BlockedAccounts     *blockedAccountsRule `json:"blockedAccounts,omitempty"`
+	RoundAmounts        *roundAmountRule     `json:"roundAmounts,omitempty"`
+}
+
+// largeAmountRule scores transfers of at least Threshold
+type largeAmountRule struct {
+	Threshold float64 `json:"threshold"`
+	Score     int     `json:"score"`
+}
+
+// velocityRule scores a source account that sends more than MaxTransfers, or more than MaxAmount in total,
+// within Window
+type velocityRule struct {
+	Window       faults.Duration `json:"window"`
+	MaxTransfers int             `json:"maxTransfers,omitempty"`
+	MaxAmount    float64         `json:"maxAmount,omitempty"`
+	Score        int             `json:"score"`
+}
+
+// structuringRule scores a source account that sends at least Count transfers within Window that are just
+// under Threshold, i.e. at least LowerFraction of it
+type structuringRule struct {
+	Window        faults.Duration `json:"window"`
+	Threshold     float64         `json:"threshold"`
+	LowerFraction float64         `json:"lowerFraction"`
+	Count         int             `json:"count"`
+	Score         int             `json:"score"`
+}
+
+// blockedAccountsRule scores transfers from or to any of Accounts, given as ACCOUNT or BANK/ACCOUNT
+type blockedAccountsRule struct {
+	Accounts []string `json:"accounts"`
+	Score    int      `json:"score"`
+}
+
+// roundAmountRule scores amounts that are an exact multiple of Multiple
+type roundAmountRule struct {
+	Multiple float64 `json:"multiple"`
+	Score    int     `json:"score"`
+}
+
+// defaultRules sends transfers of 1000 or more to a reviewer and approves the rest, as before rules existed
+var defaultRules = rulesConfig{
+	AutoApproveBelow:    30,
+	AutoRejectAtOrAbove: 100,
+	LargeAmount:         &largeAmountRule{Threshold: 1000, Score: 50},
+}
+
+func (c rulesConfig) validate() error {
+	if c.AutoApproveBelow > c.AutoRejectAtOrAbove {
+		return fmt.Errorf("autoApproveBelow must not exceed autoRejectAtOrAbove")
+	}
+	if c.Velocity != nil && c.Velocity.Window <= 0 {
+		return fmt.Errorf("velocity.window must be positive")
+	}
+	if s := c.Structuring; s != nil {
+		if s.Window <= 0 || s.Threshold <= 0 || s.Count < 1 {
+			return fmt.Errorf("structuring needs a positive window, threshold and count")
+		}
+		if s.LowerFraction <= 0 || s.LowerFraction >= 1 {
+			return fmt.Errorf("structuring.lowerFraction must be between 0 and 1")
+		}
+	}
+	if c.RoundAmounts != nil && c.RoundAmounts.Multiple <= 0 {
+		return fmt.Errorf("roundAmounts.multiple must be positive")
+	}
+	return nil
+}
+
+type triggeredRule struct {
+	Name   string
+	Score  int
+	Detail string
+}
+
+type riskAssessment struct {
+	Score     int
+	Outcome   string
+	Triggered []triggeredRule
+}
+
+func (a riskAssessment) ruleNames() []string {
+	result := []string{}
+	for _, t := range a.Triggered {
+		result = append(result, t.Name)
+	}
+	return result
+}
+
+func (a riskAssessment) summary() string {
+	details := []string{}
+	for _, t := range a.Triggered {
+		details = append(details, fmt.Sprintf("%s +%d (%s)", t.Name, t.Score, t.Detail))
+	}
+	if len(details) == 0 {
+		return fmt.Sprintf("risk score %d", a.Score)
+	}
+	return fmt.Sprintf("risk score %d: %s", a.Score, strings.Join(details, "; "))
+}
+
+// decision is the automatic decision for an assessment that did not need a reviewer
+func (a riskAssessment) decision(now time.Time) contracts.AMLDecision {
+	reason := "auto-approved"
+	if a.Outcome == outcomeReject {
+		reason = "auto-rejected"
+	}
+	return contracts.AMLDecision{
+		Decision:       a.Outcome,
+		Reviewer:       rulesEngineReviewer,
+		ReasonCode:     reason,
+		Comment:        a.summary(),
+		DecidedAt:      now,
+		RiskScore:      a.Score,
+		TriggeredRules: a.ruleNames(),
+	}
+}
+
+type transferRecord struct {
+	Ref    string
+	Amount float64 // in the reference currency
+	At     time.Time
+}
+
+// transferHistory keeps the recent transfers of each source account
+type transferHistory interface {
+	// RecordTransfer adds t to the history of account, once per reference, forgets transfers older than keep and
+	// returns what remains of the account's history
+	RecordTransfer(account string, t transferRecord, keep time.Duration) ([]transferRecord, error)
+}
+
+// rulesEngine assesses transfers against rules that can be reloaded from a file while running. The transfer
+// history the velocity and structuring rules look at is kept in the review store, so replicas sharing a store
+// file see each other's transfers.
+type rulesEngine struct {
+	mu      sync.RWMutex
+	rules   rulesConfig
+	path    string
+	modTime time.Time
+	history transferHistory
+}
+
+func newRulesEngine(path string, history transferHistory) (*rulesEngine, error) {
+	result := &rulesEngine{
+		rules:   defaultRules,
+		path:    path,
+		history: history,
+	}
+	if path == "" {
+		return result, nil
+	}
+	if _, err := result.reload(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// reload reads the rules file if it changed since it was last read
+func (e *rulesEngine) reload() (bool, error) {
+	stat, err := os.Stat(e.path)
+	if err != nil {
+		return false, err
+	}
+	e.mu.RLock()
+	unchanged := stat.ModTime().Equal(e.modTime)
+	e.mu.RUnlock()
+	if unchanged {
+		return false, nil
+	}
+	contents, err := os.ReadFile(e.path)
+	if err != nil {
+		return false, err
+	}
+	rules := rulesConfig{}
+	if err := json.Unmarshal(contents, &rules); err != nil {
+		return false, fmt.Errorf("bad rules file %s: %w", e.path, err)
+	}
+	if err := rules.validate(); err != nil {
+		return false, fmt.Errorf("bad rules file %s: %w", e.path, err)
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.rules = rules
+	e.modTime = stat.ModTime()
+	return true, nil
+}
+
+// reloadEvery calls reload every interval until done is closed. A file that fails to load is logged and what
+// was loaded before stays in force.
+func reloadEvery(done <-chan struct{}, interval time.Duration, path string, reload func() (bool, error)) {
+	if path == "" {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			changed, err := reload()
+			if err != nil {
+				log.Printf("keeping previous contents of %s: %v", path, err)
+				continue
+			}
+			if changed {
+				log.Printf("reloaded %s", path)
+			}
+		}
+	}
+}
+
+func (e *rulesEngine) assess(req contracts.Request, now time.Time) (riskAssessment, error) {
+	e.mu.RLock()
+	rules := e.rules
+	e.mu.RUnlock()
+
+	var recent []transferRecord
+	if keep := rules.historyWindow(); keep > 0 {
+		var err error
+		account := req.SourceBank + "/" + req.SourceAcc
+		recent, err = e.history.RecordTransfer(account, transferRecord{Ref: req.Ref, Amount: req.AMLAmount().Float64(), At: now}, keep)
+		if err != nil {
+			return riskAssessment{}, err
+		}
+	}
+	result := riskAssessment{}
+	trigger := func(name string, score int, detail string, args ...interface{}) {
+		result.Score += score
+		result.Triggered = append(result.Triggered, triggeredRule{Name: name, Score: score, Detail: fmt.Sprintf(detail, args...)})
+	}
+
+	// thresholds are in the reference currency; a round amount is round in the customer's own
+	if r := rules.LargeAmount; r != nil && req.AMLAmount().Float64() >= r.Threshold {
+		trigger("large-amount", r.Score, "%s is at least %.2f", req.AMLAmount(), r.Threshold)
+	}
+	if r := rules.Velocity; r != nil {
+		count, total := 0, 0.0
+		for _, t := range recent {
+			if now.Sub(t.At) <= time.Duration(r.Window) {
+				count++
+				total += t.Amount
+			}
+		}
+		if (r.MaxTransfers > 0 && count > r.MaxTransfers) || (r.MaxAmount > 0 && total > r.MaxAmount) {
+			trigger("velocity", r.Score, "%d transfers totalling %.2f within %v", count, total, time.Duration(r.Window))
+		}
+	}
+	if r := rules.Structuring; r != nil {
+		count := 0
+		for _, t := range recent {
+			if now.Sub(t.At) <= time.Duration(r.Window) && t.Amount >= r.Threshold*r.LowerFraction && t.Amount < r.Threshold {
+				count++
+			}
+		}
+		if count >= r.Count {
+			trigger("structuring", r.Score, "%d transfers just under %.2f within %v", count, r.Threshold, time.Duration(r.Window))
+		}
+	}
+	if r := rules.BlockedAccounts; r != nil {
+		for _, blocked := range r.Accounts {
+			if matchesAccount(blocked, req.SourceBank, req.SourceAcc) || matchesAccount(blocked, req.DestinationBank, req.DestinationAcc) {
+				trigger("blocked-account", r.Score, "%s is blocked", blocked)
+				break
+			}
+		}
+	}
+	if r := rules.RoundAmounts; r != nil && isMultiple(req.Amount, r.Multiple) {
+		trigger("round-amount", r.Score, "%s is a multiple of %.2f", req.Amount, r.Multiple)
+	}
+
+	switch {
+	case result.Score >= rules.AutoRejectAtOrAbove:
+		result.Outcome = outcomeReject
+	case result.Score < rules.AutoApproveBelow:
+		result.Outcome = outcomeApprove
+	default:
+		result.Outcome = outcomeManual
+	}
+	return result, nil
+}
+
+func (c rulesConfig) historyWindow() time.Duration {
+	var result time.Duration
+	if c.Velocity != nil && time.Duration(c.Velocity.Window) > result {
+		result = time.Duration(c.Velocity.Window)
+	}
+	if c.Structuring != nil && time.Duration(c.Structuring.Window) > result {
+		result = time.Duration(c.Structuring.Window)
+	}
+	return result
+}
+
+func matchesAccount(blocked, bank, account string) bool {
+	if b, a, ok := strings.Cut(blocked, "/"); ok {
+		return b == bank && a == account
+	}
+	return blocked == account
+}
+
+// isMultiple reports whether a positive amount is a whole multiple of multiple, compared in minor units
+func isMultiple(amount contracts.Money, multiple float64) bool {
+	step := contracts.MoneyFromFloat(multiple, amount.Currency).Minor
+	return amount.Minor > 0 && step > 0 && amount.Minor%step == 0
+}
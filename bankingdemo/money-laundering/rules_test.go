@@ -0,0 +1,205 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/arunsworld/temporal-demo/bankingdemo/contracts"
+	"github.com/arunsworld/temporal-demo/bankingdemo/faults"
+)
+
+func TestAssess(t *testing.T) {
+	now := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	type transfer struct {
+		ref    string
+		amount string
+		ago    time.Duration
+	}
+	velocity := &velocityRule{Window: faults.Duration(time.Hour), MaxTransfers: 2, MaxAmount: 5000, Score: 40}
+	structuring := &structuringRule{Window: faults.Duration(time.Hour), Threshold: 1000, LowerFraction: 0.9, Count: 3, Score: 60}
+	tests := []struct {
+		name        string
+		rules       rulesConfig
+		earlier     []transfer // from the same source account, oldest first
+		amount      string
+		destination string
+		wantScore   int
+		wantOutcome string
+		wantRules   []string
+	}{
+		{
+			name:        "default rules approve a small transfer",
+			rules:       defaultRules,
+			amount:      "999.99",
+			wantOutcome: outcomeApprove,
+			wantRules:   []string{},
+		},
+		{
+			name:        "default rules send a large transfer to a reviewer",
+			rules:       defaultRules,
+			amount:      "1000",
+			wantScore:   50,
+			wantOutcome: outcomeManual,
+			wantRules:   []string{"large-amount"},
+		},
+		{
+			name:        "a score just under autoApproveBelow is approved",
+			rules:       rulesConfig{AutoApproveBelow: 30, AutoRejectAtOrAbove: 100, RoundAmounts: &roundAmountRule{Multiple: 100, Score: 29}},
+			amount:      "500",
+			wantScore:   29,
+			wantOutcome: outcomeApprove,
+			wantRules:   []string{"round-amount"},
+		},
+		{
+			name:        "a score of autoApproveBelow goes to a reviewer",
+			rules:       rulesConfig{AutoApproveBelow: 30, AutoRejectAtOrAbove: 100, RoundAmounts: &roundAmountRule{Multiple: 100, Score: 30}},
+			amount:      "500",
+			wantScore:   30,
+			wantOutcome: outcomeManual,
+			wantRules:   []string{"round-amount"},
+		},
+		{
+			name: "scores add up to autoRejectAtOrAbove",
+			rules: rulesConfig{AutoApproveBelow: 30, AutoRejectAtOrAbove: 100,
+				LargeAmount:     &largeAmountRule{Threshold: 1000, Score: 50},
+				BlockedAccounts: &blockedAccountsRule{Accounts: []string{"bcbank/666"}, Score: 50}},
+			amount:      "1000",
+			destination: "666",
+			wantScore:   100,
+			wantOutcome: outcomeReject,
+			wantRules:   []string{"large-amount", "blocked-account"},
+		},
+		{
+			name:        "a blocked account without a bank matches at any bank",
+			rules:       rulesConfig{AutoApproveBelow: 30, AutoRejectAtOrAbove: 100, BlockedAccounts: &blockedAccountsRule{Accounts: []string{"666"}, Score: 50}},
+			amount:      "10",
+			destination: "666",
+			wantScore:   50,
+			wantOutcome: outcomeManual,
+			wantRules:   []string{"blocked-account"},
+		},
+		{
+			name:        "a blocked account at another bank does not match",
+			rules:       rulesConfig{AutoApproveBelow: 30, AutoRejectAtOrAbove: 100, BlockedAccounts: &blockedAccountsRule{Accounts: []string{"abbank/666"}, Score: 50}},
+			amount:      "10",
+			destination: "666",
+			wantOutcome: outcomeApprove,
+			wantRules:   []string{},
+		},
+		{
+			name:        "more transfers than velocity allows",
+			rules:       rulesConfig{AutoApproveBelow: 30, AutoRejectAtOrAbove: 100, Velocity: velocity},
+			earlier:     []transfer{{"ref-1", "10", 50 * time.Minute}, {"ref-2", "10", 10 * time.Minute}},
+			amount:      "10",
+			wantScore:   40,
+			wantOutcome: outcomeManual,
+			wantRules:   []string{"velocity"},
+		},
+		{
+			name:        "velocity only counts transfers within its window",
+			rules:       rulesConfig{AutoApproveBelow: 30, AutoRejectAtOrAbove: 100, Velocity: velocity},
+			earlier:     []transfer{{"ref-1", "10", 61 * time.Minute}, {"ref-2", "10", 10 * time.Minute}},
+			amount:      "10",
+			wantOutcome: outcomeApprove,
+			wantRules:   []string{},
+		},
+		{
+			name:        "a retried transfer is counted once",
+			rules:       rulesConfig{AutoApproveBelow: 30, AutoRejectAtOrAbove: 100, Velocity: velocity},
+			earlier:     []transfer{{"ref-1", "10", 50 * time.Minute}, {"ref", "10", 10 * time.Minute}},
+			amount:      "10",
+			wantOutcome: outcomeApprove,
+			wantRules:   []string{},
+		},
+		{
+			name:        "more than velocity's total amount",
+			rules:       rulesConfig{AutoApproveBelow: 30, AutoRejectAtOrAbove: 100, Velocity: velocity},
+			earlier:     []transfer{{"ref-1", "4000", 30 * time.Minute}},
+			amount:      "1000.01",
+			wantScore:   40,
+			wantOutcome: outcomeManual,
+			wantRules:   []string{"velocity"},
+		},
+		{
+			name:        "transfers just under the structuring threshold",
+			rules:       rulesConfig{AutoApproveBelow: 30, AutoRejectAtOrAbove: 100, Structuring: structuring},
+			earlier:     []transfer{{"ref-1", "950", 50 * time.Minute}, {"ref-2", "999.99", 20 * time.Minute}},
+			amount:      "900",
+			wantScore:   60,
+			wantOutcome: outcomeManual,
+			wantRules:   []string{"structuring"},
+		},
+		{
+			name:  "structuring ignores transfers outside its window or not just under the threshold",
+			rules: rulesConfig{AutoApproveBelow: 30, AutoRejectAtOrAbove: 100, Structuring: structuring},
+			earlier: []transfer{{"ref-1", "950", 61 * time.Minute}, {"ref-2", "1000", 30 * time.Minute},
+				{"ref-3", "899.99", 20 * time.Minute}, {"ref-4", "950", 10 * time.Minute}},
+			amount:      "950",
+			wantOutcome: outcomeApprove,
+			wantRules:   []string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, err := newRulesEngine("", newMemoryStore())
+			if err != nil {
+				t.Fatal(err)
+			}
+			e.rules = tt.rules
+			request := func(ref, amount string) contracts.Request {
+				m, err := contracts.ParseMoney(amount, "USD")
+				if err != nil {
+					t.Fatal(err)
+				}
+				destination := tt.destination
+				if destination == "" {
+					destination = "67890"
+				}
+				return contracts.Request{Ref: ref, SourceBank: "abbank", SourceAcc: "12345", DestinationBank: "bcbank",
+					DestinationAcc: destination, Amount: m}
+			}
+			for _, earlier := range tt.earlier {
+				if _, err := e.assess(request(earlier.ref, earlier.amount), now.Add(-earlier.ago)); err != nil {
+					t.Fatal(err)
+				}
+			}
+			got, err := e.assess(request("ref", tt.amount), now)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Score != tt.wantScore || got.Outcome != tt.wantOutcome || !reflect.DeepEqual(got.ruleNames(), tt.wantRules) {
+				t.Fatalf("got %s (%s), want score %d, %s and rules %v", got.summary(), got.Outcome, tt.wantScore,
+					tt.wantOutcome, tt.wantRules)
+			}
+		})
+	}
+}
+
+func TestIsMultiple(t *testing.T) {
+	tests := []struct {
+		amount   string
+		currency string
+		multiple float64
+		want     bool
+	}{
+		{"500", "USD", 100, true},
+		{"550", "USD", 100, false},
+		{"500.01", "USD", 100, false},
+		{"10.50", "USD", 0.5, true},
+		{"10.25", "USD", 0.5, false},
+		{"0", "USD", 100, false},
+		{"500", "USD", 0, false},
+		{"3000", "JPY", 1000, true},
+		{"3000.5", "KWD", 1000, false},
+	}
+	for _, tt := range tests {
+		m, err := contracts.ParseMoney(tt.amount, tt.currency)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := isMultiple(m, tt.multiple); got != tt.want {
+			t.Errorf("isMultiple(%s, %v) is %v, want %v", m, tt.multiple, got, tt.want)
+		}
+	}
+}
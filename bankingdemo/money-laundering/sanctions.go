@@ -0,0 +1,255 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/arunsworld/temporal-demo/bankingdemo/contracts"
+)
+
+const defaultNameMatchThreshold = 0.85
+
+// watchListEntry is a sanctioned party. Accounts are given as ACCOUNT or BANK/ACCOUNT.
+type watchListEntry struct {
+	ID       string   `json:"id"`
+	Name     string   `json:"name"`
+	Aliases  []string `json:"aliases,omitempty"`
+	Accounts []string `json:"accounts,omitempty"`
+}
+
+func (e watchListEntry) validate() error {
+	if strings.TrimSpace(e.ID) == "" || strings.TrimSpace(e.Name) == "" {
+		return fmt.Errorf("need an id and a name")
+	}
+	return nil
+}
+
+// watchList screens transfers against entries loaded from a JSON or CSV file, reloaded when the file changes.
+// Names match when their similarity is at least nameMatchThreshold.
+type watchList struct {
+	mu                 sync.RWMutex
+	entries            []watchListEntry
+	path               string
+	modTime            time.Time
+	nameMatchThreshold float64
+}
+
+func newWatchList(path string, nameMatchThreshold float64) (*watchList, error) {
+	result := &watchList{path: path, nameMatchThreshold: nameMatchThreshold}
+	if path == "" {
+		return result, nil
+	}
+	if _, err := result.reload(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (l *watchList) reload() (bool, error) {
+	stat, err := os.Stat(l.path)
+	if err != nil {
+		return false, err
+	}
+	l.mu.RLock()
+	unchanged := stat.ModTime().Equal(l.modTime)
+	l.mu.RUnlock()
+	if unchanged {
+		return false, nil
+	}
+	f, err := os.Open(l.path)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+	var entries []watchListEntry
+	if strings.EqualFold(filepath.Ext(l.path), ".csv") {
+		entries, err = readWatchListCSV(f)
+	} else {
+		entries, err = readWatchListJSON(f)
+	}
+	if err != nil {
+		return false, fmt.Errorf("bad watch list %s: %w", l.path, err)
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.entries = entries
+	l.modTime = stat.ModTime()
+	return true, nil
+}
+
+func readWatchListJSON(r io.Reader) ([]watchListEntry, error) {
+	var result []watchListEntry
+	if err := json.NewDecoder(r).Decode(&result); err != nil {
+		return nil, err
+	}
+	for i, e := range result {
+		if err := e.validate(); err != nil {
+			return nil, fmt.Errorf("entry %d: %w", i+1, err)
+		}
+	}
+	return result, nil
+}
+
+// readWatchListCSV reads id,name,aliases,accounts rows after a header; aliases and accounts are separated by ;
+func readWatchListCSV(r io.Reader) ([]watchListEntry, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	rows, err := cr.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	result := []watchListEntry{}
+	for i, row := range rows {
+		if i == 0 {
+			continue
+		}
+		if len(row) < 2 {
+			return nil, fmt.Errorf("line %d: need at least id and name", i+1)
+		}
+		entry := watchListEntry{ID: strings.TrimSpace(row[0]), Name: strings.TrimSpace(row[1])}
+		if len(row) > 2 {
+			entry.Aliases = splitList(row[2])
+		}
+		if len(row) > 3 {
+			entry.Accounts = splitList(row[3])
+		}
+		if err := entry.validate(); err != nil {
+			return nil, fmt.Errorf("line %d: %w", i+1, err)
+		}
+		result = append(result, entry)
+	}
+	return result, nil
+}
+
+func splitList(s string) []string {
+	result := []string{}
+	for _, v := range strings.Split(s, ";") {
+		if v = strings.TrimSpace(v); v != "" {
+			result = append(result, v)
+		}
+	}
+	return result
+}
+
+func (l *watchList) screen(req contracts.Request) []contracts.SanctionsHit {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	result := []contracts.SanctionsHit{}
+	parties := []struct{ party, bank, account, name string }{
+		{"source", req.SourceBank, req.SourceAcc, req.SourceName},
+		{"destination", req.DestinationBank, req.DestinationAcc, req.DestinationName},
+	}
+	for _, p := range parties {
+		for _, e := range l.entries {
+			if hit, ok := l.match(e, p.bank, p.account, p.name); ok {
+				hit.Party = p.party
+				result = append(result, hit)
+			}
+		}
+	}
+	return result
+}
+
+// match reports the best match of a party against an entry, preferring an account match
+func (l *watchList) match(e watchListEntry, bank, account, name string) (contracts.SanctionsHit, bool) {
+	for _, a := range e.Accounts {
+		if matchesAccount(a, bank, account) {
+			return contracts.SanctionsHit{EntryID: e.ID, EntryName: e.Name, MatchedOn: "account", Value: account, Similarity: 1}, true
+		}
+	}
+	if name == "" {
+		return contracts.SanctionsHit{}, false
+	}
+	best := 0.0
+	for _, listed := range append([]string{e.Name}, e.Aliases...) {
+		if s := nameSimilarity(name, listed); s > best {
+			best = s
+		}
+	}
+	if best < l.nameMatchThreshold {
+		return contracts.SanctionsHit{}, false
+	}
+	return contracts.SanctionsHit{EntryID: e.ID, EntryName: e.Name, MatchedOn: "name", Value: name, Similarity: best}, true
+}
+
+// nameSimilarity compares names ignoring case, punctuation and word order, 1 being identical
+func nameSimilarity(a, b string) float64 {
+	na, nb := normaliseName(a), normaliseName(b)
+	if na == "" || nb == "" {
+		return 0
+	}
+	result := similarity(na, nb)
+	if s := similarity(sortedWords(na), sortedWords(nb)); s > result {
+		result = s
+	}
+	return result
+}
+
+func normaliseName(s string) string {
+	s = strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToLower(r)
+		}
+		return ' '
+	}, s)
+	return strings.Join(strings.Fields(s), " ")
+}
+
+func sortedWords(s string) string {
+	words := strings.Fields(s)
+	sort.Strings(words)
+	return strings.Join(words, " ")
+}
+
+func similarity(a, b string) float64 {
+	ra, rb := []rune(a), []rune(b)
+	longest := len(ra)
+	if len(rb) > longest {
+		longest = len(rb)
+	}
+	if longest == 0 {
+		return 1
+	}
+	return 1 - float64(levenshtein(ra, rb))/float64(longest)
+}
+
+func levenshtein(a, b []rune) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = prev[j-1] + cost
+			if prev[j]+1 < curr[j] {
+				curr[j] = prev[j] + 1
+			}
+			if curr[j-1]+1 < curr[j] {
+				curr[j] = curr[j-1] + 1
+			}
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(b)]
+}
+
+func (s *service) sanctionsActivity(_ context.Context, req contracts.Request) ([]contracts.SanctionsHit, error) {
+	return s.watchList.screen(req), nil
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/arunsworld/temporal-demo/bankingdemo/contracts"
+)
+
+func TestLevenshtein(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"", "", 0},
+		{"abc", "", 3},
+		{"", "abc", 3},
+		{"abc", "abc", 0},
+		{"kitten", "sitting", 3},
+		{"flaw", "lawn", 2},
+		{"café", "cafe", 1},
+	}
+	for _, tt := range tests {
+		if got := levenshtein([]rune(tt.a), []rune(tt.b)); got != tt.want {
+			t.Errorf("levenshtein(%q, %q) is %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestNameSimilarity(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+		want float64
+	}{
+		{name: "identical", a: "Ivan Petrov", b: "Ivan Petrov", want: 1},
+		{name: "case and punctuation", a: "O'Brien, Sean", b: "o brien sean", want: 1},
+		{name: "word order", a: "Petrov Ivan", b: "Ivan Petrov", want: 1},
+		{name: "one letter missing", a: "Jon Smith", b: "John Smith", want: 0.9},
+		{name: "unrelated", a: "Ivan Petrov", b: "Jane Doe", want: 0.36},
+		{name: "no name", a: "", b: "Ivan Petrov", want: 0},
+		{name: "punctuation only", a: "--", b: "Ivan Petrov", want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := nameSimilarity(tt.a, tt.b); math.Abs(got-tt.want) > 0.01 {
+				t.Fatalf("got %.3f, want %.2f", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScreen(t *testing.T) {
+	entries := []watchListEntry{
+		{ID: "SDN-1", Name: "John Smith", Aliases: []string{"Johnny Blue"}},
+		{ID: "SDN-2", Name: "Acme Trading", Accounts: []string{"bcbank/666", "777"}},
+	}
+	tests := []struct {
+		name      string
+		threshold float64
+		req       contracts.Request
+		want      []contracts.SanctionsHit
+	}{
+		{
+			name:      "name at the threshold",
+			threshold: 0.9,
+			req:       contracts.Request{SourceName: "Jon Smith"},
+			want:      []contracts.SanctionsHit{{Party: "source", EntryID: "SDN-1", EntryName: "John Smith", MatchedOn: "name", Value: "Jon Smith", Similarity: 0.9}},
+		},
+		{
+			name:      "name under the threshold",
+			threshold: 0.91,
+			req:       contracts.Request{SourceName: "Jon Smith"},
+			want:      []contracts.SanctionsHit{},
+		},
+		{
+			name:      "name in another word order",
+			threshold: defaultNameMatchThreshold,
+			req:       contracts.Request{DestinationName: "SMITH, John"},
+			want:      []contracts.SanctionsHit{{Party: "destination", EntryID: "SDN-1", EntryName: "John Smith", MatchedOn: "name", Value: "SMITH, John", Similarity: 1}},
+		},
+		{
+			name:      "alias",
+			threshold: defaultNameMatchThreshold,
+			req:       contracts.Request{SourceName: "Johnny Blue"},
+			want:      []contracts.SanctionsHit{{Party: "source", EntryID: "SDN-1", EntryName: "John Smith", MatchedOn: "name", Value: "Johnny Blue", Similarity: 1}},
+		},
+		{
+			name:      "account at a bank",
+			threshold: defaultNameMatchThreshold,
+			req:       contracts.Request{SourceBank: "abbank", SourceAcc: "12345", DestinationBank: "bcbank", DestinationAcc: "666"},
+			want:      []contracts.SanctionsHit{{Party: "destination", EntryID: "SDN-2", EntryName: "Acme Trading", MatchedOn: "account", Value: "666", Similarity: 1}},
+		},
+		{
+			name:      "account at another bank",
+			threshold: defaultNameMatchThreshold,
+			req:       contracts.Request{DestinationBank: "abbank", DestinationAcc: "666"},
+			want:      []contracts.SanctionsHit{},
+		},
+		{
+			name:      "account at any bank",
+			threshold: defaultNameMatchThreshold,
+			req:       contracts.Request{SourceBank: "abbank", SourceAcc: "777"},
+			want:      []contracts.SanctionsHit{{Party: "source", EntryID: "SDN-2", EntryName: "Acme Trading", MatchedOn: "account", Value: "777", Similarity: 1}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := &watchList{entries: entries, nameMatchThreshold: tt.threshold}
+			got := l.screen(tt.req)
+			for i := range got {
+				got[i].Similarity = math.Round(got[i].Similarity*100) / 100
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadWatchListCSV(t *testing.T) {
+	csv := "id,name,aliases,accounts\n" +
+		" SDN-1 , John Smith , Johnny Blue; J. Smith ;, bcbank/666;777\n" +
+		"SDN-2,Acme Trading\n"
+	got, err := readWatchListCSV(strings.NewReader(csv))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []watchListEntry{
+		{ID: "SDN-1", Name: "John Smith", Aliases: []string{"Johnny Blue", "J. Smith"}, Accounts: []string{"bcbank/666", "777"}},
+		{ID: "SDN-2", Name: "Acme Trading"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+
+	for name, csv := range map[string]string{
+		"no name":    "id,name\nSDN-1\n",
+		"empty id":   "id,name\n ,John Smith\n",
+		"empty name": "id,name\nSDN-1, \n",
+	} {
+		if _, err := readWatchListCSV(strings.NewReader(csv)); err == nil {
+			t.Errorf("%s: got no error", name)
+		}
+	}
+}
+
+func TestReloadRejectsIncompleteEntries(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "watchlist.json")
+	if err := os.WriteFile(path, []byte(`[{"id": "SDN-1", "name": "John Smith"}]`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	l, err := newWatchList(path, defaultNameMatchThreshold)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, contents := range []string{
+		`[{"id": "SDN-1", "name": "John Smith"}, {"name": "Jane Doe"}]`,
+		`[{"id": "SDN-1", "name": "John Smith"}, {"id": "SDN-2", "name": " "}]`,
+	} {
+		if err := os.WriteFile(path, []byte(contents), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		// a new modification time, so that the file is read again
+		modTime := time.Now().Add(time.Duration(i+1) * time.Second)
+		if err := os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := l.reload(); err == nil {
+			t.Fatalf("%s was loaded", contents)
+		}
+		if len(l.entries) != 1 {
+			t.Fatalf("got entries %+v, want the previous ones kept", l.entries)
+		}
+	}
+}
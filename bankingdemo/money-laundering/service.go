@@ -2,126 +2,614 @@ package main
 
 import (
 	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"html"
 	"log"
 	"net/http"
-	"sort"
-	"sync"
+	"net/url"
+	"strings"
+	"time"
 
+	"github.com/arunsworld/temporal-demo/bankingdemo/auth"
+	"github.com/arunsworld/temporal-demo/bankingdemo/contracts"
 	"github.com/google/uuid"
+	"go.temporal.io/api/serviceerror"
 	"go.temporal.io/sdk/activity"
 	"go.temporal.io/sdk/client"
 )
 
-type Request struct {
-	SourceBank, DestinationBank string
-	SourceAcc, DestinationAcc   string
-	Amount                      float64
-	Ref                         string
+// reasonCodes a reviewer picks from to justify a decision
+var reasonCodes = []string{
+	"source-of-funds-verified",
+	"known-customer",
+	"business-rationale-provided",
+	"suspicious-pattern",
+	"unverified-source-of-funds",
+	"sanctions-concern",
+	"other",
 }
 
 type service struct {
-	mu             sync.RWMutex
-	allRequests    map[string]Request
-	tokenMap       map[string][]byte
-	bizRequests    map[string]string
+	store          reviewStore
+	audit          auditLog
 	workflowClient client.Client
+	rules          *rulesEngine
+	watchList      *watchList
+	reviewMode     string // where reviews are held, see the AMLReviewMode constants
+	authn          auth.Authenticator
+	// transfers of at least this amount need two distinct approvers; a single reject is final
+	dualControlThreshold float64
 }
 
-func newService(c client.Client) *service {
+func newService(c client.Client, store reviewStore, audit auditLog, rules *rulesEngine, watchList *watchList,
+	reviewMode string, dualControlThreshold float64, authn auth.Authenticator) *service {
 	result := &service{
-		allRequests:    make(map[string]Request),
-		tokenMap:       make(map[string][]byte),
-		bizRequests:    make(map[string]string),
-		workflowClient: c,
+		store:                store,
+		audit:                audit,
+		workflowClient:       c,
+		rules:                rules,
+		watchList:            watchList,
+		reviewMode:           reviewMode,
+		dualControlThreshold: dualControlThreshold,
+		authn:                authn,
 	}
 	result.registerHandlers()
 	return result
 }
 
 func (s *service) registerHandlers() {
-	http.HandleFunc("/", s.listHandler)
-	http.HandleFunc("/action", s.actionHandler)
+	reviewers := []string{auth.RoleReviewer, auth.RoleSeniorReviewer}
+	http.HandleFunc("/", s.protect(s.listHandler, reviewers...))
+	http.HandleFunc("/review", s.protect(s.reviewHandler, reviewers...))
+	http.HandleFunc("/action", s.protect(s.actionHandler, reviewers...))
+	http.HandleFunc("/audit", s.protect(s.auditHandler, append(reviewers, auth.RoleOps)...))
+	http.HandleFunc("/audit/export", s.protect(s.auditExportHandler, append(reviewers, auth.RoleOps)...))
 }
 
-func (s *service) listHandler(w http.ResponseWriter, _ *http.Request) {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
+// protect lets only users holding one of roles through to h, and only with a CSRF token when they post
+func (s *service) protect(h http.HandlerFunc, roles ...string) http.HandlerFunc {
+	return auth.Require(s.authn, auth.ProtectCSRF(h), roles...)
+}
+
+func (s *service) listHandler(w http.ResponseWriter, r *http.Request) {
+	reviews, err := s.openReviews(r.Context())
+	if err != nil {
+		_, _ = fmt.Fprint(w, "ERROR:"+err.Error())
+		return
+	}
 
-	if len(s.allRequests) == 0 {
+	if len(reviews) == 0 {
 		_, _ = fmt.Fprint(w, "<h1>MONEY LAUNDERING SERVICE</h1>"+
 			"<h3>No pending approvals</h3>")
 		return
 	}
-	_, _ = fmt.Fprint(w, "<h1>MONEY LAUNDERING SERVICE</h1>"+"<a href=\"/\">HOME</a>"+
-		"<h3>All approval requests:</h3><table border=1><tr><th>From</th><th>To</th><th>Amount</th><th>Reference</th><th>Action</th>")
-	var keys []string
-	for k := range s.allRequests {
-		keys = append(keys, k)
-	}
-	sort.Strings(keys)
-	for _, id := range keys {
-		req := s.allRequests[id]
-		actionLink := fmt.Sprintf("<a href=\"/action?type=approve&id=%s\">"+
-			"<button style=\"background-color:#4CAF50;\">APPROVE</button></a>"+
-			"&nbsp;&nbsp;<a href=\"/action?type=reject&id=%s\">"+
-			"<button style=\"background-color:#f44336;\">REJECT</button></a>", id, id)
-		_, _ = fmt.Fprintf(w, "<tr><td>%s [%s]</td><td>%s [%s]</td><td>%.2f</td><td>%s</td><td>%s</td></tr>",
-			req.SourceBank, req.SourceAcc, req.DestinationBank, req.DestinationAcc,
-			req.Amount, req.Ref, actionLink)
+	_, _ = fmt.Fprint(w, "<h1>MONEY LAUNDERING SERVICE</h1>"+"<a href=\"/\">HOME</a> | <a href=\"/audit\">AUDIT LOG</a>")
+	senior, regular := []pendingReview{}, []pendingReview{}
+	for _, review := range reviews {
+		if review.Escalated {
+			senior = append(senior, review)
+		} else {
+			regular = append(regular, review)
+		}
+	}
+	if len(senior) > 0 {
+		s.writeReviewTable(w, "Senior queue (escalated after missing the review SLA):", senior)
+	}
+	if len(regular) > 0 {
+		s.writeReviewTable(w, "All approval requests:", regular)
+	}
+}
+
+func (s *service) writeReviewTable(w http.ResponseWriter, title string, reviews []pendingReview) {
+	_, _ = fmt.Fprintf(w, "<h3>%s</h3><table border=1><tr><th>From</th><th>To</th><th>Amount</th><th>Reference</th>"+
+		"<th>Waiting since</th><th>Reminders</th><th>Risk</th><th>Approvals</th><th>Action</th>", html.EscapeString(title))
+	for _, review := range reviews {
+		id, req := review.ID, review.Request
+		actionLink := fmt.Sprintf("<a href=\"/review?id=%s\"><button>REVIEW</button></a>", url.QueryEscape(id))
+		_, _ = fmt.Fprintf(w, "<tr><td>%s [%s]</td><td>%s [%s]</td><td>%s</td><td>%s</td><td>%s</td><td>%d</td><td>%s</td><td>%s</td><td>%s</td></tr>",
+			html.EscapeString(req.SourceBank), html.EscapeString(req.SourceAcc),
+			html.EscapeString(req.DestinationBank), html.EscapeString(req.DestinationAcc),
+			html.EscapeString(formatAmount(req)), html.EscapeString(req.Ref), review.CreatedAt.Format(time.RFC1123), review.Reminders,
+			riskLabel(review), html.EscapeString(s.approvalState(review)), actionLink)
 	}
 	_, _ = fmt.Fprint(w, "</table>")
 }
 
-func (s *service) actionHandler(w http.ResponseWriter, r *http.Request) {
+func (s *service) reviewHandler(w http.ResponseWriter, r *http.Request) {
 	id := r.URL.Query().Get("id")
-	s.mu.RLock()
-	_, ok := s.allRequests[id]
-	s.mu.RUnlock()
+	review, ok, err := s.openReview(r.Context(), id)
+	if err != nil {
+		_, _ = fmt.Fprint(w, "ERROR:"+err.Error())
+		return
+	}
 	if !ok {
 		_, _ = fmt.Fprint(w, "ERROR:INVALID_ID")
 		return
 	}
-	actionType := r.URL.Query().Get("type")
-	if !(actionType == "approve" || actionType == "reject") {
-		_, _ = fmt.Fprint(w, "ERROR:INVALID_ACTION_TYPE")
+	csrfToken := auth.CSRFToken(w, r) // sets the cookie, so before any output
+	req := review.Request
+	_, _ = fmt.Fprintf(w, "<h1>MONEY LAUNDERING SERVICE</h1><a href=\"/\">HOME</a>"+
+		"<h3>Review %s</h3><table border=1>"+
+		"<tr><th>From</th><td>%s [%s]</td></tr><tr><th>To</th><td>%s [%s]</td></tr>"+
+		"<tr><th>Amount</th><td>%s</td></tr><tr><th>Waiting since</th><td>%s</td></tr></table>",
+		html.EscapeString(req.Ref), html.EscapeString(req.SourceBank), html.EscapeString(req.SourceAcc),
+		html.EscapeString(req.DestinationBank), html.EscapeString(req.DestinationAcc),
+		html.EscapeString(formatAmount(req)), review.CreatedAt.Format(time.RFC1123))
+	_, _ = fmt.Fprintf(w, "<p><b>Risk:</b> %s</p>", html.EscapeString(review.Assessment.summary()))
+	writeSanctionsHits(w, review.SanctionsHits)
+	if review.Escalated {
+		_, _ = fmt.Fprintf(w, "<p><b>Escalated:</b> waiting past the review SLA after %d reminders, a senior reviewer should decide.</p>",
+			review.Reminders)
+	}
+	if s.needsDualControl(review) {
+		_, _ = fmt.Fprintf(w, "<p><b>Dual control:</b> two different reviewers must approve. %s</p>",
+			html.EscapeString(s.approvalState(review)))
+	}
+	options := ""
+	for _, code := range reasonCodes {
+		options += fmt.Sprintf("<option value=\"%s\">%s</option>", code, code)
+	}
+	reviewerField := "<p><label>Reviewer: <input type=\"text\" name=\"reviewer\" autofocus></label></p>"
+	if identity := auth.FromContext(r.Context()); identity.Subject != "" {
+		reviewerField = fmt.Sprintf("<p>Reviewer: %s</p>", html.EscapeString(identity.DisplayName()))
+	}
+	_, _ = fmt.Fprintf(w, "<form action=\"/action\" method=\"post\"><input type=\"hidden\" name=\"id\" value=\"%s\">"+
+		"<input type=\"hidden\" name=\"%s\" value=\"%s\">%s"+
+		"<p><label>Reason: <select name=\"reason\">%s</select></label></p>"+
+		"<p><label>Comment: <input type=\"text\" name=\"comment\" size=\"60\"></label></p>"+
+		"<button type=\"submit\" name=\"type\" value=\"approve\" style=\"background-color:#4CAF50;\">APPROVE</button>"+
+		"&nbsp;&nbsp;<button type=\"submit\" name=\"type\" value=\"reject\" style=\"background-color:#f44336;\">REJECT</button>"+
+		"</form>", html.EscapeString(id), auth.CSRFField, html.EscapeString(csrfToken), reviewerField, options)
+	s.writeAuditTable(w, req.Ref)
+}
+
+// formatAmount shows the amount in its own currency and, when different, in the reference currency
+func formatAmount(req contracts.Request) string {
+	result := req.Amount.String()
+	if req.ReferenceAmount.Currency != "" && req.ReferenceAmount.Currency != req.Amount.Currency {
+		result += " (" + req.ReferenceAmount.String() + ")"
+	}
+	if req.DestinationCurrency != "" && req.DestinationCurrency != req.Amount.Currency {
+		result += " to " + req.DestinationCurrency
+	}
+	return result
+}
+
+func riskLabel(review pendingReview) string {
+	if len(review.SanctionsHits) > 0 {
+		return fmt.Sprintf("%d <b>SANCTIONS</b>", review.Assessment.Score)
+	}
+	return fmt.Sprintf("%d", review.Assessment.Score)
+}
+
+func writeSanctionsHits(w http.ResponseWriter, hits []contracts.SanctionsHit) {
+	if len(hits) == 0 {
 		return
 	}
-	s.mu.RLock()
-	token, ok := s.tokenMap[id]
-	s.mu.RUnlock()
-	if !ok {
-		_, _ = fmt.Fprint(w, "ERROR:INVALID_ID_FOR_TOKEN")
+	_, _ = fmt.Fprint(w, "<h3 style=\"color:#f44336;\">Sanctions watch-list matches</h3><table border=1>"+
+		"<tr><th>Party</th><th>Matched on</th><th>Value</th><th>Entry</th><th>Listed name</th><th>Similarity</th></tr>")
+	for _, h := range hits {
+		_, _ = fmt.Fprintf(w, "<tr><td>%s</td><td>%s</td><td>%s</td><td>%s</td><td>%s</td><td>%.0f%%</td></tr>",
+			h.Party, h.MatchedOn, html.EscapeString(h.Value), html.EscapeString(h.EntryID), html.EscapeString(h.EntryName),
+			h.Similarity*100)
+	}
+	_, _ = fmt.Fprint(w, "</table>")
+}
+
+// openReviews are the reviews reviewers can act on, wherever they are held
+func (s *service) openReviews(ctx context.Context) ([]pendingReview, error) {
+	if s.reviewMode == contracts.AMLReviewModeSignal {
+		return s.signalReviews(ctx)
+	}
+	return s.pendingReviews()
+}
+
+func (s *service) openReview(ctx context.Context, id string) (pendingReview, bool, error) {
+	if s.reviewMode == contracts.AMLReviewModeSignal {
+		return s.signalReview(ctx, id)
+	}
+	review, ok, err := s.store.Review(id)
+	return review, ok && review.Decision == nil, err
+}
+
+// pendingReviews are the stored reviews still waiting for a decision
+func (s *service) pendingReviews() ([]pendingReview, error) {
+	reviews, err := s.store.Reviews()
+	if err != nil {
+		return nil, err
+	}
+	result := []pendingReview{}
+	for _, r := range reviews {
+		if r.Decision == nil {
+			result = append(result, r)
+		}
+	}
+	return result, nil
+}
+
+func (s *service) actionHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		_, _ = fmt.Fprint(w, "ERROR:USE_POST")
 		return
 	}
-	if err := s.workflowClient.CompleteActivity(context.Background(), token, actionType, nil); err != nil {
+	identity := auth.FromContext(r.Context())
+	decision, err := newDecision(r, identity)
+	if err == nil {
+		err = s.decide(r.Context(), r.PostFormValue("id"), identity, decision)
+	}
+	if err != nil {
+		var rejected actionError
+		var ignored decisionIgnoredError
+		switch {
+		case errors.As(err, &rejected) && rejected.status != 0:
+			w.WriteHeader(rejected.status)
+		case errors.As(err, &ignored):
+			w.WriteHeader(http.StatusConflict)
+		}
 		_, _ = fmt.Fprint(w, "ERROR:"+err.Error())
 		return
 	}
-	s.mu.Lock()
-	delete(s.allRequests, id)
-	s.mu.Unlock()
-
 	http.Redirect(w, r, "/", http.StatusSeeOther)
 }
 
-func (s *service) temporalActivity(ctx context.Context, req Request) (string, error) {
-	s.mu.Lock()
-	defer s.mu.Unlock()
+// actionError is a decision the reviewer may not take, answered as ERROR:<code> with status when it is set
+type actionError struct {
+	status int
+	code   string
+}
 
-	id, ok := s.bizRequests[req.Ref]
-	if !ok {
-		id = uuid.NewString()
-		s.bizRequests[req.Ref] = id
+func (e actionError) Error() string {
+	return e.code
+}
+
+// newDecision reads the decision posted by the reviewer
+func newDecision(r *http.Request, identity auth.Identity) (contracts.AMLDecision, error) {
+	actionType := r.PostFormValue("type")
+	if !(actionType == contracts.MoneyLaunderingApprove || actionType == contracts.MoneyLaunderingReject) {
+		return contracts.AMLDecision{}, actionError{code: "INVALID_ACTION_TYPE"}
+	}
+	// the signed in user; the form only asks for a name when authentication is off
+	reviewer := identity.Subject
+	if reviewer == "" {
+		reviewer = strings.TrimSpace(r.PostFormValue("reviewer"))
+	}
+	if reviewer == "" {
+		return contracts.AMLDecision{}, actionError{code: "MISSING_REVIEWER"}
+	}
+	reasonCode := r.PostFormValue("reason")
+	if !isReasonCode(reasonCode) {
+		return contracts.AMLDecision{}, actionError{code: "INVALID_REASON_CODE"}
+	}
+	return contracts.AMLDecision{
+		Decision:   actionType,
+		Reviewer:   reviewer,
+		ReasonCode: reasonCode,
+		Comment:    strings.TrimSpace(r.PostFormValue("comment")),
+		DecidedAt:  time.Now(),
+	}, nil
+}
+
+// decide applies a reviewer's decision to the review id. In activity mode the store is locked only while the
+// decision is recorded; a decision that settles the review is handed back to the transfer afterwards.
+func (s *service) decide(ctx context.Context, id string, identity auth.Identity, decision contracts.AMLDecision) error {
+	if s.reviewMode == contracts.AMLReviewModeSignal {
+		review, ok, err := s.signalReview(ctx, id)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return actionError{code: "INVALID_ID"}
+		}
+		if err := s.checkDecision(review, identity, &decision); err != nil {
+			return err
+		}
+		return s.sendDecision(ctx, review, decision)
+	}
+	review, settled, err := s.recordDecision(id, identity, decision)
+	if err != nil || !settled {
+		return err
+	}
+	return s.complete(context.Background(), review)
+}
+
+// recordDecision stores decision on the review id, answering whether it settles the review. A first approval of
+// a review under dual control does not.
+func (s *service) recordDecision(id string, identity auth.Identity, decision contracts.AMLDecision) (pendingReview, bool, error) {
+	unlock, err := s.store.Lock()
+	if err != nil {
+		return pendingReview{}, false, err
+	}
+	defer unlock()
+
+	review, ok, err := s.store.Review(id)
+	if err != nil {
+		return pendingReview{}, false, err
+	}
+	if !ok || review.Decision != nil {
+		return pendingReview{}, false, actionError{code: "INVALID_ID"}
+	}
+	if err := s.checkDecision(review, identity, &decision); err != nil {
+		return pendingReview{}, false, err
 	}
+	if decision.Decision == contracts.MoneyLaunderingApprove && s.needsDualControl(review) {
+		review.Approvals = append(review.Approvals, decision)
+		if len(review.Approvals) < 2 {
+			if err := s.store.SaveReview(review); err != nil {
+				return pendingReview{}, false, err
+			}
+			s.recordAudit(review, auditApproval, &decision)
+			return review, false, nil
+		}
+		for _, a := range review.Approvals {
+			decision.Approvers = append(decision.Approvers, a.Reviewer)
+		}
+	}
+	review.Decision = &decision
+	if err := s.store.SaveReview(review); err != nil {
+		return pendingReview{}, false, err
+	}
+	s.recordAudit(review, auditDecision, review.Decision)
+	return review, true, nil
+}
+
+// checkDecision rejects a decision identity may not take on review, and otherwise adds the review's risk
+// assessment to it
+func (s *service) checkDecision(review pendingReview, identity auth.Identity, decision *contracts.AMLDecision) error {
+	if review.Escalated && !identity.HasRole(auth.RoleSeniorReviewer) {
+		return actionError{status: http.StatusForbidden, code: "ESCALATED_REVIEW_NEEDS_SENIOR_REVIEWER"}
+	}
+	if len(review.Token) == 0 && s.reviewMode != contracts.AMLReviewModeSignal {
+		return actionError{code: "INVALID_ID_FOR_TOKEN"}
+	}
+	if decision.Decision == contracts.MoneyLaunderingApprove && s.needsDualControl(review) && review.approvedBy(decision.Reviewer) {
+		return actionError{code: "SAME_REVIEWER_CANNOT_APPROVE_TWICE"}
+	}
+	decision.RiskScore = review.Assessment.Score
+	decision.TriggeredRules = review.Assessment.ruleNames()
+	return nil
+}
+
+// complete hands a decided review back to its workflow and forgets it. A review whose activity no longer exists
+// (the transfer was cancelled or timed out) is forgotten as well.
+func (s *service) complete(ctx context.Context, review pendingReview) error {
+	event := auditCompleted
+	err := s.workflowClient.CompleteActivity(ctx, review.Token, *review.Decision, nil)
+	var notFound *serviceerror.NotFound
+	if errors.As(err, &notFound) {
+		log.Printf("activity for %s no longer exists, dropping the review", review.Request.Ref)
+		event = auditDropped
+		err = nil
+	}
+	if err != nil {
+		return err
+	}
+	s.recordAudit(review, event, review.Decision)
+	return s.store.DeleteReview(review.ID)
+}
+
+func (s *service) needsDualControl(review pendingReview) bool {
+	return s.dualControlThreshold > 0 && review.Request.AMLAmount().Float64() >= s.dualControlThreshold
+}
+
+func (s *service) approvalState(review pendingReview) string {
+	if !s.needsDualControl(review) {
+		return "1 required"
+	}
+	if len(review.Approvals) == 0 {
+		return "0/2"
+	}
+	names := []string{}
+	for _, a := range review.Approvals {
+		names = append(names, a.Reviewer)
+	}
+	return fmt.Sprintf("%d/2 (%s)", len(review.Approvals), strings.Join(names, ", "))
+}
+
+func (s *service) recordAudit(review pendingReview, event string, d *contracts.AMLDecision) {
+	entry := auditEntry{
+		Time:     time.Now(),
+		Ref:      review.Request.Ref,
+		ReviewID: review.ID,
+		Event:    event,
+	}
+	if d != nil {
+		entry.Reviewer = d.Reviewer
+		entry.Decision = d.Decision
+		entry.ReasonCode = d.ReasonCode
+		entry.Comment = d.Comment
+	}
+	if err := s.audit.Append(entry); err != nil {
+		log.Printf("unable to record audit entry %+v: %v", entry, err)
+	}
+}
+
+func isReasonCode(code string) bool {
+	for _, c := range reasonCodes {
+		if c == code {
+			return true
+		}
+	}
+	return false
+}
+
+// resumeCompletions finishes the decisions that were recorded but not yet handed back before a restart
+func (s *service) resumeCompletions(ctx context.Context) error {
+	reviews, err := s.store.Reviews()
+	if err != nil {
+		return err
+	}
+	for _, r := range reviews {
+		if r.Decision == nil {
+			continue
+		}
+		if err := s.complete(ctx, r); err != nil {
+			log.Printf("unable to complete %s for %s: %v", r.Decision.Decision, r.Request.Ref, err)
+		}
+	}
+	return nil
+}
+
+// assess scores a transfer with the rules engine; sanctions hits always need a reviewer
+func (s *service) assess(req contracts.Request, hits []contracts.SanctionsHit) (riskAssessment, error) {
+	result, err := s.rules.assess(req, time.Now())
+	if err != nil {
+		return riskAssessment{}, err
+	}
+	if len(hits) > 0 {
+		result.Outcome = outcomeManual
+		result.Triggered = append(result.Triggered, triggeredRule{
+			Name:   "sanctions-hit",
+			Detail: fmt.Sprintf("%d watch-list matches", len(hits)),
+		})
+	}
+	return result, nil
+}
 
+// temporalActivity screens the transfer with the rules engine and answers straight away unless a reviewer is
+// needed, in which case the review is stored and completed later. Sanctions hits always need a reviewer. A retried
+// attempt reattaches to its review.
+func (s *service) temporalActivity(ctx context.Context, req contracts.Request, hits []contracts.SanctionsHit) (contracts.AMLDecision, error) {
 	info := activity.GetInfo(ctx)
-	token := info.TaskToken
+	decision, decided, err := s.attachReview(req, hits, info.TaskToken)
+	if err != nil || decided {
+		return decision, err
+	}
+	// the transfer starts the review SLA now; every attempt tells it again in case an earlier one could not
+	if err := contracts.NotifyAMLReviewOpened(ctx, s.workflowClient, req.Ref); err != nil {
+		return contracts.AMLDecision{}, err
+	}
+	log.Printf("registered review of %s. Attempt: %d", req.Ref, info.Attempt)
+
+	return contracts.AMLDecision{}, activity.ErrResultPending
+}
+
+// attachReview stores the review of req with the task token of the current attempt, answering the decision
+// instead when there is one already or the rules engine makes it
+func (s *service) attachReview(req contracts.Request, hits []contracts.SanctionsHit, token []byte) (contracts.AMLDecision, bool, error) {
+	unlock, err := s.store.Lock()
+	if err != nil {
+		return contracts.AMLDecision{}, false, err
+	}
+	defer unlock()
+
+	id, ok, err := s.store.ReviewID(req.Ref)
+	if err != nil {
+		return contracts.AMLDecision{}, false, err
+	}
+	var review pendingReview
+	if ok {
+		review, ok, err = s.store.Review(id)
+		if err != nil {
+			return contracts.AMLDecision{}, false, err
+		}
+	}
+	if ok && review.Decision != nil {
+		// decided, but the completion was lost with the previous attempt
+		s.recordAudit(review, auditCompleted, review.Decision)
+		if err := s.store.DeleteReview(id); err != nil {
+			return contracts.AMLDecision{}, false, err
+		}
+		return *review.Decision, true, nil
+	}
+	if !ok {
+		assessment, err := s.assess(req, hits)
+		if err != nil {
+			return contracts.AMLDecision{}, false, err
+		}
+		if assessment.Outcome != outcomeManual {
+			decision := assessment.decision(time.Now())
+			s.recordAudit(pendingReview{Request: req}, auditScreened, &decision)
+			return decision, true, nil
+		}
+		if id == "" {
+			id = uuid.NewString()
+			if err := s.store.SetReviewID(req.Ref, id); err != nil {
+				return contracts.AMLDecision{}, false, err
+			}
+		}
+		review = pendingReview{ID: id, CreatedAt: time.Now(), Assessment: assessment, SanctionsHits: hits}
+	}
+
+	opened := len(review.Token) == 0
+	review.Request = req
+	review.Token = token
+	if err := s.store.SaveReview(review); err != nil {
+		return contracts.AMLDecision{}, false, err
+	}
+	if opened {
+		s.recordAudit(review, auditReviewOpened, &contracts.AMLDecision{Comment: review.Assessment.summary()})
+	}
+	return contracts.AMLDecision{}, false, nil
+}
 
-	s.allRequests[id] = req
-	s.tokenMap[id] = token
+func (s *service) auditHandler(w http.ResponseWriter, r *http.Request) {
+	ref := r.URL.Query().Get("ref")
+	_, _ = fmt.Fprint(w, "<h1>MONEY LAUNDERING SERVICE</h1><a href=\"/\">HOME</a>"+
+		"<form action=\"/audit\" method=\"get\"><label>Reference: <input type=\"text\" name=\"ref\"></label>"+
+		"<button type=\"submit\">Go</button></form>")
+	s.writeAuditTable(w, ref)
+}
 
-	log.Printf("registerd ID: %s. Attempt: %d", id, info.Attempt)
+func (s *service) writeAuditTable(w http.ResponseWriter, ref string) {
+	entries, err := s.audit.Entries(ref)
+	if err != nil {
+		_, _ = fmt.Fprint(w, "ERROR:"+err.Error())
+		return
+	}
+	title := "Audit log"
+	if ref != "" {
+		title = "Audit log for " + ref
+	}
+	exportQuery := url.Values{"ref": {ref}}
+	_, _ = fmt.Fprintf(w, "<h3>%s</h3><p>Export: <a href=\"/audit/export?%s&format=csv\">CSV</a> "+
+		"<a href=\"/audit/export?%s&format=json\">JSON</a></p>"+
+		"<table border=1><tr><th>Time</th><th>Reference</th><th>Event</th><th>Reviewer</th><th>Decision</th><th>Reason</th><th>Comment</th></tr>",
+		html.EscapeString(title), exportQuery.Encode(), exportQuery.Encode())
+	for _, e := range entries {
+		_, _ = fmt.Fprintf(w, "<tr><td>%s</td><td>%s</td><td>%s</td><td>%s</td><td>%s</td><td>%s</td><td>%s</td></tr>",
+			e.Time.Format(time.RFC3339), html.EscapeString(e.Ref), e.Event, html.EscapeString(e.Reviewer),
+			e.Decision, e.ReasonCode, html.EscapeString(e.Comment))
+	}
+	_, _ = fmt.Fprint(w, "</table>")
+}
 
-	return "", activity.ErrResultPending
+func (s *service) auditExportHandler(w http.ResponseWriter, r *http.Request) {
+	ref := r.URL.Query().Get("ref")
+	entries, err := s.audit.Entries(ref)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		_, _ = fmt.Fprint(w, "ERROR:"+err.Error())
+		return
+	}
+	filename := "aml-audit"
+	if ref != "" {
+		filename += "-" + strings.Map(func(r rune) rune {
+			if r == '"' || r == '\\' || r < ' ' {
+				return '_'
+			}
+			return r
+		}, ref)
+	}
+	switch r.URL.Query().Get("format") {
+	case "", "csv":
+		w.Header().Set("Content-Type", "text/csv")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s.csv\"", filename))
+		cw := csv.NewWriter(w)
+		_ = cw.Write([]string{"time", "reference", "review_id", "event", "reviewer", "decision", "reason_code", "comment"})
+		for _, e := range entries {
+			_ = cw.Write([]string{e.Time.Format(time.RFC3339Nano), e.Ref, e.ReviewID, e.Event, e.Reviewer, e.Decision, e.ReasonCode, e.Comment})
+		}
+		cw.Flush()
+	case "json":
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s.json\"", filename))
+		_ = json.NewEncoder(w).Encode(entries)
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = fmt.Fprint(w, "ERROR:INVALID_FORMAT")
+	}
 }
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/arunsworld/temporal-demo/bankingdemo/contracts"
+	"go.temporal.io/api/serviceerror"
+	"go.temporal.io/api/workflowservice/v1"
+)
+
+// In signal mode reviews are held by the transfers themselves: open ones are found through visibility by their
+// AMLReviewStatus search attribute and read with the AMLReviewQuery, and decisions are sent as signals. The
+// service keeps no state for them, so any number of replicas can serve reviewers; only the transfer history of
+// the rules engine is kept in the store. A review's ID is the transfer reference.
+
+// screenActivity is the automatic part of the review in signal mode: a decision when the rules engine can make
+// one, otherwise what the reviewers need to know.
+func (s *service) screenActivity(_ context.Context, req contracts.Request, hits []contracts.SanctionsHit) (contracts.AMLScreening, error) {
+	assessment, err := s.assess(req, hits)
+	if err != nil {
+		return contracts.AMLScreening{}, err
+	}
+	result := contracts.AMLScreening{RiskScore: assessment.Score, ApprovalsRequired: 1}
+	for _, t := range assessment.Triggered {
+		result.TriggeredRules = append(result.TriggeredRules, contracts.AMLRule{Name: t.Name, Score: t.Score, Detail: t.Detail})
+	}
+	review := pendingReview{ID: req.Ref, Request: req, Assessment: assessment}
+	if assessment.Outcome != outcomeManual {
+		decision := assessment.decision(time.Now())
+		result.Decision = &decision
+		s.recordAudit(review, auditScreened, &decision)
+		return result, nil
+	}
+	if s.needsDualControl(review) {
+		result.ApprovalsRequired = 2
+	}
+	s.recordAudit(review, auditReviewOpened, &contracts.AMLDecision{Comment: assessment.summary()})
+	return result, nil
+}
+
+func (s *service) signalReviews(ctx context.Context) ([]pendingReview, error) {
+	result := []pendingReview{}
+	var pageToken []byte
+	for {
+		resp, err := s.workflowClient.ListWorkflow(ctx, &workflowservice.ListWorkflowExecutionsRequest{
+			Query:         contracts.PendingAMLReviewsQuery,
+			NextPageToken: pageToken,
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range resp.Executions {
+			ref, ok := contracts.ReferenceFromMoneyTransferID(e.GetExecution().GetWorkflowId())
+			if !ok {
+				continue
+			}
+			review, ok, err := s.signalReview(ctx, ref)
+			if err != nil {
+				log.Printf("unable to read the review of %s: %v", ref, err)
+				continue
+			}
+			if ok {
+				result = append(result, review)
+			}
+		}
+		pageToken = resp.NextPageToken
+		if len(pageToken) == 0 {
+			break
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].CreatedAt.Before(result[j].CreatedAt)
+	})
+	return result, nil
+}
+
+func (s *service) signalReview(ctx context.Context, ref string) (pendingReview, bool, error) {
+	reviewCase, err := contracts.QueryAMLReview(ctx, s.workflowClient, ref)
+	var notFound *serviceerror.NotFound
+	if errors.As(err, &notFound) {
+		return pendingReview{}, false, nil
+	}
+	if err != nil || !reviewCase.Open {
+		return pendingReview{}, false, err
+	}
+	assessment := riskAssessment{Score: reviewCase.Screening.RiskScore, Outcome: outcomeManual}
+	for _, rule := range reviewCase.Screening.TriggeredRules {
+		assessment.Triggered = append(assessment.Triggered, triggeredRule{Name: rule.Name, Score: rule.Score, Detail: rule.Detail})
+	}
+	return pendingReview{
+		ID:            ref,
+		Request:       reviewCase.Request,
+		CreatedAt:     reviewCase.Since,
+		Assessment:    assessment,
+		SanctionsHits: reviewCase.SanctionsHits,
+		Approvals:     reviewCase.Approvals,
+		Reminders:     reviewCase.Reminders,
+		Escalated:     reviewCase.Escalated,
+	}, true, nil
+}
+
+// decisionPollInterval and decisionWait bound how long a reviewer waits to learn whether the transfer took
+// their decision
+const (
+	decisionPollInterval = time.Millisecond * 200
+	decisionWait         = time.Second * 10
+)
+
+var errDecisionNotConfirmed = errors.New("the transfer has not acted on the decision yet; check the review again")
+
+// decisionIgnoredError is a decision the transfer would not accept, such as a second approval by the same reviewer
+// or one that came after the review closed
+type decisionIgnoredError struct {
+	problem string
+}
+
+func (e decisionIgnoredError) Error() string {
+	return "decision not accepted: " + e.problem
+}
+
+// sendDecision hands a reviewer's decision to the transfer, which applies dual control itself, and waits until
+// the transfer has acted on it. A decision it ignores is reported as a decisionIgnoredError.
+func (s *service) sendDecision(ctx context.Context, review pendingReview, decision contracts.AMLDecision) error {
+	if err := contracts.SendAMLDecision(ctx, s.workflowClient, review.Request.Ref, decision); err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, decisionWait)
+	defer cancel()
+	for {
+		reviewCase, err := contracts.QueryAMLReview(ctx, s.workflowClient, review.Request.Ref)
+		if err != nil && ctx.Err() == nil {
+			return err
+		}
+		if err == nil {
+			handled, counted, problem := reviewCase.DecisionOutcome(decision)
+			if handled && !counted {
+				return decisionIgnoredError{problem: problem}
+			}
+			if handled {
+				break
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return errDecisionNotConfirmed
+		case <-time.After(decisionPollInterval):
+		}
+	}
+	event := auditDecision
+	if decision.Decision == contracts.MoneyLaunderingApprove && s.needsDualControl(review) && len(review.Approvals) == 0 {
+		event = auditApproval
+	}
+	s.recordAudit(review, event, &decision)
+	return nil
+}
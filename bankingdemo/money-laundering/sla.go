@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/arunsworld/temporal-demo/bankingdemo/contracts"
+)
+
+// The review SLA runs in the MoneyTransfer workflow, which tells the service about its events through these
+// activities. A notice for a review that no longer exists is ignored.
+
+func (s *service) reminderActivity(_ context.Context, notice contracts.AMLReviewNotice) error {
+	return s.updateForNotice(notice, func(review *pendingReview) (string, bool) {
+		review.Reminders = notice.Event.Reminder
+		log.Printf("REMINDER %d: review of %s is still waiting", notice.Event.Reminder, notice.Ref)
+		return auditReminder, true
+	})
+}
+
+func (s *service) escalateActivity(_ context.Context, notice contracts.AMLReviewNotice) error {
+	return s.updateForNotice(notice, func(review *pendingReview) (string, bool) {
+		review.Escalated = true
+		log.Printf("ESCALATED: review of %s moved to the senior reviewers", notice.Ref)
+		return auditEscalated, true
+	})
+}
+
+// expireActivity forgets a review whose SLA expired; the workflow has applied its default outcome
+func (s *service) expireActivity(_ context.Context, notice contracts.AMLReviewNotice) error {
+	return s.updateForNotice(notice, func(review *pendingReview) (string, bool) {
+		log.Printf("EXPIRED: review of %s, %s applied", notice.Ref, notice.Outcome)
+		return auditExpired, false
+	})
+}
+
+// updateForNotice applies update to the pending review of notice.Ref and audits the event update returns. The
+// review is saved when update keeps it and deleted otherwise.
+func (s *service) updateForNotice(notice contracts.AMLReviewNotice, update func(*pendingReview) (string, bool)) error {
+	if s.reviewMode == contracts.AMLReviewModeSignal {
+		// the transfer keeps the review up to date itself
+		review := pendingReview{ID: notice.Ref, Request: contracts.Request{Ref: notice.Ref}}
+		event, _ := update(&review)
+		s.recordAudit(review, event, noticeDecision(notice))
+		return nil
+	}
+	unlock, err := s.store.Lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	id, ok, err := s.store.ReviewID(notice.Ref)
+	if err != nil || !ok {
+		return err
+	}
+	review, ok, err := s.store.Review(id)
+	if err != nil || !ok || review.Decision != nil {
+		return err
+	}
+	event, keep := update(&review)
+	if keep {
+		err = s.store.SaveReview(review)
+	} else {
+		err = s.store.DeleteReview(id)
+	}
+	if err != nil {
+		return err
+	}
+	s.recordAudit(review, event, noticeDecision(notice))
+	return nil
+}
+
+func noticeDecision(notice contracts.AMLReviewNotice) *contracts.AMLDecision {
+	if notice.Outcome != "" {
+		return &contracts.AMLDecision{Decision: notice.Outcome, Reviewer: "review-sla", Comment: "no decision in time"}
+	}
+	return &contracts.AMLDecision{Comment: fmt.Sprintf("review SLA %s", notice.Event.Event)}
+}
@@ -0,0 +1,293 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/arunsworld/temporal-demo/bankingdemo/contracts"
+)
+
+type pendingReview struct {
+	ID        string
+	Request   contracts.Request
+	Token     []byte
+	CreatedAt time.Time
+	// Assessment is why the rules engine sent the transfer for review
+	Assessment riskAssessment
+	// SanctionsHits found by the screening, shown to the reviewer
+	SanctionsHits []contracts.SanctionsHit
+	// Reminders sent and whether the review SLA moved the review to the senior reviewers
+	Reminders int
+	Escalated bool
+	// Approvals collected so far for reviews that need two reviewers
+	Approvals []contracts.AMLDecision
+	// Decision is recorded before the activity is completed so that a completion interrupted by a restart can
+	// be finished on boot
+	Decision *contracts.AMLDecision
+}
+
+func (r pendingReview) approvedBy(reviewer string) bool {
+	for _, a := range r.Approvals {
+		if a.Reviewer == reviewer {
+			return true
+		}
+	}
+	return false
+}
+
+// reviewStore keeps everything needed to finish a review after a restart: the pending reviews with their task
+// tokens and decisions, and the review ID assigned to each transfer reference. It also keeps the recent transfers
+// of each source account that the rules engine looks at. A store may be shared by several
+// replicas of the service; Lock serialises changes to reviews across all of them and is held only around reads
+// and writes of the store, never across calls to Temporal.
+type reviewStore interface {
+	SaveReview(r pendingReview) error
+	Review(id string) (pendingReview, bool, error)
+	DeleteReview(id string) error      // along with the reference assigned it
+	Reviews() ([]pendingReview, error) // sorted by ID
+	ReviewID(ref string) (string, bool, error)
+	SetReviewID(ref, id string) error
+	Lock() (unlock func(), err error)
+	transferHistory
+}
+
+type storeState struct {
+	Reviews map[string]pendingReview
+	Refs    map[string]string
+	History map[string][]transferRecord // by source account
+}
+
+func newStoreState() storeState {
+	return storeState{
+		Reviews: make(map[string]pendingReview),
+		Refs:    make(map[string]string),
+		History: make(map[string][]transferRecord),
+	}
+}
+
+// jsonStore holds the state in memory or, when given a path, in a JSON file. The file is read afresh for every
+// operation and changed under a file lock, so replicas can share it on a common volume.
+type jsonStore struct {
+	mu     sync.Mutex
+	path   string
+	state  storeState // when there is no file
+	locker *fileMutex
+}
+
+func newMemoryStore() *jsonStore {
+	return &jsonStore{state: newStoreState(), locker: &fileMutex{}}
+}
+
+func newFileStore(path string) (*jsonStore, error) {
+	result := &jsonStore{path: path, locker: &fileMutex{path: path + ".mutex"}}
+	if err := result.read(func(storeState) {}); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (s *jsonStore) SaveReview(r pendingReview) error {
+	return s.update(func(state *storeState) {
+		state.Reviews[r.ID] = r
+	})
+}
+
+func (s *jsonStore) Review(id string) (pendingReview, bool, error) {
+	var r pendingReview
+	var ok bool
+	err := s.read(func(state storeState) {
+		r, ok = state.Reviews[id]
+	})
+	return r, ok, err
+}
+
+func (s *jsonStore) DeleteReview(id string) error {
+	return s.update(func(state *storeState) {
+		delete(state.Reviews, id)
+		for ref, refID := range state.Refs {
+			if refID == id {
+				delete(state.Refs, ref)
+			}
+		}
+	})
+}
+
+func (s *jsonStore) Reviews() ([]pendingReview, error) {
+	result := []pendingReview{}
+	err := s.read(func(state storeState) {
+		for _, r := range state.Reviews {
+			result = append(result, r)
+		}
+	})
+	sort.Slice(result, func(i, j int) bool { return result[i].ID < result[j].ID })
+	return result, err
+}
+
+func (s *jsonStore) ReviewID(ref string) (string, bool, error) {
+	var id string
+	var ok bool
+	err := s.read(func(state storeState) {
+		id, ok = state.Refs[ref]
+	})
+	return id, ok, err
+}
+
+func (s *jsonStore) SetReviewID(ref, id string) error {
+	return s.update(func(state *storeState) {
+		state.Refs[ref] = id
+	})
+}
+
+func (s *jsonStore) RecordTransfer(account string, t transferRecord, keep time.Duration) ([]transferRecord, error) {
+	var result []transferRecord
+	err := s.update(func(state *storeState) {
+		if _, ok := state.History[account]; !ok {
+			state.History[account] = nil
+		}
+		for key, records := range state.History {
+			recent := []transferRecord{}
+			seen := false
+			for _, r := range records {
+				if t.At.Sub(r.At) > keep {
+					continue
+				}
+				seen = seen || r.Ref == t.Ref
+				recent = append(recent, r)
+			}
+			if key == account {
+				if !seen {
+					recent = append(recent, t)
+				}
+				result = append([]transferRecord(nil), recent...)
+			}
+			if len(recent) == 0 {
+				delete(state.History, key)
+				continue
+			}
+			state.History[key] = recent
+		}
+	})
+	return result, err
+}
+
+func (s *jsonStore) Lock() (func(), error) {
+	return s.locker.Lock()
+}
+
+func (s *jsonStore) read(f func(storeState)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.path == "" {
+		f(s.state)
+		return nil
+	}
+	unlock, err := lockFile(s.path+".lock", false)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	state, err := loadStoreState(s.path)
+	if err != nil {
+		return err
+	}
+	f(state)
+	return nil
+}
+
+func (s *jsonStore) update(f func(*storeState)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.path == "" {
+		f(&s.state)
+		return nil
+	}
+	unlock, err := lockFile(s.path+".lock", true)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	state, err := loadStoreState(s.path)
+	if err != nil {
+		return err
+	}
+	f(&state)
+	return writeFileAtomically(s.path, state)
+}
+
+func loadStoreState(path string) (storeState, error) {
+	result := newStoreState()
+	contents, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return result, nil
+	}
+	if err != nil {
+		return result, err
+	}
+	if err := json.Unmarshal(contents, &result); err != nil {
+		return result, fmt.Errorf("corrupt store %s: %w", path, err)
+	}
+	if result.Reviews == nil {
+		result.Reviews = make(map[string]pendingReview)
+	}
+	if result.Refs == nil {
+		result.Refs = make(map[string]string)
+	}
+	if result.History == nil {
+		result.History = make(map[string][]transferRecord)
+	}
+	return result, nil
+}
+
+// fileMutex is a mutex held across processes through an exclusive lock on path, or within this process only
+// when there is no path. Failing to take the file lock is an error rather than excluding this process alone.
+type fileMutex struct {
+	mu   sync.Mutex
+	path string
+}
+
+func (m *fileMutex) Lock() (func(), error) {
+	m.mu.Lock()
+	if m.path == "" {
+		return m.mu.Unlock, nil
+	}
+	unlock, err := lockFile(m.path, true)
+	if err != nil {
+		m.mu.Unlock()
+		return nil, fmt.Errorf("unable to lock %s: %w", m.path, err)
+	}
+	return func() {
+		unlock()
+		m.mu.Unlock()
+	}, nil
+}
+
+// writeFileAtomically replaces path so that readers never see a partially written file
+func writeFileAtomically(path string, v interface{}) error {
+	contents, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(contents); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
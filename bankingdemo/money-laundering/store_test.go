@@ -0,0 +1,146 @@
+//go:build unix
+
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/arunsworld/temporal-demo/bankingdemo/contracts"
+)
+
+// TestSharedFileStore runs two replicas against one store file, as on a shared volume
+func TestSharedFileStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "aml-store.json")
+	replicas := make([]*jsonStore, 2)
+	for i := range replicas {
+		store, err := newFileStore(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		replicas[i] = store
+	}
+	const reviews = 40
+
+	var wg sync.WaitGroup
+	for i := 0; i < reviews; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			store := replicas[i%2]
+			ref, id := fmt.Sprintf("ref-%d", i), fmt.Sprintf("review-%02d", i)
+			if err := store.SetReviewID(ref, id); err != nil {
+				t.Error(err)
+				return
+			}
+			if err := store.SaveReview(pendingReview{ID: id, Request: contracts.Request{Ref: ref}}); err != nil {
+				t.Error(err)
+			}
+		}(i)
+	}
+	wg.Wait()
+	for i, store := range replicas {
+		got, err := store.Reviews()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(got) != reviews {
+			t.Fatalf("replica %d sees %d reviews, want %d", i, len(got), reviews)
+		}
+	}
+
+	// both replicas try to decide every review; Lock lets only one of them do it
+	var decided int64
+	for i := 0; i < reviews; i++ {
+		for _, store := range replicas {
+			wg.Add(1)
+			go func(store *jsonStore, id string) {
+				defer wg.Done()
+				unlock, err := store.Lock()
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				defer unlock()
+				review, ok, err := store.Review(id)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				if !ok || review.Decision != nil {
+					return
+				}
+				review.Decision = &contracts.AMLDecision{Decision: contracts.MoneyLaunderingApprove, Reviewer: "alice"}
+				if err := store.SaveReview(review); err != nil {
+					t.Error(err)
+					return
+				}
+				if err := store.DeleteReview(id); err != nil {
+					t.Error(err)
+					return
+				}
+				atomic.AddInt64(&decided, 1)
+			}(store, fmt.Sprintf("review-%02d", i))
+		}
+	}
+	wg.Wait()
+	if decided != reviews {
+		t.Fatalf("%d decisions were made, want one per review (%d)", decided, reviews)
+	}
+	for i, store := range replicas {
+		got, err := store.Reviews()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(got) != 0 {
+			t.Fatalf("replica %d still sees %d reviews", i, len(got))
+		}
+		if _, ok, err := store.ReviewID("ref-0"); err != nil || ok {
+			t.Fatalf("replica %d still has the reference of a decided review (%v)", i, err)
+		}
+	}
+}
+
+// TestSharedTransferHistory checks that replicas sharing a store count each other's transfers
+func TestSharedTransferHistory(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "aml-store.json")
+	replicas := make([]*jsonStore, 2)
+	for i := range replicas {
+		store, err := newFileStore(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		replicas[i] = store
+	}
+	start := time.Date(2026, 10, 1, 9, 0, 0, 0, time.UTC)
+	record := func(store *jsonStore, account, ref string, at time.Time) []transferRecord {
+		got, err := store.RecordTransfer(account, transferRecord{Ref: ref, Amount: 100, At: at}, time.Hour)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return got
+	}
+	record(replicas[0], "abbank/A1", "ref-1", start)
+	record(replicas[1], "abbank/A2", "ref-2", start)
+	if got := record(replicas[1], "abbank/A1", "ref-3", start.Add(time.Minute)); len(got) != 2 {
+		t.Fatalf("replica 1 sees %+v, want the transfers of both replicas", got)
+	}
+	// a retried assessment is counted once
+	if got := record(replicas[0], "abbank/A1", "ref-3", start.Add(2*time.Minute)); len(got) != 2 {
+		t.Fatalf("got %+v after a retry, want 2 transfers", got)
+	}
+	if got := record(replicas[0], "abbank/A1", "ref-4", start.Add(61*time.Minute)); len(got) != 2 || got[0].Ref != "ref-3" {
+		t.Fatalf("got %+v, want ref-1 forgotten", got)
+	}
+	state, err := loadStoreState(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := state.History["abbank/A2"]; ok {
+		t.Fatal("the history of an account without recent transfers is kept")
+	}
+}
@@ -5,6 +5,8 @@ go 1.20
 require (
 	github.com/arunsworld/nursery v0.6.0
 	github.com/google/uuid v1.3.0
+	github.com/stretchr/testify v1.8.3
+	go.temporal.io/api v1.21.0
 	go.temporal.io/sdk v1.24.0
 )
 
@@ -21,8 +23,6 @@ require (
 	github.com/pmezard/go-difflib v1.0.0 // indirect
 	github.com/robfig/cron v1.2.0 // indirect
 	github.com/stretchr/objx v0.5.0 // indirect
-	github.com/stretchr/testify v1.8.3 // indirect
-	go.temporal.io/api v1.21.0 // indirect
 	go.uber.org/atomic v1.9.0 // indirect
 	golang.org/x/net v0.10.0 // indirect
 	golang.org/x/sys v0.8.0 // indirect
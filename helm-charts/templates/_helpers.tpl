@@ -79,8 +79,9 @@ Create the name of the service account to use
 9999
 {{- end}}
 
-{{- define "temporal-demo.abbank.port" -}}
+{{- define "temporal-demo.fx.port" -}}
 {{- end}}
 
-{{- define "temporal-demo.bcbank.port" -}}
+{{- define "temporal-demo.bank.port" -}}
+9499
 {{- end}}